@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// RoleSystem - роль изменений, выполненных без HTTP-запроса (фоновые задачи).
+const RoleSystem = "system"
+
+type ctxKey struct{}
+
+// Actor - автор изменения.
+type Actor struct {
+	ID        *int64
+	Role      string
+	IP        string
+	RequestID string
+}
+
+// WithActor - сохранение автора изменений в контексте.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, ctxKey{}, a)
+}
+
+// ActorFromContext - автор изменений из контекста; без запроса изменения относятся к системе.
+func ActorFromContext(ctx context.Context) Actor {
+	if ctx != nil {
+		if a, ok := ctx.Value(ctxKey{}).(Actor); ok {
+			return a
+		}
+	}
+	return Actor{Role: RoleSystem}
+}
+
+// Diff - поля, значения которых различаются. Значения сравниваются после
+// преобразования в JSON; nil означает отсутствие объекта (создание или удаление),
+// тогда в результат попадают все поля другого объекта.
+func Diff(before, after any) (map[string]any, map[string]any, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b == nil || a == nil {
+		return b, a, nil
+	}
+
+	changedBefore := map[string]any{}
+	changedAfter := map[string]any{}
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			changedBefore[k] = v
+			changedAfter[k] = a[k]
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changedBefore[k] = nil
+			changedAfter[k] = v
+		}
+	}
+	return changedBefore, changedAfter, nil
+}
+
+// toMap - JSON-представление объекта в виде map.
+func toMap(v any) (map[string]any, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Хэш для сравнения, когда клиент не найден: время ответа не должно
+// выдавать, существует ли учетная запись
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// HashPassword - хэширование пароля bcrypt.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHashed - хранится ли пароль в виде bcrypt-хэша. Пароли из fill_data.sql
+// хранятся открытым текстом и перехэшируются при первом успешном входе.
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword - сравнение пароля с сохраненным значением.
+func CheckPassword(stored, password string) bool {
+	if IsHashed(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// CheckDummyPassword - сравнение с фиктивным хэшем для выравнивания времени ответа.
+func CheckDummyPassword(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
+// ErrInvalidToken - токен отсутствует, поврежден или просрочен.
+var ErrInvalidToken = errors.New("недействительный токен авторизации")
+
+// Claims - данные, которые хранятся в JWT.
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// CustomerID - ID клиента из поля sub.
+func (c *Claims) CustomerID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// GenerateToken - выпуск токена HS256 для клиента.
+func GenerateToken(secret string, customerID int64, role string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(customerID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("ошибка подписи токена: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// ParseToken - проверка подписи и срока действия токена.
+func ParseToken(secret, token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if _, err := claims.CustomerID(); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -9,16 +12,53 @@ import (
 
 type Config struct {
 	JWT           JWT           `mapstructure:",squash"`
+	Login         Login         `mapstructure:",squash"`
+	Account       Account       `mapstructure:",squash"`
+	Mailer        Mailer        `mapstructure:",squash"`
+	AgeCheck      AgeCheck      `mapstructure:",squash"`
+	Privacy       Privacy       `mapstructure:",squash"`
+	PII           PII           `mapstructure:",squash"`
+	Catalog       Catalog       `mapstructure:",squash"`
+	Import        Import        `mapstructure:",squash"`
+	Images        Images        `mapstructure:",squash"`
+	S3            S3            `mapstructure:",squash"`
 	Database      Database      `mapstructure:",squash"`
 	ServerPort    int           `mapstructure:"SERVER_PORT"`
 	ServerAddress string        `mapstructure:"SERVER_ADDRESS"`
 	Debug         bool          `mapstructure:"DEBUG"`
 	CORS          CORS          `mapstructure:",squash"`
+	Tracing       Tracing       `mapstructure:",squash"`
+	RateLimit     RateLimit     `mapstructure:",squash"`
+	Redis         Redis         `mapstructure:",squash"`
 	Timeout       time.Duration `mapstructure:"SERVER_TIMEOUT"`
+	// Время ожидания завершения активных запросов при остановке сервера
+	ShutdownTimeout time.Duration `mapstructure:"SERVER_SHUTDOWN_TIMEOUT"`
+	// Время жизни неактивного keep-alive соединения
+	IdleTimeout time.Duration `mapstructure:"SERVER_IDLE_TIMEOUT"`
+	// Адреса и подсети прокси, которым доверяется X-Forwarded-For; пустой список -
+	// IP клиента берется из соединения, заголовок игнорируется
+	TrustedProxies []string `mapstructure:"SERVER_TRUSTED_PROXIES"`
+	// Таймаут каждой проверки в /readyz
+	HealthCheckTimeout time.Duration `mapstructure:"HEALTH_CHECK_TIMEOUT"`
 }
 
 type JWT struct {
 	Secret string `mapstructure:"JWT_SECRET"`
+	// Время жизни выдаваемого токена
+	TTL time.Duration `mapstructure:"JWT_TTL"`
+}
+
+// Защита от подбора пароля
+type Login struct {
+	// Количество неудачных попыток, после которого учетная запись блокируется
+	MaxFailedAttempts int           `mapstructure:"LOGIN_MAX_FAILED_ATTEMPTS"`
+	LockoutDuration   time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	// Неудачные попытки с одного IP за окно IPWindow, после которых IP блокируется
+	IPMaxFailedAttempts int           `mapstructure:"LOGIN_IP_MAX_FAILED_ATTEMPTS"`
+	IPWindow            time.Duration `mapstructure:"LOGIN_IP_WINDOW"`
+	// Минимальная пауза после неудачной попытки удваивается с каждой следующей до DelayMax
+	DelayBase time.Duration `mapstructure:"LOGIN_DELAY_BASE"`
+	DelayMax  time.Duration `mapstructure:"LOGIN_DELAY_MAX"`
 }
 
 type Database struct {
@@ -28,12 +68,151 @@ type Database struct {
 	Password string `mapstructure:"DATABASE_PASSWORD"`
 	Name     string `mapstructure:"DATABASE_NAME"`
 	SSLMode  string `mapstructure:"DATABASE_SSLMODE"`
+	// Путь к корневому сертификату для sslmode=verify-ca/verify-full
+	SSLRootCert     string        `mapstructure:"DATABASE_SSLROOTCERT"`
+	ConnectTimeout  time.Duration `mapstructure:"DATABASE_CONNECT_TIMEOUT"`
+	ApplicationName string        `mapstructure:"DATABASE_APPLICATION_NAME"`
+	// Применять миграции из internal/db/migrations при запуске
+	AutoMigrate bool `mapstructure:"DATABASE_AUTO_MIGRATE"`
+	// Порог, после которого запрос считается медленным и пишется в лог
+	SlowQueryThreshold time.Duration `mapstructure:"DATABASE_SLOW_QUERY_THRESHOLD"`
+
+	// Настройки пула соединений
+	MaxOpenConns    int           `mapstructure:"DATABASE_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `mapstructure:"DATABASE_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `mapstructure:"DATABASE_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `mapstructure:"DATABASE_CONN_MAX_IDLE_TIME"`
 }
 
 type CORS struct {
 	Origins []string `mapstructure:"CORS_ORIGINS"`
 	Methods []string `mapstructure:"CORS_METHODS"`
 	Headers []string `mapstructure:"CORS_HEADERS"`
+	// Заголовки ответа, доступные JavaScript на стороне клиента
+	ExposedHeaders []string `mapstructure:"CORS_EXPOSED_HEADERS"`
+	// С учетными данными источник "*" запрещен: ответ с ними разрешал бы чтение любому сайту
+	AllowCredentials bool          `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `mapstructure:"CORS_MAX_AGE"`
+}
+
+// Сброс пароля и подтверждение email
+type Account struct {
+	// Адрес клиентского приложения, на который ведут ссылки из писем
+	AppBaseURL           string        `mapstructure:"APP_BASE_URL"`
+	PasswordResetTTL     time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+	EmailVerificationTTL time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+}
+
+// Отправка писем
+type Mailer struct {
+	// smtp или file (письма сохраняются в MAILER_FILE_DIR)
+	Driver       string `mapstructure:"MAILER_DRIVER"`
+	From         string `mapstructure:"MAILER_FROM"`
+	FileDir      string `mapstructure:"MAILER_FILE_DIR"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+}
+
+// Проверка возраста покупателей
+type AgeCheck struct {
+	// Минимальный возраст для покупки товаров из категорий с возрастным ограничением
+	MinimumAge int `mapstructure:"AGE_MINIMUM"`
+	// Максимальный размер загружаемого документа, удостоверяющего личность
+	DocumentMaxSize int64 `mapstructure:"AGE_DOCUMENT_MAX_SIZE"`
+}
+
+// Выгрузка и удаление персональных данных
+type Privacy struct {
+	// Срок, в течение которого клиент может отменить запрос на удаление
+	ErasureGracePeriod time.Duration `mapstructure:"PRIVACY_ERASURE_GRACE_PERIOD"`
+	// Как часто проверять запросы, срок отмены которых истек
+	ErasureInterval time.Duration `mapstructure:"PRIVACY_ERASURE_INTERVAL"`
+}
+
+// Шифрование персональных данных
+type PII struct {
+	// Мастер-ключи "kid1:<base64>,kid2:<base64>" (32 байта каждый)
+	Keys string `mapstructure:"PII_KEYS"`
+	// Ключ, которым шифруются новые значения; старые ключи нужны для расшифровки
+	PrimaryKeyID string `mapstructure:"PII_PRIMARY_KEY_ID"`
+	// JSON-файл с ключами; если задан, PII_KEYS и PII_BLIND_INDEX_KEY не используются
+	KeyFile string `mapstructure:"PII_KEYFILE"`
+	// Ключ HMAC для слепого индекса email (base64, не короче 32 байт)
+	BlindIndexKey string `mapstructure:"PII_BLIND_INDEX_KEY"`
+	// Как часто шифровать старые значения и перешифровывать их основным ключом
+	ReencryptInterval time.Duration `mapstructure:"PII_REENCRYPT_INTERVAL"`
+}
+
+// Удаление категорий и товаров
+type Catalog struct {
+	// Сколько хранятся удаленные записи, на которые ничего не ссылается, до окончательного удаления
+	PurgeRetention time.Duration `mapstructure:"CATALOG_PURGE_RETENTION"`
+	// Как часто искать записи для окончательного удаления
+	PurgeInterval time.Duration `mapstructure:"CATALOG_PURGE_INTERVAL"`
+}
+
+// Импорт товаров из таблиц
+type Import struct {
+	// Максимальный размер загружаемого файла
+	MaxSize int64 `mapstructure:"IMPORT_MAX_SIZE"`
+	// Как часто искать задания импорта в очереди
+	Interval time.Duration `mapstructure:"IMPORT_INTERVAL"`
+}
+
+// Изображения товаров
+type Images struct {
+	// file (каталог IMAGES_DIR) или s3
+	Store string `mapstructure:"IMAGES_STORE"`
+	Dir   string `mapstructure:"IMAGES_DIR"`
+	// Максимальный размер одного загружаемого файла
+	MaxSize int64 `mapstructure:"IMAGES_MAX_SIZE"`
+	// Максимальное количество пикселей; защищает от изображений, которые занимают
+	// гигабайты памяти после декодирования
+	MaxPixels int `mapstructure:"IMAGES_MAX_PIXELS"`
+	// Стороны квадратов, в которые вписываются уменьшенные копии
+	ThumbnailSizes []int `mapstructure:"IMAGES_THUMBNAIL_SIZES"`
+	// Время кэширования файлов клиентами; содержимое по адресу изображения не меняется
+	CacheMaxAge time.Duration `mapstructure:"IMAGES_CACHE_MAX_AGE"`
+}
+
+// S3-совместимое хранилище файлов
+type S3 struct {
+	// Например https://s3.eu-central-1.amazonaws.com или http://localhost:9000 для MinIO
+	Endpoint  string `mapstructure:"S3_ENDPOINT"`
+	Region    string `mapstructure:"S3_REGION"`
+	Bucket    string `mapstructure:"S3_BUCKET"`
+	AccessKey string `mapstructure:"S3_ACCESS_KEY"`
+	SecretKey string `mapstructure:"S3_SECRET_KEY"`
+	// Bucket в пути, а не в имени хоста; нужно для MinIO
+	PathStyle bool `mapstructure:"S3_PATH_STYLE"`
+}
+
+// Настройки трассировки OpenTelemetry
+type Tracing struct {
+	// none, otlp или stdout (для локальной разработки)
+	Exporter string `mapstructure:"TRACING_EXPORTER"`
+	// Адрес OTLP/HTTP коллектора, например http://localhost:4318
+	Endpoint    string  `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	Insecure    bool    `mapstructure:"TRACING_OTLP_INSECURE"`
+	ServiceName string  `mapstructure:"TRACING_SERVICE_NAME"`
+	SampleRatio float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+}
+
+// Настройки ограничения частоты запросов
+type RateLimit struct {
+	Enabled bool `mapstructure:"RATE_LIMIT_ENABLED"`
+	// memory - в памяти процесса, redis - общий лимит для всех экземпляров
+	Store string `mapstructure:"RATE_LIMIT_STORE"`
+	// Лимиты по группам маршрутов: "auth:10/1m,catalog:300/1m,default:120/1m"
+	Rules string `mapstructure:"RATE_LIMIT_RULES"`
+}
+
+type Redis struct {
+	Addr     string `mapstructure:"REDIS_ADDR"`
+	Password string `mapstructure:"REDIS_PASSWORD"`
+	DB       int    `mapstructure:"REDIS_DB"`
 }
 
 // Функция для загрузки конфигурации из файла .env или переменных окружения
@@ -42,6 +221,79 @@ func LoadConfig() (*Config, error) {
 	viper.AutomaticEnv()
 	viper.SetDefault("DATABASE_SSLMODE", "disable") // Установите значение по умолчанию
 	viper.SetDefault("SERVER_TIMEOUT", "15s")       // Добавлено значение по умолчанию
+	viper.SetDefault("SERVER_SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("SERVER_IDLE_TIMEOUT", "60s")
+	viper.SetDefault("SERVER_TRUSTED_PROXIES", []string{})
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("JWT_TTL", "24h")
+	viper.SetDefault("LOGIN_MAX_FAILED_ATTEMPTS", 5)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_IP_MAX_FAILED_ATTEMPTS", 20)
+	viper.SetDefault("LOGIN_IP_WINDOW", "15m")
+	viper.SetDefault("LOGIN_DELAY_BASE", "1s")
+	viper.SetDefault("LOGIN_DELAY_MAX", "30s")
+	viper.SetDefault("APP_BASE_URL", "http://localhost:3000")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "48h")
+	viper.SetDefault("MAILER_DRIVER", "file")
+	viper.SetDefault("MAILER_FROM", "VapeShop <no-reply@vapeshop.local>")
+	viper.SetDefault("MAILER_FILE_DIR", "mail")
+	viper.SetDefault("SMTP_HOST", "localhost")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("AGE_MINIMUM", 18)
+	viper.SetDefault("AGE_DOCUMENT_MAX_SIZE", 5<<20)
+	viper.SetDefault("PRIVACY_ERASURE_GRACE_PERIOD", "720h")
+	viper.SetDefault("PRIVACY_ERASURE_INTERVAL", "1h")
+	viper.SetDefault("PII_KEYS", "")
+	viper.SetDefault("PII_PRIMARY_KEY_ID", "")
+	viper.SetDefault("PII_KEYFILE", "")
+	viper.SetDefault("PII_BLIND_INDEX_KEY", "")
+	viper.SetDefault("PII_REENCRYPT_INTERVAL", "10m")
+	viper.SetDefault("CATALOG_PURGE_RETENTION", "2160h")
+	viper.SetDefault("CATALOG_PURGE_INTERVAL", "24h")
+	viper.SetDefault("IMPORT_MAX_SIZE", 20<<20)
+	viper.SetDefault("IMPORT_INTERVAL", "5s")
+	viper.SetDefault("IMAGES_STORE", "file")
+	viper.SetDefault("IMAGES_DIR", "images")
+	viper.SetDefault("IMAGES_MAX_SIZE", 10<<20)
+	viper.SetDefault("IMAGES_MAX_PIXELS", 40_000_000)
+	viper.SetDefault("IMAGES_THUMBNAIL_SIZES", []int{160, 480})
+	viper.SetDefault("IMAGES_CACHE_MAX_AGE", "8760h")
+	viper.SetDefault("S3_ENDPOINT", "http://localhost:9000")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("S3_BUCKET", "vapeshop-images")
+	viper.SetDefault("S3_ACCESS_KEY", "")
+	viper.SetDefault("S3_SECRET_KEY", "")
+	viper.SetDefault("S3_PATH_STYLE", true)
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_OTLP_ENDPOINT", "http://localhost:4318")
+	viper.SetDefault("TRACING_OTLP_INSECURE", true)
+	viper.SetDefault("TRACING_SERVICE_NAME", "vapeshop-client-api")
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("RATE_LIMIT_ENABLED", true)
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("RATE_LIMIT_RULES", "auth:10/1m,catalog:300/1m,purchases:60/1m,default:120/1m")
+	viper.SetDefault("REDIS_ADDR", "localhost:6379")
+	viper.SetDefault("REDIS_PASSWORD", "")
+	viper.SetDefault("REDIS_DB", 0)
+	viper.SetDefault("SERVER_PORT", 8080)
+	viper.SetDefault("CORS_ORIGINS", []string{"*"})
+	viper.SetDefault("CORS_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
+	viper.SetDefault("CORS_HEADERS", []string{"Content-Type", "Authorization"})
+	viper.SetDefault("CORS_EXPOSED_HEADERS", []string{})
+	viper.SetDefault("CORS_ALLOW_CREDENTIALS", false)
+	viper.SetDefault("CORS_MAX_AGE", "12h")
+	viper.SetDefault("DATABASE_SSLROOTCERT", "")
+	viper.SetDefault("DATABASE_CONNECT_TIMEOUT", "5s")
+	viper.SetDefault("DATABASE_APPLICATION_NAME", "vapeshop-client-api")
+	viper.SetDefault("DATABASE_AUTO_MIGRATE", true)
+	viper.SetDefault("DATABASE_SLOW_QUERY_THRESHOLD", "200ms")
+	viper.SetDefault("DATABASE_MAX_OPEN_CONNS", 25)
+	viper.SetDefault("DATABASE_MAX_IDLE_CONNS", 25)
+	viper.SetDefault("DATABASE_CONN_MAX_LIFETIME", "30m")
+	viper.SetDefault("DATABASE_CONN_MAX_IDLE_TIME", "5m")
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("Ошибка чтения конфигурации: %w", err)
@@ -51,7 +303,9 @@ func LoadConfig() (*Config, error) {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("Ошибка разбора конфигурации: %w", err)
 	}
-	fmt.Printf("Загруженная конфигурация: %+v\n", cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
 
@@ -63,6 +317,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("неверный порт сервера: %d", c.ServerPort)
 	}
 
+	// Cors отражает Origin запроса, поэтому "*" с учетными данными открыл бы
+	// доступ к ответам с cookie и авторизацией любому сайту
+	if c.CORS.AllowCredentials {
+		for _, v := range c.CORS.Origins {
+			for _, origin := range strings.Split(v, ",") {
+				if strings.Trim(strings.TrimSpace(origin), `[]"' `) == "*" {
+					return errors.New("CORS_ORIGINS=* нельзя использовать вместе с CORS_ALLOW_CREDENTIALS=true: укажите список разрешенных источников")
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -75,7 +341,7 @@ func GetConfigValue(key string, v interface{}) error {
 func GetConfigString(key string) string {
 	var value string
 	if err := GetConfigValue(key, &value); err != nil {
-		fmt.Println("Ошибка получения значения конфигурации:", err)
+		slog.Warn("Ошибка получения значения конфигурации", slog.String("key", key), slog.Any("error", err))
 	}
 	return value
 }
@@ -84,7 +350,7 @@ func GetConfigString(key string) string {
 func GetConfigInt(key string) int {
 	var value int
 	if err := GetConfigValue(key, &value); err != nil {
-		fmt.Println("Ошибка получения значения конфигурации:", err)
+		slog.Warn("Ошибка получения значения конфигурации", slog.String("key", key), slog.Any("error", err))
 		return 0
 	}
 	return value
@@ -94,7 +360,7 @@ func GetConfigInt(key string) int {
 func GetConfigBool(key string) bool {
 	var value bool
 	if err := GetConfigValue(key, &value); err != nil {
-		fmt.Println("Ошибка получения значения конфигурации:", err)
+		slog.Warn("Ошибка получения значения конфигурации", slog.String("key", key), slog.Any("error", err))
 		return false
 	}
 	return value
@@ -104,7 +370,7 @@ func GetConfigBool(key string) bool {
 func GetConfigDuration(key string) time.Duration {
 	var value time.Duration
 	if err := GetConfigValue(key, &value); err != nil {
-		fmt.Println("Ошибка получения значения конфигурации:", err)
+		slog.Warn("Ошибка получения значения конфигурации", slog.String("key", key), slog.Any("error", err))
 		return 0
 	}
 	return value
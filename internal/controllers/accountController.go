@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/mailer"
+	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AccountController - контроллер сброса пароля и подтверждения email.
+type AccountController struct {
+	accountService services.AccountService
+	validate       *validator.Validate
+}
+
+// NewAccountController - функция для создания нового контроллера учетных записей.
+func NewAccountController(accountService services.AccountService) *AccountController {
+	return &AccountController{
+		accountService: accountService,
+		validate:       validator.New(),
+	}
+}
+
+// ForgotPasswordHandler - обработчик запроса ссылки для сброса пароля.
+// Ответ не зависит от того, зарегистрирован ли email.
+func (c *AccountController) ForgotPasswordHandler(ctx *gin.Context) {
+	var req services.ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locale := mailer.Locale(ctx.GetHeader("Accept-Language"))
+	if err := c.accountService.ForgotPassword(ctx, req.Email, locale); err != nil {
+		// Ошибка только логируется: по ответу нельзя определить наличие учетной записи
+		logger.FromContext(ctx).ErrorContext(ctx, "Ошибка отправки ссылки для сброса пароля", slog.Any("error", err))
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "Если email зарегистрирован, на него отправлена ссылка для сброса пароля"})
+}
+
+// ResetPasswordHandler - обработчик установки нового пароля по токену из письма.
+func (c *AccountController) ResetPasswordHandler(ctx *gin.Context) {
+	var req services.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.accountService.ResetPassword(ctx, req); err != nil {
+		if errors.Is(err, services.ErrInvalidAccountToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Пароль успешно изменен"})
+}
+
+// SendEmailVerificationHandler - обработчик повторной отправки письма для подтверждения email.
+func (c *AccountController) SendEmailVerificationHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+	locale := mailer.Locale(ctx.GetHeader("Accept-Language"))
+
+	if err := c.accountService.SendEmailVerification(ctx, customerID, locale); err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCustomerNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "Письмо для подтверждения email отправлено"})
+}
+
+// VerifyEmailHandler - обработчик подтверждения email по токену из письма.
+func (c *AccountController) VerifyEmailHandler(ctx *gin.Context) {
+	var req services.VerifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.accountService.VerifyEmail(ctx, req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidAccountToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Email подтвержден"})
+}
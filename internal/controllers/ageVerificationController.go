@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Допустимые форматы сканов документов; тип определяется по содержимому файла
+var allowedDocumentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// AgeVerificationController - контроллер проверки возраста клиентов.
+type AgeVerificationController struct {
+	ageVerificationService services.AgeVerificationService
+	validate               *validator.Validate
+	// Максимальный размер загружаемого документа в байтах
+	maxDocumentSize int64
+}
+
+// NewAgeVerificationController - функция для создания нового контроллера проверки возраста.
+func NewAgeVerificationController(ageVerificationService services.AgeVerificationService, maxDocumentSize int64) *AgeVerificationController {
+	return &AgeVerificationController{
+		ageVerificationService: ageVerificationService,
+		validate:               validator.New(),
+		maxDocumentSize:        maxDocumentSize,
+	}
+}
+
+// GetAgeVerificationHandler - обработчик запроса статуса проверки возраста текущего клиента.
+func (c *AgeVerificationController) GetAgeVerificationHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+
+	verification, err := c.ageVerificationService.GetAgeVerification(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, verification)
+}
+
+// SubmitDocumentHandler - обработчик загрузки документа, удостоверяющего личность (multipart/form-data).
+func (c *AgeVerificationController) SubmitDocumentHandler(ctx *gin.Context) {
+	// Запас на остальные поля формы
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxDocumentSize+64<<10)
+
+	var req services.SubmitDocumentRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		c.bindError(ctx, err)
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		c.bindError(ctx, err)
+		return
+	}
+	if header.Size > c.maxDocumentSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("размер файла превышает %d байт", c.maxDocumentSize)})
+		return
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	if !allowedDocumentTypes[contentType] {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "поддерживаются только JPEG, PNG и PDF"})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	doc, err := c.ageVerificationService.SubmitDocument(ctx, customerID, req, services.IDDocumentFile{
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Content:     content,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAlreadyAgeVerified):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCustomerNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusCreated, doc)
+}
+
+// GetDocumentsHandler - обработчик запроса администратора на получение списка документов.
+func (c *AgeVerificationController) GetDocumentsHandler(ctx *gin.Context) {
+	filter := services.IDDocumentFilter{
+		Status: ctx.Query("status"),
+	}
+
+	if v := ctx.Query("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый customer_id"})
+			return
+		}
+		filter.CustomerID = id
+	}
+	if v := ctx.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	documents, err := c.ageVerificationService.GetDocuments(ctx, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, documents)
+}
+
+// GetDocumentFileHandler - обработчик запроса администратора на получение файла документа.
+func (c *AgeVerificationController) GetDocumentFileHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	file, err := c.ageVerificationService.GetDocumentFile(ctx, id)
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Документы содержат персональные данные: не кэшируем и не отображаем в браузере
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.FileName}))
+	ctx.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
+// ApproveDocumentHandler - обработчик подтверждения документа администратором.
+func (c *AgeVerificationController) ApproveDocumentHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var req services.ApproveDocumentRequest
+	// Тело запроса необязательно
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := middleware.CustomerID(ctx)
+	if err := c.ageVerificationService.ApproveDocument(ctx, id, adminID, req); err != nil {
+		c.reviewError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Документ подтвержден"})
+}
+
+// RejectDocumentHandler - обработчик отклонения документа администратором.
+func (c *AgeVerificationController) RejectDocumentHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var req services.RejectDocumentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := middleware.CustomerID(ctx)
+	if err := c.ageVerificationService.RejectDocument(ctx, id, adminID, req); err != nil {
+		c.reviewError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Документ отклонен"})
+}
+
+func (c *AgeVerificationController) reviewError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDocumentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentAlreadyReviewed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (c *AgeVerificationController) bindError(ctx *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("размер файла превышает %d байт", c.maxDocumentSize)})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AttributeController - контроллер схем характеристик товаров категорий.
+type AttributeController struct {
+	attributeService services.AttributeService
+	validate         *validator.Validate
+}
+
+// NewAttributeController - функция для создания нового контроллера характеристик.
+func NewAttributeController(attributeService services.AttributeService) *AttributeController {
+	return &AttributeController{
+		attributeService: attributeService,
+		validate:         validator.New(),
+	}
+}
+
+// GetCategoryAttributesHandler - обработчик запроса характеристик категории,
+// включая унаследованные от родительских категорий.
+func (c *AttributeController) GetCategoryAttributesHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	definitions, err := c.attributeService.GetCategoryAttributes(ctx, id)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, definitions)
+}
+
+// SaveCategoryAttributesHandler - обработчик запроса на замену собственных характеристик категории.
+func (c *AttributeController) SaveCategoryAttributesHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var definitions []services.AttributeDefinition
+	if err := ctx.ShouldBindJSON(&definitions); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.validate.Var(definitions, "dive"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := c.attributeService.SaveCategoryAttributes(ctx, id, definitions)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, saved)
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса характеристик.
+func (c *AttributeController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, new(*services.AttributeError)):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
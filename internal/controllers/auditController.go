@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditController - контроллер просмотра журнала аудита.
+type AuditController struct {
+	auditService services.AuditService
+}
+
+// NewAuditController - функция для создания нового контроллера журнала аудита.
+func NewAuditController(auditService services.AuditService) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
+
+// GetAuditLogHandler - обработчик запроса журнала аудита с фильтрами по сущности,
+// автору и периоду (from включительно, to не включительно, RFC 3339).
+func (c *AuditController) GetAuditLogHandler(ctx *gin.Context) {
+	filter := services.AuditFilter{
+		EntityType: ctx.Query("entity_type"),
+	}
+
+	if v := ctx.Query("entity_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый entity_id"})
+			return
+		}
+		filter.EntityID = id
+	}
+	if v := ctx.Query("actor_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый actor_id"})
+			return
+		}
+		filter.ActorID = id
+	}
+	if v := ctx.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый from, ожидается RFC 3339"})
+			return
+		}
+		filter.From = t
+	}
+	if v := ctx.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый to, ожидается RFC 3339"})
+			return
+		}
+		filter.To = t
+	}
+	if v := ctx.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := c.auditService.GetAuditLog(ctx, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AuthController - контроллер входа клиентов и управления блокировками.
+type AuthController struct {
+	authService services.AuthService
+	validate    *validator.Validate
+}
+
+// NewAuthController - функция для создания нового контроллера авторизации.
+func NewAuthController(authService services.AuthService) *AuthController {
+	return &AuthController{
+		authService: authService,
+		validate:    validator.New(),
+	}
+}
+
+// LoginHandler - обработчик запроса на вход по email и паролю.
+func (c *AuthController) LoginHandler(ctx *gin.Context) {
+	var req services.LoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := c.authService.Login(ctx, req, ctx.ClientIP())
+	if err != nil {
+		var throttled *services.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidCredentials):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+// UnlockCustomerHandler - обработчик запроса администратора на снятие блокировки входа.
+func (c *AuthController) UnlockCustomerHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	adminID, _ := middleware.CustomerID(ctx)
+	if err := c.authService.UnlockCustomer(ctx, id, adminID, ctx.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Учетная запись разблокирована"})
+}
+
+// GetSecurityEventsHandler - обработчик запроса журнала подозрительной активности.
+func (c *AuthController) GetSecurityEventsHandler(ctx *gin.Context) {
+	filter := services.SecurityEventFilter{
+		IP:        ctx.Query("ip"),
+		EventType: ctx.Query("event_type"),
+	}
+
+	if v := ctx.Query("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый customer_id"})
+			return
+		}
+		filter.CustomerID = id
+	}
+	if v := ctx.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := c.authService.GetSecurityEvents(ctx, filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, events)
+}
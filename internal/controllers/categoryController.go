@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"VapeShop-ClientAPI/internal/auth"
+	"VapeShop-ClientAPI/internal/middleware"
 	"VapeShop-ClientAPI/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -23,7 +26,13 @@ func NewCategoryController(categoryService services.CategoryService) *CategoryCo
 }
 
 func (c *CategoryController) GetCategoriesHandler(ctx *gin.Context) {
-	categories, err := c.categoryService.GetAllCategories(ctx)
+	var filter services.CategoryFilter
+	var ok bool
+	if filter.IncludeDeleted, ok = includeDeleted(ctx); !ok {
+		return
+	}
+
+	categories, err := c.categoryService.GetAllCategories(ctx, filter)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -31,6 +40,16 @@ func (c *CategoryController) GetCategoriesHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, categories)
 }
 
+// GetCategoryTreeHandler - обработчик запроса дерева категорий.
+func (c *CategoryController) GetCategoryTreeHandler(ctx *gin.Context) {
+	tree, err := c.categoryService.GetCategoryTree(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, tree)
+}
+
 func (c *CategoryController) GetCategoryByIDHandler(ctx *gin.Context) {
 	id := ctx.Param("id") // Получаем ID из параметров URL
 
@@ -62,7 +81,7 @@ func (c *CategoryController) CreateCategoriesHandler(ctx *gin.Context) {
 
 	newCategory, err := c.categoryService.CreateCategory(ctx, category)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, newCategory)
@@ -99,7 +118,7 @@ func (c *CategoryController) UpdateCategoryHandler(ctx *gin.Context) {
 	// Обновление категории через сервис
 	updatedCategory, err := c.categoryService.UpdateCategory(ctx.Request.Context(), category)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 
@@ -121,3 +140,52 @@ func (c *CategoryController) DeleteCategoryHandler(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
 }
+
+// RestoreCategoryHandler - обработчик запроса на восстановление удаленной категории.
+func (c *CategoryController) RestoreCategoryHandler(ctx *gin.Context) {
+	category, err := c.categoryService.RestoreCategory(ctx, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "удаленная категория не найдена"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, category)
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса категорий.
+func (c *CategoryController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrParentCategoryNotFound),
+		errors.Is(err, services.ErrCategoryCycle),
+		errors.Is(err, services.ErrInvalidSlug):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCategorySlugTaken):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// includeDeleted - разбор параметра include_deleted. Удаленные записи видит только
+// администратор; при ошибке ответ уже отправлен и возвращается false.
+func includeDeleted(ctx *gin.Context) (bool, bool) {
+	v := ctx.Query("include_deleted")
+	if v == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый include_deleted"})
+		return false, false
+	}
+	if include && middleware.Role(ctx) != auth.RoleAdmin {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "недостаточно прав"})
+		return false, false
+	}
+	return include, true
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// CustomerController - контроллер профиля и адресной книги текущего клиента.
+type CustomerController struct {
+	customerService services.CustomerService
+	validate        *validator.Validate
+}
+
+// NewCustomerController - функция для создания нового контроллера клиентов.
+func NewCustomerController(customerService services.CustomerService) *CustomerController {
+	return &CustomerController{
+		customerService: customerService,
+		validate:        validator.New(),
+	}
+}
+
+// GetProfileHandler - обработчик запроса профиля текущего клиента.
+func (c *CustomerController) GetProfileHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+
+	profile, err := c.customerService.GetProfile(ctx, customerID)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfileHandler - обработчик частичного обновления профиля.
+func (c *CustomerController) UpdateProfileHandler(ctx *gin.Context) {
+	var req services.UpdateProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	profile, err := c.customerService.UpdateProfile(ctx, customerID, req)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, profile)
+}
+
+// GetAddressesHandler - обработчик запроса адресной книги.
+func (c *CustomerController) GetAddressesHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+
+	addresses, err := c.customerService.GetAddresses(ctx, customerID)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, addresses)
+}
+
+// GetAddressHandler - обработчик запроса адреса по ID.
+func (c *CustomerController) GetAddressHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	address, err := c.customerService.GetAddress(ctx, customerID, id)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, address)
+}
+
+// CreateAddressHandler - обработчик добавления адреса.
+func (c *CustomerController) CreateAddressHandler(ctx *gin.Context) {
+	var address services.Address
+	if err := ctx.ShouldBindJSON(&address); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(address); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	newAddress, err := c.customerService.CreateAddress(ctx, customerID, address)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newAddress)
+}
+
+// UpdateAddressHandler - обработчик замены адреса.
+func (c *CustomerController) UpdateAddressHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var address services.Address
+	if err := ctx.ShouldBindJSON(&address); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.validate.Struct(address); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address.ID = id
+	customerID, _ := middleware.CustomerID(ctx)
+	updatedAddress, err := c.customerService.UpdateAddress(ctx, customerID, address)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, updatedAddress)
+}
+
+// DeleteAddressHandler - обработчик удаления адреса.
+func (c *CustomerController) DeleteAddressHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	if err := c.customerService.DeleteAddress(ctx, customerID, id); err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Адрес удален"})
+}
+
+// SetDefaultAddressHandler - обработчик назначения адреса по умолчанию.
+func (c *CustomerController) SetDefaultAddressHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	address, err := c.customerService.SetDefaultAddress(ctx, customerID, id)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, address)
+}
+
+func (c *CustomerController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCustomerNotFound), errors.Is(err, services.ErrAddressNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
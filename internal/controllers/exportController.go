@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/services"
+	"VapeShop-ClientAPI/internal/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportMediaTypes - форматы выгрузки по типу из заголовка Accept.
+var exportMediaTypes = map[string]string{
+	"text/csv": spreadsheet.FormatCSV,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": spreadsheet.FormatXLSX,
+	"application/x-ndjson": spreadsheet.FormatJSONLines,
+	"application/jsonl":    spreadsheet.FormatJSONLines,
+}
+
+// ExportController - контроллер выгрузок каталога и покупок.
+type ExportController struct {
+	exportService services.ExportService
+}
+
+// NewExportController - функция для создания нового контроллера выгрузок.
+func NewExportController(exportService services.ExportService) *ExportController {
+	return &ExportController{
+		exportService: exportService,
+	}
+}
+
+// ExportProductsHandler - обработчик выгрузки товаров. Принимает те же фильтры,
+// что и список продуктов.
+func (c *ExportController) ExportProductsHandler(ctx *gin.Context) {
+	format, ok := exportFormat(ctx)
+	if !ok {
+		return
+	}
+	filter, ok := productFilter(ctx)
+	if !ok {
+		return
+	}
+
+	c.stream(ctx, "products", format, func(w spreadsheet.Writer) error {
+		return c.exportService.ExportProducts(ctx, filter, w)
+	})
+}
+
+// ExportPurchasesHandler - обработчик выгрузки покупок за период (from, to)
+// по магазину (store_id) и последнему статусу доставки (status).
+func (c *ExportController) ExportPurchasesHandler(ctx *gin.Context) {
+	format, ok := exportFormat(ctx)
+	if !ok {
+		return
+	}
+
+	var filter services.PurchaseExportFilter
+	var err error
+	if filter.From, err = exportDate(ctx.Query("from"), false); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый from: ожидается дата YYYY-MM-DD или время RFC 3339"})
+		return
+	}
+	if filter.To, err = exportDate(ctx.Query("to"), true); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый to: ожидается дата YYYY-MM-DD или время RFC 3339"})
+		return
+	}
+	if v := ctx.Query("store_id"); v != "" {
+		if filter.StoreID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый store_id"})
+			return
+		}
+	}
+	filter.Status = ctx.Query("status")
+
+	c.stream(ctx, "purchases", format, func(w spreadsheet.Writer) error {
+		return c.exportService.ExportPurchases(ctx, filter, w)
+	})
+}
+
+// stream - отправка выгрузки в ответ по мере чтения строк. Пока ничего не отправлено,
+// ошибка возвращается обычным ответом; после начала передачи соединение обрывается,
+// чтобы клиент не принял неполный файл за целый.
+func (c *ExportController) stream(ctx *gin.Context, name, format string, export func(w spreadsheet.Writer) error) {
+	// Выгрузка может идти дольше общего таймаута записи ответа
+	if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logger.FromContext(ctx).Warn("не удалось снять таймаут записи выгрузки", "error", err)
+	}
+
+	ctx.Header("Content-Type", spreadsheet.ContentTypes[format])
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, format))
+	w, err := spreadsheet.NewWriter(format, ctx.Writer)
+	if err == nil {
+		err = export(w)
+	}
+	if err == nil {
+		return
+	}
+
+	if !ctx.Writer.Written() {
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		c.error(ctx, err)
+		return
+	}
+	logger.FromContext(ctx).Error("выгрузка прервана", "export", name, "error", err)
+	panic(http.ErrAbortHandler)
+}
+
+// exportFormat - формат выгрузки из параметра format или заголовка Accept;
+// по умолчанию CSV. При ошибке ответ уже отправлен и возвращается false.
+func exportFormat(ctx *gin.Context) (string, bool) {
+	if v := ctx.Query("format"); v != "" {
+		if _, ok := spreadsheet.ContentTypes[v]; !ok {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый format: ожидается csv, xlsx или jsonl"})
+			return "", false
+		}
+		return v, true
+	}
+
+	accept := ctx.GetHeader("Accept")
+	if accept == "" {
+		return spreadsheet.FormatCSV, true
+	}
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		if format, ok := exportMediaTypes[mediaType]; ok {
+			return format, true
+		}
+		if mediaType == "*/*" || mediaType == "text/*" {
+			return spreadsheet.FormatCSV, true
+		}
+	}
+	ctx.JSON(http.StatusNotAcceptable, gin.H{"error": "поддерживаются форматы text/csv, XLSX и application/x-ndjson"})
+	return "", false
+}
+
+// exportDate - граница периода: время RFC 3339 или дата. Дата в конце периода
+// включается целиком, поэтому заменяется началом следующего дня.
+func exportDate(v string, end bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса выгрузок.
+func (c *ExportController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.As(err, new(*services.AttributeError)):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
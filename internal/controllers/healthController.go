@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/services"
+	"VapeShop-ClientAPI/internal/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController - контроллер для проверок состояния сервиса оркестратором.
+type HealthController struct {
+	healthService services.HealthService
+}
+
+// NewHealthController - функция для создания нового контроллера проверок состояния.
+func NewHealthController(healthService services.HealthService) *HealthController {
+	return &HealthController{
+		healthService: healthService,
+	}
+}
+
+// LivenessHandler - процесс жив и обрабатывает запросы.
+func (c *HealthController) LivenessHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": services.HealthStatusOK})
+}
+
+// ReadinessHandler - сервис готов принимать трафик: база данных доступна, миграции применены.
+// Ответ содержит только состояния зависимостей, причины недоступности пишутся в лог.
+func (c *HealthController) ReadinessHandler(ctx *gin.Context) {
+	reqCtx := ctx.Request.Context()
+	report := c.healthService.Readiness(reqCtx)
+	if report.Status != services.HealthStatusOK {
+		for name, dep := range report.Dependencies {
+			if dep.Status != services.HealthStatusOK {
+				logger.FromContext(reqCtx).WarnContext(reqCtx, "Зависимость недоступна",
+					slog.String("dependency", name),
+					slog.String("error", dep.Error),
+					slog.Any("details", dep.Details))
+			}
+		}
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// VersionHandler - информация о сборке.
+func (c *HealthController) VersionHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, version.Get())
+}
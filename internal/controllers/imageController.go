@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxImagesPerUpload - максимальное количество файлов в одном запросе на загрузку.
+const maxImagesPerUpload = 10
+
+// Допустимые форматы изображений товаров; тип определяется по содержимому файла
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// ImageController - контроллер изображений товаров.
+type ImageController struct {
+	imageService services.ImageService
+	validate     *validator.Validate
+	// Максимальный размер одного файла в байтах
+	maxSize int64
+	// Время кэширования файлов клиентами
+	cacheMaxAge time.Duration
+}
+
+// NewImageController - функция для создания нового контроллера изображений товаров.
+func NewImageController(imageService services.ImageService, maxSize int64, cacheMaxAge time.Duration) *ImageController {
+	return &ImageController{
+		imageService: imageService,
+		validate:     validator.New(),
+		maxSize:      maxSize,
+		cacheMaxAge:  cacheMaxAge,
+	}
+}
+
+// GetImagesHandler - обработчик запроса изображений товара.
+func (c *ImageController) GetImagesHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	images, err := c.imageService.GetProductImages(ctx, productID)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, images)
+}
+
+// UploadImagesHandler - обработчик загрузки изображений товара (multipart/form-data,
+// один или несколько файлов в поле file). Изображения добавляются в конец в порядке файлов.
+func (c *ImageController) UploadImagesHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	// Запас на заголовки частей формы
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxSize*maxImagesPerUpload+64<<10)
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		c.bindError(ctx, err)
+		return
+	}
+	headers := form.File["file"]
+	if len(headers) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "не передано ни одного файла в поле file"})
+		return
+	}
+	if len(headers) > maxImagesPerUpload {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("за один запрос можно загрузить не больше %d файлов", maxImagesPerUpload)})
+		return
+	}
+
+	uploads := make([]services.ImageUpload, 0, len(headers))
+	for _, header := range headers {
+		name := filepath.Base(header.Filename)
+		if header.Size > c.maxSize {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s: размер файла превышает %d байт", name, c.maxSize)})
+			return
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+		if !allowedImageTypes[contentType] {
+			ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("%s: поддерживаются только JPEG и PNG", name)})
+			return
+		}
+		uploads = append(uploads, services.ImageUpload{FileName: name, Content: content})
+	}
+
+	images, err := c.imageService.UploadImages(ctx, productID, uploads)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, images)
+}
+
+// ReorderImagesHandler - обработчик запроса на изменение порядка изображений товара.
+func (c *ImageController) ReorderImagesHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var req services.ImageOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	images, err := c.imageService.ReorderImages(ctx, productID, req.IDs)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, images)
+}
+
+// DeleteImageHandler - обработчик запроса на удаление изображения товара.
+func (c *ImageController) DeleteImageHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+	imageID, err := strconv.ParseInt(ctx.Param("image_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID изображения"})
+		return
+	}
+
+	if err := c.imageService.DeleteImage(ctx, productID, imageID); err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Изображение удалено"})
+}
+
+// GetImageFileHandler - обработчик запроса файла изображения или уменьшенной копии
+// (параметр size). Файлы не меняются, поэтому кэшируются клиентами на cacheMaxAge.
+func (c *ImageController) GetImageFileHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+	imageID, err := strconv.ParseInt(ctx.Param("image_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID изображения"})
+		return
+	}
+	size := 0
+	if v := ctx.Query("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый size"})
+			return
+		}
+	}
+
+	file, err := c.imageService.GetImageFile(ctx, productID, imageID, size)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int64(c.cacheMaxAge.Seconds())))
+	ctx.Header("ETag", file.ETag)
+	if etagMatch(ctx.GetHeader("If-None-Match"), file.ETag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	obj, err := file.Open(ctx)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	defer obj.Body.Close()
+	ctx.DataFromReader(http.StatusOK, obj.Size, file.ContentType, obj.Body, nil)
+}
+
+// etagMatch - совпадение ETag с одним из значений заголовка If-None-Match.
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса изображений.
+func (c *ImageController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrImageNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidImage):
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrImageTooLarge):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidImageOrder):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (c *ImageController) bindError(ctx *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("размер запроса превышает %d байт", tooLarge.Limit)})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportController - контроллер импорта товаров из таблиц.
+type ImportController struct {
+	importService services.ImportService
+	// Максимальный размер загружаемого файла в байтах
+	maxSize int64
+}
+
+// NewImportController - функция для создания нового контроллера импорта товаров.
+func NewImportController(importService services.ImportService, maxSize int64) *ImportController {
+	return &ImportController{
+		importService: importService,
+		maxSize:       maxSize,
+	}
+}
+
+// ImportProductsHandler - обработчик загрузки файла CSV или XLSX (multipart/form-data, поле file;
+// сопоставление столбцов - JSON в поле mapping). Создается задание импорта; с dry_run=true
+// задание только проверяет файл, и отчет доступен по его ID.
+func (c *ImportController) ImportProductsHandler(ctx *gin.Context) {
+	dryRun := false
+	if v := ctx.Query("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый dry_run"})
+			return
+		}
+	}
+
+	// Запас на остальные поля формы
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxSize+64<<10)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		c.bindError(ctx, err)
+		return
+	}
+	if header.Size > c.maxSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("размер файла превышает %d байт", c.maxSize)})
+		return
+	}
+
+	file := services.ImportFile{FileName: filepath.Base(header.Filename)}
+	if v := ctx.PostForm("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &file.Mapping); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "mapping должен быть JSON-объектом {\"поле\": \"заголовок столбца\"}"})
+			return
+		}
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	if file.Content, err = io.ReadAll(f); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := c.importService.StartImport(ctx, file, dryRun)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/v1/admin/products/import/%d", job.ID))
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetImportJobHandler - обработчик запроса состояния задания импорта.
+func (c *ImportController) GetImportJobHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	job, err := c.importService.GetImportJob(ctx, id)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса импорта.
+func (c *ImportController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrImportJobNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidImport):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (c *ImportController) bindError(ctx *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("размер файла превышает %d байт", c.maxSize)})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyController - контроллер выгрузки и удаления персональных данных.
+type PrivacyController struct {
+	privacyService services.PrivacyService
+}
+
+// NewPrivacyController - функция для создания нового контроллера персональных данных.
+func NewPrivacyController(privacyService services.PrivacyService) *PrivacyController {
+	return &PrivacyController{
+		privacyService: privacyService,
+	}
+}
+
+// ExportDataHandler - обработчик выгрузки всех данных клиента в ZIP-архиве.
+func (c *PrivacyController) ExportDataHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+
+	// Архив собирается целиком, чтобы при ошибке вернуть корректный статус
+	var buf bytes.Buffer
+	if err := c.privacyService.ExportData(ctx, customerID, &buf); err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("vapeshop-data-%d-%s.zip", customerID, time.Now().Format("20060102"))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	ctx.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// RequestErasureHandler - обработчик запроса на удаление учетной записи.
+func (c *PrivacyController) RequestErasureHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+
+	req, err := c.privacyService.RequestErasure(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, req)
+}
+
+// CancelErasureHandler - обработчик отмены запроса на удаление учетной записи.
+func (c *PrivacyController) CancelErasureHandler(ctx *gin.Context) {
+	customerID, _ := middleware.CustomerID(ctx)
+
+	if err := c.privacyService.CancelErasure(ctx, customerID); err != nil {
+		if errors.Is(err, services.ErrErasureRequestNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Запрос на удаление данных отменен"})
+}
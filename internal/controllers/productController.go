@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 
 	"VapeShop-ClientAPI/internal/services"
 
@@ -25,15 +28,119 @@ func NewProductController(productService services.ProductService) *ProductContro
 }
 
 // GetProductsHandler - обработчик запроса на получение всех продуктов.
+// Параметр region скрывает товары, запрещенные к продаже в регионе,
+// category_id оставляет товары категории и ее подкатегорий, store_id - товары магазина,
+// attr.<code> и attr.<code>.min/max фильтруют по характеристикам,
+// sort=rating упорядочивает по рейтингу.
 func (c *ProductController) GetProductsHandler(ctx *gin.Context) {
-	products, err := c.productService.GetAllProducts(ctx)
+	filter, ok := productFilter(ctx)
+	if !ok {
+		return
+	}
+
+	products, err := c.productService.GetAllProducts(ctx, filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, products)
 }
 
+// GetProductFacetsHandler - обработчик запроса значений характеристик среди товаров,
+// подходящих под те же фильтры, что и список продуктов.
+func (c *ProductController) GetProductFacetsHandler(ctx *gin.Context) {
+	filter, ok := productFilter(ctx)
+	if !ok {
+		return
+	}
+
+	facets, err := c.productService.GetProductFacets(ctx, filter)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, facets)
+}
+
+// productFilter - разбор параметров фильтра каталога. Значения attr.<code>
+// перечисляются через запятую или повторением параметра; при ошибке ответ
+// уже отправлен и возвращается false.
+func productFilter(ctx *gin.Context) (services.ProductFilter, bool) {
+	filter := services.ProductFilter{
+		Region: ctx.Query("region"),
+	}
+	if v := ctx.Query("category_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый category_id"})
+			return filter, false
+		}
+		filter.CategoryID = id
+	}
+	if v := ctx.Query("store_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый store_id"})
+			return filter, false
+		}
+		filter.StoreID = id
+	}
+	switch v := ctx.Query("sort"); v {
+	case "", "id":
+	case services.ProductSortRating:
+		filter.Sort = v
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый sort: ожидается id или rating"})
+		return filter, false
+	}
+	var ok bool
+	if filter.IncludeDeleted, ok = includeDeleted(ctx); !ok {
+		return filter, false
+	}
+
+	byCode := make(map[string]*services.AttributeFilter)
+	for key, values := range ctx.Request.URL.Query() {
+		rest, found := strings.CutPrefix(key, "attr.")
+		if !found {
+			continue
+		}
+		code, bound, _ := strings.Cut(rest, ".")
+		f, exists := byCode[code]
+		if !exists {
+			f = &services.AttributeFilter{Code: code}
+			byCode[code] = f
+		}
+		switch bound {
+		case "":
+			for _, v := range values {
+				f.Values = append(f.Values, strings.Split(v, ",")...)
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимое значение " + key})
+				return filter, false
+			}
+			if bound == "min" {
+				f.Min = &n
+			} else {
+				f.Max = &n
+			}
+		default:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый параметр " + key})
+			return filter, false
+		}
+	}
+	for _, f := range byCode {
+		filter.Attributes = append(filter.Attributes, *f)
+	}
+	// Одинаковый текст запроса для одинаковых фильтров
+	sort.Slice(filter.Attributes, func(i, j int) bool {
+		return filter.Attributes[i].Code < filter.Attributes[j].Code
+	})
+	return filter, true
+}
+
 // GetProductByIDHandler - обработчик запроса на получение продукта по ID.
 func (c *ProductController) GetProductByIDHandler(ctx *gin.Context) {
 	id := ctx.Param("id")
@@ -66,7 +173,7 @@ func (c *ProductController) CreateProductHandler(ctx *gin.Context) {
 
 	newProduct, err := c.productService.CreateProduct(ctx, product)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, newProduct)
@@ -90,6 +197,10 @@ func (c *ProductController) UpdateProductHandler(ctx *gin.Context) {
 
 	updatedProduct, err := c.productService.UpdateProduct(ctx.Request.Context(), product)
 	if err != nil {
+		if errors.As(err, new(*services.AttributeError)) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -113,3 +224,29 @@ func (c *ProductController) DeleteProductHandler(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Продукт успешно удален"})
 }
+
+// RestoreProductHandler - обработчик запроса на восстановление удаленного продукта.
+func (c *ProductController) RestoreProductHandler(ctx *gin.Context) {
+	product, err := c.productService.RestoreProduct(ctx, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "удаленный продукт не найден"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, product)
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса продуктов.
+func (c *ProductController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.As(err, new(*services.AttributeError)):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSKUTaken):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
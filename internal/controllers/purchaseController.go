@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
+	"VapeShop-ClientAPI/internal/auth"
+	"VapeShop-ClientAPI/internal/middleware"
 	"VapeShop-ClientAPI/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -23,9 +26,15 @@ func NewPurchaseController(purchaseService services.PurchaseService) *PurchaseCo
 	}
 }
 
-// GetPurchasesHandler - обработчик запроса на получение всех покупок.
+// GetPurchasesHandler - обработчик запроса на получение покупок. Клиенту
+// возвращаются только его покупки, администратору - все.
 func (c *PurchaseController) GetPurchasesHandler(ctx *gin.Context) {
-	purchases, err := c.purchaseService.GetAllPurchases(ctx)
+	owner, ok := purchaseOwner(ctx)
+	if !ok {
+		return
+	}
+
+	purchases, err := c.purchaseService.GetAllPurchases(ctx, owner)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -41,9 +50,14 @@ func (c *PurchaseController) GetPurchaseByIDHandler(ctx *gin.Context) {
 		return
 	}
 
-	purchase, err := c.purchaseService.GetPurchaseByID(ctx, id)
+	owner, ok := purchaseOwner(ctx)
+	if !ok {
+		return
+	}
+
+	purchase, err := c.purchaseService.GetPurchaseByID(ctx, id, owner)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, purchase)
@@ -63,9 +77,15 @@ func (c *PurchaseController) CreatePurchaseHandler(ctx *gin.Context) {
 		return
 	}
 
+	// Покупка оформляется на клиента из токена, иначе проверку возраста
+	// можно было бы обойти, указав чужой customer_id
+	if customerID, ok := middleware.CustomerID(ctx); ok {
+		purchase.CustomerID = customerID
+	}
+
 	newPurchase, err := c.purchaseService.CreatePurchase(ctx, purchase)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, newPurchase)
@@ -84,9 +104,14 @@ func (c *PurchaseController) UpdatePurchaseHandler(ctx *gin.Context) {
 		return
 	}
 
-	updatedPurchase, err := c.purchaseService.UpdatePurchase(ctx, purchase) // Изменено: теперь получаем обновлённую покупку
+	owner, ok := purchaseOwner(ctx)
+	if !ok {
+		return
+	}
+
+	updatedPurchase, err := c.purchaseService.UpdatePurchase(ctx, purchase, owner) // Изменено: теперь получаем обновлённую покупку
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, updatedPurchase) // Отправляем обновлённую покупку
@@ -100,10 +125,49 @@ func (c *PurchaseController) DeletePurchaseHandler(ctx *gin.Context) {
 		return
 	}
 
-	err := c.purchaseService.DeletePurchase(ctx, id)
+	owner, ok := purchaseOwner(ctx)
+	if !ok {
+		return
+	}
+
+	err := c.purchaseService.DeletePurchase(ctx, id, owner)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.error(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"message": "Покупка успешно удалена"})
 }
+
+// error - ответ с кодом, соответствующим ошибке сервиса покупок.
+func (c *PurchaseController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOutOfStock):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAgeVerificationRequired), errors.Is(err, services.ErrUnderage):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.As(err, new(*services.RegionRestrictedError)):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPurchaseNotFound), errors.Is(err, services.ErrAddressNotFound),
+		errors.Is(err, services.ErrVariantNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeliveryRegionRequired), errors.Is(err, services.ErrVariantRequired):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// purchaseOwner - клиент, покупками которого ограничен запрос: для администратора 0
+// (все покупки), для остальных - клиент из токена. При ошибке ответ уже отправлен
+// и возвращается false.
+func purchaseOwner(ctx *gin.Context) (int64, bool) {
+	if middleware.Role(ctx) == auth.RoleAdmin {
+		return 0, true
+	}
+	customerID, ok := middleware.CustomerID(ctx)
+	if !ok || customerID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication Token"})
+		return 0, false
+	}
+	return customerID, true
+}
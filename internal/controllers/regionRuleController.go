@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegionRuleController - контроллер региональных ограничений продаж.
+type RegionRuleController struct {
+	regionRuleService services.RegionRuleService
+	validate          *validator.Validate
+}
+
+// NewRegionRuleController - функция для создания нового контроллера региональных ограничений.
+func NewRegionRuleController(regionRuleService services.RegionRuleService) *RegionRuleController {
+	return &RegionRuleController{
+		regionRuleService: regionRuleService,
+		validate:          validator.New(),
+	}
+}
+
+// GetRegionRulesHandler - обработчик запроса на получение правил всех регионов.
+func (c *RegionRuleController) GetRegionRulesHandler(ctx *gin.Context) {
+	rules, err := c.regionRuleService.GetRegionRules(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rules)
+}
+
+// GetRegionRuleHandler - обработчик запроса на получение правил региона.
+func (c *RegionRuleController) GetRegionRuleHandler(ctx *gin.Context) {
+	rule, err := c.regionRuleService.GetRegionRule(ctx, ctx.Param("region"))
+	if err != nil {
+		if errors.Is(err, services.ErrRegionRuleNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rule)
+}
+
+// SaveRegionRuleHandler - обработчик создания или замены правил региона.
+func (c *RegionRuleController) SaveRegionRuleHandler(ctx *gin.Context) {
+	var rule services.RegionRule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule.RegionCode = services.NormalizeRegion(ctx.Param("region"))
+	if rule.RegionCode == "" || len(rule.RegionCode) > 16 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый код региона"})
+		return
+	}
+
+	if err := c.validate.Struct(rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := c.regionRuleService.SaveRegionRule(ctx, rule)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, saved)
+}
+
+// DeleteRegionRuleHandler - обработчик удаления правил региона.
+func (c *RegionRuleController) DeleteRegionRuleHandler(ctx *gin.Context) {
+	if err := c.regionRuleService.DeleteRegionRule(ctx, ctx.Param("region")); err != nil {
+		if errors.Is(err, services.ErrRegionRuleNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Правила региона удалены"})
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// ReviewController - контроллер отзывов о товарах.
+type ReviewController struct {
+	reviewService services.ReviewService
+	validate      *validator.Validate
+}
+
+// NewReviewController - функция для создания нового контроллера отзывов.
+func NewReviewController(reviewService services.ReviewService) *ReviewController {
+	return &ReviewController{
+		reviewService: reviewService,
+		validate:      validator.New(),
+	}
+}
+
+// GetProductReviewsHandler - обработчик запроса одобренных отзывов о товаре.
+func (c *ReviewController) GetProductReviewsHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+	limit, offset, ok := reviewPage(ctx)
+	if !ok {
+		return
+	}
+
+	reviews, err := c.reviewService.GetProductReviews(ctx, productID, limit, offset)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, reviews)
+}
+
+// CreateReviewHandler - обработчик отправки отзыва о товаре. Отзыв появится
+// в каталоге после одобрения администратором.
+func (c *ReviewController) CreateReviewHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var req services.CreateReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, _ := middleware.CustomerID(ctx)
+	review, err := c.reviewService.CreateReview(ctx, productID, customerID, req)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, review)
+}
+
+// GetReviewsHandler - обработчик запроса администратора на получение отзывов
+// для модерации с фильтрами status и product_id.
+func (c *ReviewController) GetReviewsHandler(ctx *gin.Context) {
+	filter := services.ReviewFilter{
+		Status: ctx.Query("status"),
+	}
+	switch filter.Status {
+	case "", services.ReviewStatusPending, services.ReviewStatusApproved, services.ReviewStatusRejected:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый status"})
+		return
+	}
+	if v := ctx.Query("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый product_id"})
+			return
+		}
+		filter.ProductID = id
+	}
+	var ok bool
+	if filter.Limit, filter.Offset, ok = reviewPage(ctx); !ok {
+		return
+	}
+
+	reviews, err := c.reviewService.GetReviews(ctx, filter)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, reviews)
+}
+
+// ApproveReviewHandler - обработчик одобрения отзыва администратором.
+func (c *ReviewController) ApproveReviewHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	adminID, _ := middleware.CustomerID(ctx)
+	review, err := c.reviewService.ApproveReview(ctx, id, adminID)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, review)
+}
+
+// RejectReviewHandler - обработчик отклонения отзыва администратором.
+func (c *ReviewController) RejectReviewHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var req services.RejectReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.validate.Struct(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := middleware.CustomerID(ctx)
+	review, err := c.reviewService.RejectReview(ctx, id, adminID, req)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, review)
+}
+
+// DeleteReviewHandler - обработчик удаления отзыва администратором.
+func (c *ReviewController) DeleteReviewHandler(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	if err := c.reviewService.DeleteReview(ctx, id); err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Отзыв удален"})
+}
+
+// reviewPage - параметры limit и offset списка отзывов. При ошибке ответ
+// уже отправлен и возвращается false.
+func reviewPage(ctx *gin.Context) (int, int, bool) {
+	var limit, offset int
+	var err error
+	if v := ctx.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый limit"})
+			return 0, 0, false
+		}
+	}
+	if v := ctx.Query("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый offset"})
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса отзывов.
+func (c *ReviewController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrReviewNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReviewExists):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
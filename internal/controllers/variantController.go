@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"VapeShop-ClientAPI/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// VariantController - контроллер вариантов товаров.
+type VariantController struct {
+	variantService services.VariantService
+	validate       *validator.Validate
+}
+
+// NewVariantController - функция для создания нового контроллера вариантов товаров.
+func NewVariantController(variantService services.VariantService) *VariantController {
+	return &VariantController{
+		variantService: variantService,
+		validate:       validator.New(),
+	}
+}
+
+// GetVariantsHandler - обработчик запроса вариантов товара.
+func (c *VariantController) GetVariantsHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	variants, err := c.variantService.GetProductVariants(ctx, productID)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, variants)
+}
+
+// CreateVariantHandler - обработчик запроса на добавление варианта товара.
+func (c *VariantController) CreateVariantHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+
+	var variant services.ProductVariant
+	if err := ctx.ShouldBindJSON(&variant); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.validate.Struct(variant); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant.ProductID = productID
+	newVariant, err := c.variantService.CreateVariant(ctx, variant)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, newVariant)
+}
+
+// UpdateVariantHandler - обработчик запроса на изменение варианта товара.
+func (c *VariantController) UpdateVariantHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+	variantID, err := strconv.ParseInt(ctx.Param("variant_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID варианта"})
+		return
+	}
+
+	var variant services.ProductVariant
+	if err := ctx.ShouldBindJSON(&variant); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.validate.Struct(variant); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant.ID = variantID
+	variant.ProductID = productID
+	updatedVariant, err := c.variantService.UpdateVariant(ctx, variant)
+	if err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, updatedVariant)
+}
+
+// DeleteVariantHandler - обработчик запроса на удаление варианта товара.
+func (c *VariantController) DeleteVariantHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID"})
+		return
+	}
+	variantID, err := strconv.ParseInt(ctx.Param("variant_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "недопустимый ID варианта"})
+		return
+	}
+
+	if err := c.variantService.DeleteVariant(ctx, productID, variantID); err != nil {
+		c.error(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Вариант удален"})
+}
+
+// error - ответ с кодом, соответствующим ошибке сервиса вариантов.
+func (c *VariantController) error(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrVariantNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSKUTaken):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
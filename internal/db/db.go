@@ -4,33 +4,44 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 
 	"VapeShop-ClientAPI/internal/config"
+	"VapeShop-ClientAPI/internal/logger"
 )
 
 // Структура для хранения подключения к базе данных
 type DB struct {
 	*sql.DB
+	// Запросы дольше этого порога пишутся в лог; 0 отключает журналирование
+	slowQueryThreshold time.Duration
+}
+
+// Tx - транзакция с тем же журналированием запросов, что и у DB
+type Tx struct {
+	*sql.Tx
+	db *DB
 }
 
 // Создание нового подключения к базе данных
 func NewDB(cfg config.Database) (*DB, error) {
-	dsn := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable", cfg.User, cfg.Password, cfg.Name)
-	if cfg.Host != "" {
-		dsn += fmt.Sprintf(" host=%s", cfg.Host)
-	}
-	if cfg.Port != 0 {
-		dsn += fmt.Sprintf(" port=%d", cfg.Port)
-	}
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", BuildDSN(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("Ошибка подключения к базе данных: %w", err)
 	}
 
+	// Настройка пула соединений
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := db.PingContext(ctx); err != nil {
@@ -38,7 +49,50 @@ func NewDB(cfg config.Database) (*DB, error) {
 		return nil, fmt.Errorf("Ошибка ping к базе данных: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, slowQueryThreshold: cfg.SlowQueryThreshold}, nil
+}
+
+// BuildDSN - формирование строки подключения в формате key=value из конфигурации.
+// Пустые значения пропускаются, остальные экранируются.
+func BuildDSN(cfg config.Database) string {
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	params := [][2]string{
+		{"host", cfg.Host},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.Name},
+		{"sslmode", sslMode},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"application_name", cfg.ApplicationName},
+	}
+	if cfg.Port != 0 {
+		params = append(params, [2]string{"port", strconv.Itoa(cfg.Port)})
+	}
+	// connect_timeout задается в целых секундах; дробное значение округляется вверх,
+	// чтобы таймаут меньше секунды не превратился в бесконечное ожидание
+	if secs := int(math.Ceil(cfg.ConnectTimeout.Seconds())); secs > 0 {
+		params = append(params, [2]string{"connect_timeout", strconv.Itoa(secs)})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		parts = append(parts, p[0]+"="+quoteDSNValue(p[1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue - экранирование значения для DSN: значение заключается в одинарные
+// кавычки, а символы ' и \ экранируются обратной косой чертой.
+func quoteDSNValue(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(v) + "'"
 }
 
 // Закрытие подключения к базе данных
@@ -47,16 +101,94 @@ func (db *DB) Close() error {
 }
 
 // Метод для выполнения запроса
-func (db *DB) QueryContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error) {
-	return db.DB.QueryContext(ctx, sql, args...)
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	db.observe(ctx, query, start)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, span: span}, nil
 }
 
 // Метод для выполнения запроса, возвращающего одну строку
-func (db *DB) QueryRowContext(ctx context.Context, sql string, args ...interface{}) *sql.Row {
-	return db.DB.QueryRowContext(ctx, sql, args...)
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	db.observe(ctx, query, start)
+	endSpan(span, row.Err())
+	return row
 }
 
 // Метод для выполнения команды
-func (db *DB) ExecContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error) {
-	return db.DB.ExecContext(ctx, sql, args...)
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	db.observe(ctx, query, start)
+	recordRowsAffected(span, res, err)
+	endSpan(span, err)
+	return res, err
+}
+
+// Начало транзакции
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+// Метод для выполнения запроса в транзакции
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.db.observe(ctx, query, start)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, span: span}, nil
+}
+
+// Метод для выполнения запроса, возвращающего одну строку, в транзакции
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tx.db.observe(ctx, query, start)
+	endSpan(span, row.Err())
+	return row
+}
+
+// Метод для выполнения команды в транзакции
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.db.observe(ctx, query, start)
+	recordRowsAffected(span, res, err)
+	endSpan(span, err)
+	return res, err
+}
+
+// observe - журналирование медленных запросов. Аргументы запроса не логируются,
+// так как могут содержать персональные данные.
+func (db *DB) observe(ctx context.Context, query string, start time.Time) {
+	elapsed := time.Since(start)
+	if db.slowQueryThreshold <= 0 || elapsed < db.slowQueryThreshold {
+		return
+	}
+	logger.FromContext(ctx).WarnContext(ctx, "Медленный запрос к базе данных",
+		slog.String("query", compactQuery(query)),
+		slog.Int64("duration_ms", elapsed.Milliseconds()))
+}
+
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
 }
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"VapeShop-ClientAPI/internal/config"
+)
+
+func TestBuildDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Database
+		want string
+	}{
+		{
+			name: "пустые значения пропускаются, sslmode по умолчанию disable",
+			cfg:  config.Database{Host: "localhost", Name: "vapeshop"},
+			want: "host='localhost' dbname='vapeshop' sslmode='disable'",
+		},
+		{
+			name: "все параметры",
+			cfg: config.Database{
+				Host: "db", Port: 5433, User: "shop", Password: "secret", Name: "vapeshop",
+				SSLMode: "verify-full", SSLRootCert: "/etc/ssl/ca.pem", ApplicationName: "client-api",
+				ConnectTimeout: 5 * time.Second,
+			},
+			want: "host='db' user='shop' password='secret' dbname='vapeshop' sslmode='verify-full' " +
+				"sslrootcert='/etc/ssl/ca.pem' application_name='client-api' port='5433' connect_timeout='5'",
+		},
+		{
+			name: "кавычки, обратная косая черта и пробелы в пароле",
+			cfg:  config.Database{Password: `p a'ss\word=`},
+			want: `password='p a\'ss\\word=' sslmode='disable'`,
+		},
+		{
+			name: "connect_timeout округляется вверх до секунд",
+			cfg:  config.Database{ConnectTimeout: 2500 * time.Millisecond},
+			want: "sslmode='disable' connect_timeout='3'",
+		},
+		{
+			name: "connect_timeout меньше секунды не отключает таймаут",
+			cfg:  config.Database{ConnectTimeout: 500 * time.Millisecond},
+			want: "sslmode='disable' connect_timeout='1'",
+		},
+		{
+			name: "нулевой connect_timeout не задается",
+			want: "sslmode='disable'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildDSN(tt.cfg); got != tt.want {
+				t.Errorf("BuildDSN = %s, ожидалось %s", got, tt.want)
+			}
+		})
+	}
+}
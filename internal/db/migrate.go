@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Файлы миграций встраиваются в бинарник и применяются по порядку имени
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migration - одна миграция схемы базы данных.
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+// MigrationStatus - состояние миграций: примененные и ожидающие применения версии.
+type MigrationStatus struct {
+	Applied []string `json:"applied"`
+	Pending []string `json:"pending"`
+}
+
+// loadMigrations - чтение встроенных миграций, отсортированных по версии.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		body, err := migrationsFS.ReadFile("migrations/" + e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения миграции %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, Migration{
+			Version: strings.TrimSuffix(e.Name(), ".sql"),
+			SQL:     string(body),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable - создание служебной таблицы учета миграций.
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	_, err := db.DB.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version VARCHAR(255) PRIMARY KEY,
+            applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        )`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions - множество уже примененных версий миграций.
+func (db *DB) appliedVersions(ctx context.Context) (map[string]bool, error) {
+	rows, err := db.DB.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// Migrate - применение всех ожидающих миграций. Каждая миграция выполняется
+// в отдельной транзакции вместе с записью в schema_migrations.
+func (db *DB) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return err
+	}
+	applied, err := db.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		tx, err := db.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("ошибка начала транзакции миграции %s: %w", m.Version, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("ошибка применения миграции %s: %w", m.Version, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", m.Version); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("ошибка записи миграции %s: %w", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("ошибка фиксации миграции %s: %w", m.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrationStatus - получение списка примененных и ожидающих миграций.
+func (db *DB) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Проверка состояния не должна изменять схему, поэтому таблицу не создаем
+	var exists bool
+	err = db.DB.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_name = 'schema_migrations')").Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки таблицы schema_migrations: %w", err)
+	}
+	applied := map[string]bool{}
+	if exists {
+		if applied, err = db.appliedVersions(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	status := &MigrationStatus{Applied: []string{}, Pending: []string{}}
+	for _, m := range migrations {
+		if applied[m.Version] {
+			status.Applied = append(status.Applied, m.Version)
+		} else {
+			status.Pending = append(status.Pending, m.Version)
+		}
+	}
+	return status, nil
+}
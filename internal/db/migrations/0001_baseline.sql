@@ -0,0 +1,96 @@
+-- Базовая схема, на которую рассчитан код сервиса. Основана на create_schema.sql,
+-- но не удаляет существующие таблицы, поэтому безопасна для уже развернутых баз данных.
+-- Отличия от create_schema.sql:
+--   - categories описана так, как ее использует код (name, store_id): в create_schema.sql
+--     у нее по ошибке столбцы покупки (customer_id, total_price и т. д.);
+--   - customers создается раньше таблиц, которые на нее ссылаются.
+
+CREATE TABLE IF NOT EXISTS stores (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255),
+    phone VARCHAR(20)
+);
+
+CREATE TABLE IF NOT EXISTS manufacturers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+    id SERIAL PRIMARY KEY,
+    first_name VARCHAR(255) NOT NULL,
+    last_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    phone VARCHAR(20),
+    address TEXT
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    store_id INT,
+    FOREIGN KEY (store_id) REFERENCES stores(id)
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price DECIMAL(10, 2) NOT NULL,
+    image_url VARCHAR(255),
+    category_id INT,
+    manufacturer_id INT,
+    stock INT,
+    vape_type VARCHAR(255),
+    power INT,
+    battery_capacity INT,
+    tank_capacity INT,
+    coil_resistance DECIMAL(4, 2),
+    material VARCHAR(255),
+    color VARCHAR(255),
+    is_new BOOLEAN,
+    is_featured BOOLEAN,
+    FOREIGN KEY (category_id) REFERENCES categories(id),
+    FOREIGN KEY (manufacturer_id) REFERENCES manufacturers(id)
+);
+
+CREATE TABLE IF NOT EXISTS purchases (
+    id SERIAL PRIMARY KEY,
+    customer_id INT,
+    store_id INT,
+    product_id INT,
+    quantity INT,
+    FOREIGN KEY (customer_id) REFERENCES customers(id)
+);
+
+CREATE TABLE IF NOT EXISTS deliveries (
+    id SERIAL PRIMARY KEY,
+    order_id INT,
+    status VARCHAR(255) NOT NULL,
+    tracking_number VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES purchases(id)
+);
+
+CREATE TABLE IF NOT EXISTS purchase_items (
+    id SERIAL PRIMARY KEY,
+    purchase_id INT,
+    product_id INT,
+    quantity INT,
+    price DECIMAL(10, 2),
+    FOREIGN KEY (purchase_id) REFERENCES purchases(id),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS price_change (
+    id SERIAL PRIMARY KEY,
+    product_id INT,
+    old_price DECIMAL(10, 2),
+    new_price DECIMAL(10, 2),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
@@ -0,0 +1,33 @@
+-- Роли, блокировка учетных записей и журнал попыток входа
+
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'customer',
+    ADD COLUMN IF NOT EXISTS failed_login_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Все попытки входа; по ним считаются неудачные попытки с одного IP
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id SERIAL PRIMARY KEY,
+    customer_id INT REFERENCES customers(id),
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    success BOOLEAN NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_ip_created_at_idx ON login_attempts (ip, created_at) WHERE NOT success;
+
+-- Журнал подозрительной активности: блокировки, разблокировки администратором
+CREATE TABLE IF NOT EXISTS security_events (
+    id SERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    customer_id INT REFERENCES customers(id),
+    actor_id INT REFERENCES customers(id),
+    email VARCHAR(255),
+    ip VARCHAR(64),
+    details JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS security_events_created_at_idx ON security_events (created_at);
@@ -0,0 +1,17 @@
+-- Подтверждение email и одноразовые токены для сброса пароля
+
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Хранится только SHA-256 токена, сам токен уходит клиенту в письме
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id),
+    purpose VARCHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS auth_tokens_customer_purpose_idx ON auth_tokens (customer_id, purpose) WHERE used_at IS NULL;
@@ -0,0 +1,32 @@
+-- Проверка возраста: дата рождения клиента, документы для проверки
+-- и признак возрастного ограничения у категорий
+
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS date_of_birth DATE,
+    -- unverified, pending, verified или rejected
+    ADD COLUMN IF NOT EXISTS age_verification_status VARCHAR(16) NOT NULL DEFAULT 'unverified',
+    ADD COLUMN IF NOT EXISTS age_verified_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS age_restricted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Документы, удостоверяющие личность; проверяются администратором
+CREATE TABLE IF NOT EXISTS id_documents (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id),
+    document_type VARCHAR(32) NOT NULL,
+    -- Дата рождения, указанная клиентом при загрузке
+    date_of_birth DATE NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    content BYTEA NOT NULL,
+    -- pending, approved или rejected
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    rejection_reason TEXT,
+    reviewed_by INT REFERENCES customers(id),
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS id_documents_status_idx ON id_documents (status, created_at);
+CREATE INDEX IF NOT EXISTS id_documents_customer_id_idx ON id_documents (customer_id);
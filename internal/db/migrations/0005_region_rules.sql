@@ -0,0 +1,24 @@
+-- Характеристики жидкостей и региональные ограничения продаж
+
+ALTER TABLE products
+    -- Крепость никотина, мг/мл
+    ADD COLUMN IF NOT EXISTS nicotine_strength DECIMAL(5, 2),
+    -- Объем жидкости, мл
+    ADD COLUMN IF NOT EXISTS volume INT,
+    ADD COLUMN IF NOT EXISTS flavor VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS nicotine_salt BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Правила продажи в регионе доставки; NULL означает отсутствие ограничения
+CREATE TABLE IF NOT EXISTS region_rules (
+    region_code VARCHAR(16) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    max_nicotine_strength DECIMAL(5, 2),
+    -- Максимальный объем бака, мл
+    max_tank_capacity INT,
+    -- Запрещенные вкусы в нижнем регистре
+    banned_flavors TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS delivery_region VARCHAR(16);
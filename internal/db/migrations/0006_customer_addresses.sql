@@ -0,0 +1,24 @@
+-- Адресная книга клиентов и снимок адреса доставки в покупке
+
+CREATE TABLE IF NOT EXISTS customer_addresses (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id),
+    -- Название адреса, например "Дом" или "Работа"
+    name VARCHAR(100) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    postcode VARCHAR(20) NOT NULL,
+    region VARCHAR(16) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS customer_addresses_customer_id_idx ON customer_addresses (customer_id);
+-- У клиента не больше одного адреса по умолчанию
+CREATE UNIQUE INDEX IF NOT EXISTS customer_addresses_default_idx ON customer_addresses (customer_id) WHERE is_default;
+
+-- Адрес копируется в покупку, чтобы изменения адресной книги не меняли историю заказов
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS address_id INT REFERENCES customer_addresses(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS delivery_address JSONB;
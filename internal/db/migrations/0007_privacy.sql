@@ -0,0 +1,21 @@
+-- Удаление персональных данных по запросу клиента
+
+ALTER TABLE customers
+    -- Момент обезличивания учетной записи
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Запросы на удаление; выполняются после окончания срока на отмену
+CREATE TABLE IF NOT EXISTS erasure_requests (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id),
+    requested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- У клиента не больше одного активного запроса
+CREATE UNIQUE INDEX IF NOT EXISTS erasure_requests_active_idx ON erasure_requests (customer_id)
+    WHERE cancelled_at IS NULL AND completed_at IS NULL;
+CREATE INDEX IF NOT EXISTS erasure_requests_scheduled_at_idx ON erasure_requests (scheduled_at)
+    WHERE cancelled_at IS NULL AND completed_at IS NULL;
@@ -0,0 +1,16 @@
+-- Шифрование персональных данных. Зашифрованные значения длиннее исходных,
+-- поэтому столбцы переводятся в TEXT. Существующие значения шифруются
+-- фоновой задачей, она же заполняет слепой индекс email.
+
+ALTER TABLE customers
+    ALTER COLUMN email TYPE TEXT,
+    ALTER COLUMN phone TYPE TEXT,
+    -- HMAC-SHA256 от email в нижнем регистре; по нему ищется клиент при входе
+    ADD COLUMN IF NOT EXISTS email_hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS customers_email_hash_idx ON customers (email_hash);
+
+ALTER TABLE customer_addresses
+    ALTER COLUMN city TYPE TEXT,
+    ALTER COLUMN street TYPE TEXT,
+    ALTER COLUMN postcode TYPE TEXT;
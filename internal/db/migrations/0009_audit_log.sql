@@ -0,0 +1,21 @@
+-- Журнал аудита изменений каталога и покупок
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    -- Автор изменения; NULL для фоновых задач
+    actor_id INT REFERENCES customers(id),
+    actor_role VARCHAR(32) NOT NULL,
+    ip VARCHAR(64),
+    request_id VARCHAR(128),
+    entity_type VARCHAR(64) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    -- Значения измененных полей до и после; при создании before пуст, при удалении - after
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, created_at);
@@ -0,0 +1,11 @@
+-- Мягкое удаление категорий и товаров: на них ссылаются покупки и история цен
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Для задачи окончательного удаления
+CREATE INDEX IF NOT EXISTS categories_deleted_at_idx ON categories (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS products_deleted_at_idx ON products (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,14 @@
+-- Дерево категорий: родитель, порядок среди соседей и слаг для URL
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES categories(id),
+    ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+
+-- Существующим категориям слаг назначается по ID; его можно изменить через API
+UPDATE categories SET slug = 'category-' || id WHERE slug IS NULL;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_slug_idx ON categories (slug);
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id, position);
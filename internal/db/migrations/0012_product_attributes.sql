@@ -0,0 +1,48 @@
+-- Характеристики товаров, которые зависят от категории. Описания характеристик
+-- задаются на категорию и наследуются подкатегориями, значения хранятся в JSONB.
+
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id SERIAL PRIMARY KEY,
+    category_id INT NOT NULL REFERENCES categories(id),
+    -- Ключ значения в products.attributes
+    code VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    -- string, number, integer или boolean
+    type VARCHAR(16) NOT NULL,
+    unit VARCHAR(32),
+    -- Допустимые значения строковой характеристики; NULL - любые
+    allowed_values TEXT[],
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    position INT NOT NULL DEFAULT 0,
+    UNIQUE (category_id, code)
+);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Перенос характеристик из столбцов. Столбцы остаются для совместимости API
+UPDATE products SET attributes = jsonb_strip_nulls(jsonb_build_object(
+    'vape_type', NULLIF(vape_type, ''),
+    'power', power,
+    'battery_capacity', battery_capacity,
+    'tank_capacity', tank_capacity,
+    'coil_resistance', coil_resistance,
+    'material', NULLIF(material, ''),
+    'color', NULLIF(color, '')
+)) || attributes;
+
+-- Описания перенесенных характеристик для категорий, в товарах которых они заполнены
+INSERT INTO category_attributes (category_id, code, name, type, unit, position)
+SELECT DISTINCT p.category_id, a.code, a.name, a.type, a.unit, a.position
+FROM products p
+CROSS JOIN (VALUES
+    ('vape_type', 'Тип устройства', 'string', NULL, 1),
+    ('power', 'Мощность', 'integer', 'Вт', 2),
+    ('battery_capacity', 'Емкость аккумулятора', 'integer', 'мА·ч', 3),
+    ('tank_capacity', 'Объем бака', 'integer', 'мл', 4),
+    ('coil_resistance', 'Сопротивление испарителя', 'number', 'Ом', 5),
+    ('material', 'Материал', 'string', NULL, 6),
+    ('color', 'Цвет', 'string', NULL, 7)
+) AS a(code, name, type, unit, position)
+WHERE p.category_id IS NOT NULL AND p.attributes ? a.code
+ON CONFLICT (category_id, code) DO NOTHING;
@@ -0,0 +1,43 @@
+-- Варианты товара (цвет, сопротивление испарителя, крепость) со своим артикулом,
+-- ценой, остатком и изображением. Остаток товара - сумма остатков вариантов.
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    sku VARCHAR(64) NOT NULL,
+    color VARCHAR(255),
+    coil_resistance DECIMAL(4, 2),
+    nicotine_strength DECIMAL(5, 2),
+    -- NULL - цена товара
+    price DECIMAL(10, 2),
+    stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    -- NULL - изображение товара
+    image_url VARCHAR(255),
+    position INT NOT NULL DEFAULT 0,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_sku_idx ON product_variants (sku);
+CREATE INDEX IF NOT EXISTS product_variants_product_id_idx ON product_variants (product_id, position);
+
+-- Каждый существующий товар получает один вариант с его характеристиками и остатком
+INSERT INTO product_variants (product_id, sku, color, coil_resistance, nicotine_strength, stock)
+SELECT id, 'SKU-' || id, NULLIF(color, ''), coil_resistance, nicotine_strength, GREATEST(COALESCE(stock, 0), 0)
+FROM products
+WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id);
+
+-- Строки покупок ссылаются на вариант; артикул сохраняется на момент покупки
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS variant_id INT REFERENCES product_variants(id),
+    ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+
+ALTER TABLE purchase_items
+    ADD COLUMN IF NOT EXISTS variant_id INT REFERENCES product_variants(id);
+
+UPDATE purchases p SET variant_id = v.id, sku = v.sku
+FROM product_variants v
+WHERE v.product_id = p.product_id AND p.variant_id IS NULL;
+
+UPDATE purchase_items i SET variant_id = v.id
+FROM product_variants v
+WHERE v.product_id = i.product_id AND i.variant_id IS NULL;
@@ -0,0 +1,18 @@
+-- Изображения товара в порядке показа. Файлы лежат в хранилище (каталог или S3):
+-- оригинал под ключом key, уменьшенные копии рядом с ним под именами <сторона>.<расширение>.
+
+CREATE TABLE IF NOT EXISTS product_images (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    size INT NOT NULL,
+    -- Стороны квадратов, в которые вписаны уменьшенные копии
+    thumbnails INT[] NOT NULL DEFAULT '{}',
+    position INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS product_images_product_id_idx ON product_images (product_id, position);
@@ -0,0 +1,29 @@
+-- Задания импорта товаров из CSV/XLSX. Файл хранится до завершения задания;
+-- processed_rows и счетчики обновляются в одной транзакции с импортированными строками,
+-- поэтому прерванное задание продолжается с первой необработанной строки.
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id SERIAL PRIMARY KEY,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    file_name VARCHAR(255) NOT NULL,
+    content BYTEA,
+    -- Целевое поле -> заголовок столбца файла
+    mapping JSONB NOT NULL DEFAULT '{}',
+    -- Автор загрузки для журнала аудита
+    actor JSONB NOT NULL DEFAULT '{}',
+    total_rows INT NOT NULL DEFAULT 0,
+    processed_rows INT NOT NULL DEFAULT 0,
+    created_count INT NOT NULL DEFAULT 0,
+    updated_count INT NOT NULL DEFAULT 0,
+    failed_count INT NOT NULL DEFAULT 0,
+    -- Ошибки строк: [{"row": 3, "column": "price", "message": "..."}]
+    errors JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS import_jobs_pending_idx ON import_jobs (id) WHERE status IN ('pending', 'running');
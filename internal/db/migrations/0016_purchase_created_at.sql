@@ -0,0 +1,19 @@
+-- Дата оформления покупки для выгрузок по периоду. Для существующих покупок
+-- берется из журнала аудита; покупки старше журнала остаются без даты.
+
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE purchases p SET created_at = a.created_at
+FROM (
+    SELECT entity_id, min(created_at) AS created_at
+    FROM audit_log
+    WHERE entity_type = 'purchase' AND action = 'create'
+    GROUP BY entity_id
+) a
+WHERE a.entity_id = p.id AND p.created_at IS NULL;
+
+ALTER TABLE purchases
+    ALTER COLUMN created_at SET DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS purchases_created_at_idx ON purchases (created_at);
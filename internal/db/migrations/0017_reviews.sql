@@ -0,0 +1,28 @@
+-- Отзывы о товарах с оценкой 1-5. Отзывы проходят модерацию; в рейтинг товара
+-- попадают только одобренные. Сумма и количество оценок хранятся в товаре
+-- и обновляются вместе со статусом отзыва, чтобы каталог сортировался без агрегации.
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    customer_id INT NOT NULL REFERENCES customers(id),
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    text TEXT NOT NULL DEFAULT '',
+    -- Автор покупал товар на момент написания отзыва
+    verified_purchase BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    rejection_reason TEXT,
+    moderated_by INT REFERENCES customers(id),
+    moderated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    UNIQUE (product_id, customer_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_product_id_idx ON reviews (product_id, status, created_at);
+CREATE INDEX IF NOT EXISTS reviews_status_idx ON reviews (status, created_at);
+CREATE INDEX IF NOT EXISTS reviews_customer_id_idx ON reviews (customer_id);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS rating_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_sum INT NOT NULL DEFAULT 0;
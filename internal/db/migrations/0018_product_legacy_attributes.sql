@@ -0,0 +1,40 @@
+-- Характеристики товара хранятся в attributes; столбцы vape_type, power и другие
+-- повторяют их для совместимости API и проверок региональных ограничений.
+-- Изменения характеристик до этой миграции не переносились в столбцы: значения
+-- из столбцов, которых нет в attributes, переносятся в характеристики, после чего
+-- столбцы заполняются из attributes. Значение другого типа столбец не меняет:
+-- по tank_capacity проверяются региональные ограничения.
+
+UPDATE products SET attributes = jsonb_strip_nulls(jsonb_build_object(
+    'vape_type', NULLIF(vape_type, ''),
+    'power', NULLIF(power, 0),
+    'battery_capacity', NULLIF(battery_capacity, 0),
+    'tank_capacity', NULLIF(tank_capacity, 0),
+    'coil_resistance', NULLIF(coil_resistance, 0),
+    'material', NULLIF(material, ''),
+    'color', NULLIF(color, '')
+)) || attributes;
+
+INSERT INTO category_attributes (category_id, code, name, type, unit, position)
+SELECT DISTINCT p.category_id, a.code, a.name, a.type, a.unit, a.position
+FROM products p
+CROSS JOIN (VALUES
+    ('vape_type', 'Тип устройства', 'string', NULL, 1),
+    ('power', 'Мощность', 'integer', 'Вт', 2),
+    ('battery_capacity', 'Емкость аккумулятора', 'integer', 'мА·ч', 3),
+    ('tank_capacity', 'Объем бака', 'integer', 'мл', 4),
+    ('coil_resistance', 'Сопротивление испарителя', 'number', 'Ом', 5),
+    ('material', 'Материал', 'string', NULL, 6),
+    ('color', 'Цвет', 'string', NULL, 7)
+) AS a(code, name, type, unit, position)
+WHERE p.category_id IS NOT NULL AND p.attributes ? a.code
+ON CONFLICT (category_id, code) DO NOTHING;
+
+UPDATE products SET
+    vape_type = CASE WHEN jsonb_typeof(attributes->'vape_type') = 'string' THEN attributes->>'vape_type' ELSE vape_type END,
+    power = CASE WHEN jsonb_typeof(attributes->'power') = 'number' THEN round((attributes->>'power')::numeric) ELSE power END,
+    battery_capacity = CASE WHEN jsonb_typeof(attributes->'battery_capacity') = 'number' THEN round((attributes->>'battery_capacity')::numeric) ELSE battery_capacity END,
+    tank_capacity = CASE WHEN jsonb_typeof(attributes->'tank_capacity') = 'number' THEN round((attributes->>'tank_capacity')::numeric) ELSE tank_capacity END,
+    coil_resistance = CASE WHEN jsonb_typeof(attributes->'coil_resistance') = 'number' THEN (attributes->>'coil_resistance')::numeric ELSE coil_resistance END,
+    material = CASE WHEN jsonb_typeof(attributes->'material') = 'string' THEN attributes->>'material' ELSE material END,
+    color = CASE WHEN jsonb_typeof(attributes->'color') = 'string' THEN attributes->>'color' ELSE color END;
@@ -0,0 +1,4 @@
+-- Цена единицы товара на момент покупки. У покупок, оформленных раньше, цена
+-- не сохранялась, поэтому столбец остается пустым.
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS price DECIMAL(10, 2);
@@ -0,0 +1,4 @@
+-- Проверка файла импорта без сохранения выполняется заданием, как и импорт:
+-- изменения каждого пакета строк откатываются, сохраняется только отчет.
+ALTER TABLE import_jobs
+    ADD COLUMN IF NOT EXISTS dry_run BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+-- Email в журналах входа хранится зашифрованным, как и в customers, а ищется
+-- по слепому индексу. Существующие записи шифруются фоновой задачей.
+
+ALTER TABLE login_attempts
+    ALTER COLUMN email TYPE TEXT,
+    ADD COLUMN IF NOT EXISTS email_hash CHAR(64);
+
+CREATE INDEX IF NOT EXISTS login_attempts_email_hash_idx ON login_attempts (email_hash);
+
+ALTER TABLE security_events
+    ALTER COLUMN email TYPE TEXT,
+    ADD COLUMN IF NOT EXISTS email_hash CHAR(64);
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("VapeShop-ClientAPI/internal/db")
+
+const (
+	attrRowsReturned = attribute.Key("db.rows_returned")
+	attrRowsAffected = attribute.Key("db.rows_affected")
+)
+
+// Rows - результат запроса, который считает прочитанные строки
+// и завершает спан запроса после чтения всех строк или Close.
+type Rows struct {
+	*sql.Rows
+	span  trace.Span
+	count int64
+	once  sync.Once
+}
+
+// Next - переход к следующей строке с подсчетом прочитанных строк.
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.count++
+		return true
+	}
+	r.finish()
+	return false
+}
+
+// Close - закрытие результата и завершение спана.
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.finish()
+	return err
+}
+
+func (r *Rows) finish() {
+	r.once.Do(func() {
+		r.span.SetAttributes(attrRowsReturned.Int64(r.count))
+		endSpan(r.span, r.Rows.Err())
+	})
+}
+
+// startSpan - начало спана SQL-запроса. Имя спана - операция (SELECT, INSERT, ...).
+func startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	op := queryOperation(query)
+	return tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(op),
+			semconv.DBQueryText(compactQuery(query)),
+		))
+}
+
+// endSpan - завершение спана с записью ошибки. sql.ErrNoRows ошибкой не считается.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func recordRowsAffected(span trace.Span, res sql.Result, err error) {
+	if err != nil || res == nil {
+		return
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(attrRowsAffected.Int64(n))
+	}
+}
+
+// queryOperation - первое ключевое слово запроса.
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+	return strings.ToUpper(fields[0])
+}
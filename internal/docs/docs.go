@@ -0,0 +1,71 @@
+package docs
+
+//go:generate go run yaml2json.go
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// Спецификация пишется в swagger.yaml, swagger.json генерируется из нее
+//
+//go:embed swagger.json
+var spec []byte
+
+//go:embed index.html
+var indexHTML []byte
+
+// Register - регистрация маршрутов документации:
+// /api/docs - Swagger UI, /api/docs/openapi.json - спецификация.
+func Register(router *gin.Engine) {
+	docs := router.Group("/api/docs")
+	docs.GET("", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", indexHTML)
+	})
+	docs.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+	})
+	docs.StaticFS("/ui", http.FS(swaggerFiles.FS))
+}
+
+// MissingRoutes - маршруты gin, которых нет в спецификации, в виде "GET /api/v1/...".
+// Маршруты с "*" (раздача статики) не проверяются.
+func MissingRoutes(routes gin.RoutesInfo) ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("ошибка разбора спецификации: %w", err)
+	}
+
+	var missing []string
+	for _, r := range routes {
+		if strings.Contains(r.Path, "*") || r.Method == http.MethodHead {
+			continue
+		}
+		if _, ok := doc.Paths[openAPIPath(r.Path)][strings.ToLower(r.Method)]; !ok {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// openAPIPath - "/products/:id" -> "/products/{id}".
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,37 @@
+//go:build ignore
+
+// Генерация swagger.json из swagger.yaml: go generate ./internal/docs
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	src, err := os.ReadFile("swagger.yaml")
+	if err != nil {
+		log.Fatalf("Ошибка чтения swagger.yaml: %v", err)
+	}
+
+	var spec map[string]any
+	if err := yaml.Unmarshal(src, &spec); err != nil {
+		log.Fatalf("Ошибка разбора swagger.yaml: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(spec); err != nil {
+		log.Fatalf("Ошибка кодирования JSON: %v", err)
+	}
+
+	if err := os.WriteFile("swagger.json", buf.Bytes(), 0o644); err != nil {
+		log.Fatalf("Ошибка записи swagger.json: %v", err)
+	}
+}
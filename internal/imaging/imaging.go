@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// JPEGQuality - качество JPEG для уменьшенных копий.
+const JPEGQuality = 85
+
+// ErrUnsupportedFormat - формат изображения не поддерживается.
+var ErrUnsupportedFormat = errors.New("поддерживаются только изображения JPEG и PNG")
+
+// Info - размеры и формат изображения ("jpeg" или "png").
+type Info struct {
+	Format string
+	Width  int
+	Height int
+}
+
+// DecodeConfig - размеры изображения без декодирования пикселей; позволяет
+// отклонить слишком большие изображения до выделения памяти под них.
+func DecodeConfig(data []byte) (Info, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, fmt.Errorf("не удалось прочитать изображение: %w", err)
+	}
+	if format != "jpeg" && format != "png" {
+		return Info{}, ErrUnsupportedFormat
+	}
+	return Info{Format: format, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// Decode - декодирование изображения JPEG или PNG.
+func Decode(data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("не удалось прочитать изображение: %w", err)
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, "", ErrUnsupportedFormat
+	}
+	return img, format, nil
+}
+
+// Encode - кодирование в формат format ("jpeg" или "png").
+func Encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality})
+	case "png":
+		err = png.Encode(&buf, img)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка кодирования изображения: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Thumbnail - уменьшенная копия, вписанная в квадрат size x size с сохранением пропорций.
+// Изображения меньше квадрата не увеличиваются.
+func Thumbnail(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/b.Dx())
+		} else {
+			w, h = max(1, w*size/b.Dy()), size
+		}
+	}
+	return resize(img, w, h)
+}
+
+// resize - уменьшение усреднением по области: каждый пиксель результата - среднее
+// пикселей исходного прямоугольника, который он покрывает. Цвета усредняются
+// с учетом прозрачности, чтобы у полупрозрачных краев не появлялась темная кайма.
+func resize(img image.Image, w, h int) *image.NRGBA {
+	b := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok || src.Rect.Min != (image.Point{}) {
+		src = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					pa := uint64(p[3])
+					r += uint64(p[0]) * pa
+					g += uint64(p[1]) * pa
+					bl += uint64(p[2]) * pa
+					a += pa
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			if a > 0 {
+				d[0] = uint8(r / a)
+				d[1] = uint8(g / a)
+				d[2] = uint8(bl / a)
+			}
+			d[3] = uint8(a / n)
+		}
+	}
+	return dst
+}
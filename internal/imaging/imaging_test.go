@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// filled - изображение w x h, залитое цветом c.
+func filled(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestThumbnailSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		wantW, wantH int
+	}{
+		{"горизонтальное", 400, 200, 100, 50},
+		{"вертикальное", 200, 400, 50, 100},
+		{"квадратное", 300, 300, 100, 100},
+		{"меньше квадрата не увеличивается", 50, 30, 50, 30},
+		{"узкая полоса", 1000, 1, 100, 1},
+		{"высокая полоса", 3, 1000, 1, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb := Thumbnail(filled(tt.w, tt.h, color.NRGBA{255, 0, 0, 255}), 100)
+			if got := thumb.Bounds().Size(); got != image.Pt(tt.wantW, tt.wantH) {
+				t.Errorf("размер = %v, ожидалось %dx%d", got, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestResizeAveragesWithAlpha(t *testing.T) {
+	// Левая половина - непрозрачный красный, правая - прозрачный черный
+	src := filled(4, 2, color.NRGBA{255, 0, 0, 255})
+	for y := 0; y < 2; y++ {
+		for x := 2; x < 4; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 0})
+		}
+	}
+
+	got := resize(src, 1, 1).NRGBAAt(0, 0)
+	// Цвет прозрачных пикселей не затемняет результат, прозрачность усредняется
+	if want := (color.NRGBA{255, 0, 0, 127}); got != want {
+		t.Errorf("пиксель = %v, ожидалось %v", got, want)
+	}
+
+	half := resize(src, 2, 1)
+	if got := half.NRGBAAt(0, 0); got != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("левый пиксель = %v", got)
+	}
+	if got := half.NRGBAAt(1, 0); got.A != 0 {
+		t.Errorf("правый пиксель = %v, ожидался прозрачный", got)
+	}
+}
+
+func TestResizeSubImage(t *testing.T) {
+	// Часть изображения со смещенными границами
+	src := filled(4, 4, color.NRGBA{0, 0, 255, 255})
+	src.SetNRGBA(0, 0, color.NRGBA{255, 255, 255, 255})
+	sub := src.SubImage(image.Rect(2, 2, 4, 4))
+
+	got := resize(sub, 1, 1)
+	if got.Bounds() != image.Rect(0, 0, 1, 1) {
+		t.Fatalf("границы = %v", got.Bounds())
+	}
+	if c := got.NRGBAAt(0, 0); c != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("пиксель = %v, ожидался синий без пикселей вне области", c)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	img := filled(8, 6, color.NRGBA{10, 200, 30, 255})
+	for _, format := range []string{"png", "jpeg"} {
+		data, err := Encode(img, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := DecodeConfig(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info != (Info{Format: format, Width: 8, Height: 6}) {
+			t.Errorf("DecodeConfig = %+v", info)
+		}
+		decoded, gotFormat, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotFormat != format || decoded.Bounds().Size() != image.Pt(8, 6) {
+			t.Errorf("Decode = %s %v", gotFormat, decoded.Bounds())
+		}
+	}
+
+	if _, err := Encode(img, "gif"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Encode(gif): %v, ожидалось ErrUnsupportedFormat", err)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, filled(2, 2, color.NRGBA{0, 0, 0, 255}), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeConfig(buf.Bytes()); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("DecodeConfig(gif): %v, ожидалось ErrUnsupportedFormat", err)
+	}
+	if _, _, err := Decode(buf.Bytes()); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Decode(gif): %v, ожидалось ErrUnsupportedFormat", err)
+	}
+	if _, err := DecodeConfig([]byte("not an image")); err == nil {
+		t.Error("DecodeConfig: ожидалась ошибка")
+	}
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type ctxKey struct{}
+
+const redacted = "[REDACTED]"
+
+// Ключи атрибутов, значения которых никогда не попадают в лог
+var secretKeys = []string{"password", "secret", "token", "authorization", "cookie", "jwt", "dsn"}
+
+// Ключи атрибутов с персональными данными, которые маскируются
+var piiKeys = map[string]func(string) string{
+	"email":   maskEmail,
+	"phone":   maskTail,
+	"address": func(string) string { return redacted },
+}
+
+// New - создание логгера. В режиме отладки используется текстовый формат,
+// иначе JSON. Секреты и персональные данные скрываются в любом режиме.
+func New(w io.Writer, debug bool) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       slog.LevelInfo,
+		ReplaceAttr: redact,
+	}
+	if debug {
+		opts.Level = slog.LevelDebug
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// WithContext - сохранение логгера в контексте.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext - логгер из контекста (с request_id и т.п.) или логгер по умолчанию.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// redact - замена значений секретных и персональных атрибутов.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	if mask, ok := piiKeys[key]; ok {
+		// Значение другого типа (структура, указатель) замаскировать нельзя, оно скрывается целиком
+		if a.Value.Kind() != slog.KindString {
+			return slog.String(a.Key, redacted)
+		}
+		return slog.String(a.Key, mask(a.Value.String()))
+	}
+	return a
+}
+
+// maskEmail - "ivan@example.com" -> "i***@example.com".
+func maskEmail(v string) string {
+	name, domain, ok := strings.Cut(v, "@")
+	if !ok || name == "" {
+		return redacted
+	}
+	first := []rune(name)[:1]
+	return string(first) + "***@" + domain
+}
+
+// maskTail - оставляет видимыми только два последних символа.
+func maskTail(v string) string {
+	r := []rune(v)
+	if len(r) <= 2 {
+		return redacted
+	}
+	return strings.Repeat("*", len(r)-2) + string(r[len(r)-2:])
+}
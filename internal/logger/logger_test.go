@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	type address struct{ City, Street string }
+
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want any
+	}{
+		{"пароль", slog.String("password", "qwerty"), redacted},
+		{"ключ с секретом в имени", slog.String("jwt_secret", "s3cr3t"), redacted},
+		{"ключ в другом регистре", slog.String("Authorization", "Bearer abc"), redacted},
+		{"секрет не строкой", slog.Int("token", 12345), redacted},
+		{"email", slog.String("email", "ivan@example.com"), "i***@example.com"},
+		{"email с кириллицей", slog.String("email", "иван@пример.рф"), "и***@пример.рф"},
+		{"email без @", slog.String("email", "ivan"), redacted},
+		{"email без имени", slog.String("email", "@example.com"), redacted},
+		{"телефон", slog.String("phone", "+79001234567"), "**********67"},
+		{"короткий телефон", slog.String("phone", "12"), redacted},
+		{"адрес", slog.String("address", "Москва, ул. Тверская, 1"), redacted},
+		{"адрес структурой", slog.Any("address", address{"Москва", "Тверская"}), redacted},
+		{"обычный атрибут", slog.String("sku", "SKU-1"), "SKU-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, false).Info("test", tt.attr)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			if got := record[tt.attr.Key]; got != tt.want {
+				t.Errorf("%s = %v, ожидалось %v", tt.attr.Key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactInGroup(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, false).Info("test", slog.Group("customer", slog.String("email", "ivan@example.com"), slog.String("password", "x")))
+
+	var record struct {
+		Customer map[string]string `json:"customer"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Customer["email"] != "i***@example.com" || record.Customer["password"] != redacted {
+		t.Errorf("группа = %v, ожидалось скрытие email и пароля", record.Customer)
+	}
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"VapeShop-ClientAPI/internal/logger"
+)
+
+// FileMailer - сохранение писем в каталог в формате .eml вместо отправки.
+// Используется при локальном запуске и в тестах.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer - создание отправителя, пишущего письма в каталог dir.
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+// Send - запись письма в файл.
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := buildMIME(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("ошибка формирования письма: %w", err)
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога писем: %w", err)
+	}
+
+	name := filepath.Join(m.dir, fmt.Sprintf("%s.eml", time.Now().Format("20060102T150405.000000000")))
+	if err := os.WriteFile(name, body, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи письма: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Письмо сохранено в файл",
+		slog.String("file", name),
+		slog.String("subject", msg.Subject))
+	return nil
+}
@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	LocaleRU = "ru"
+	LocaleEN = "en"
+
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+)
+
+//go:embed templates/*/*.tmpl
+var templatesFS embed.FS
+
+// Шаблоны по ключу "язык/имя"
+var templates = mustLoadTemplates()
+
+// Message - письмо в виде обычного текста.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer - отправка писем. Реализации: SMTP и запись в файлы для локального запуска.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func mustLoadTemplates() map[string]*template.Template {
+	result := make(map[string]*template.Template)
+	for _, locale := range []string{LocaleRU, LocaleEN} {
+		for _, name := range []string{TemplatePasswordReset, TemplateEmailVerification} {
+			path := fmt.Sprintf("templates/%s/%s.tmpl", locale, name)
+			result[locale+"/"+name] = template.Must(template.ParseFS(templatesFS, path))
+		}
+	}
+	return result
+}
+
+// Locale - выбор языка письма по заголовку Accept-Language (по умолчанию русский).
+func Locale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, LocaleRU):
+			return LocaleRU
+		case strings.HasPrefix(tag, LocaleEN):
+			return LocaleEN
+		}
+	}
+	return LocaleRU
+}
+
+// Render - формирование письма по шаблону на нужном языке.
+func Render(locale, name, to string, data map[string]any) (Message, error) {
+	t, ok := templates[locale+"/"+name]
+	if !ok {
+		t, ok = templates[LocaleRU+"/"+name]
+		if !ok {
+			return Message{}, fmt.Errorf("шаблон письма %s не найден", name)
+		}
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("ошибка формирования темы письма: %w", err)
+	}
+	if err := t.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, fmt.Errorf("ошибка формирования текста письма: %w", err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimLeft(body.String(), "\n"),
+	}, nil
+}
+
+// FormatTTL - срок действия ссылки для текста письма.
+func FormatTTL(locale string, d time.Duration) string {
+	if d >= time.Hour {
+		h := int(d.Hours())
+		if locale == LocaleEN {
+			if h == 1 {
+				return "1 hour"
+			}
+			return fmt.Sprintf("%d hours", h)
+		}
+		return fmt.Sprintf("%d ч.", h)
+	}
+
+	m := int(d.Minutes())
+	if locale == LocaleEN {
+		return fmt.Sprintf("%d minutes", m)
+	}
+	return fmt.Sprintf("%d мин.", m)
+}
+
+// buildMIME - сборка письма в формате RFC 5322 с текстом в UTF-8.
+func buildMIME(from string, msg Message) ([]byte, error) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = strings.Trim(d, "> ")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer - отправка писем через SMTP. На порту 465 используется TLS
+// с самого начала соединения, на остальных - STARTTLS, если сервер его поддерживает.
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer - создание SMTP-отправителя.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send - отправка письма.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	body, err := buildMIME(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("ошибка формирования письма: %w", err)
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к SMTP-серверу: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if m.port == 465 {
+		conn = tls.Client(conn, &tls.Config{ServerName: m.host})
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("ошибка подключения к SMTP-серверу: %w", err)
+	}
+	defer client.Close()
+
+	if m.port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+				return fmt.Errorf("ошибка STARTTLS: %w", err)
+			}
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("ошибка авторизации SMTP: %w", err)
+		}
+	}
+
+	// В MAIL FROM передается только адрес, без отображаемого имени
+	envelopeFrom := m.from
+	if addr, err := mail.ParseAddress(m.from); err == nil {
+		envelopeFrom = addr.Address
+	}
+	if err := client.Mail(envelopeFrom); err != nil {
+		return fmt.Errorf("ошибка отправки письма: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("ошибка отправки письма: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("ошибка отправки письма: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("ошибка отправки письма: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("ошибка отправки письма: %w", err)
+	}
+	return client.Quit()
+}
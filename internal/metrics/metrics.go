@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "vapeshop"
+
+// HTTP-метрики. Маршрут берется из шаблона gin (/api/v1/products/:id),
+// чтобы количество временных рядов не зависело от значений параметров.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Количество обработанных HTTP-запросов.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Время обработки HTTP-запросов.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Бизнес-метрики
+var (
+	PurchasesCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "purchases_created_total",
+		Help:      "Количество созданных покупок.",
+	})
+
+	Revenue = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Сумма созданных покупок.",
+	})
+
+	StockOuts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stock_outs_total",
+		Help:      "Количество покупок, отклоненных из-за нехватки товара на складе.",
+	})
+
+	FailedLogins = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_logins_total",
+		Help:      "Количество неудачных попыток входа.",
+	})
+)
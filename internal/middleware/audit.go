@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"VapeShop-ClientAPI/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditActor - сохранение автора запроса в контексте для журнала аудита.
+// Используется после AuthMiddleware.
+func AuditActor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a := audit.Actor{
+			Role:      Role(c),
+			IP:        c.ClientIP(),
+			RequestID: c.GetString(RequestIDKey),
+		}
+		if id, ok := CustomerID(c); ok {
+			a.ID = &id
+		}
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), a))
+
+		c.Next()
+	}
+}
@@ -2,18 +2,65 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+
+	"VapeShop-ClientAPI/internal/auth"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+const (
+	// CustomerIDKey - ключ ID авторизованного клиента в gin.Context
+	CustomerIDKey = "customer_id"
+	// RoleKey - ключ роли авторизованного клиента в gin.Context
+	RoleKey = "role"
+)
+
+// CustomerID - ID авторизованного клиента, если он известен.
+func CustomerID(c *gin.Context) (int64, bool) {
+	v, ok := c.Get(CustomerIDKey)
+	if !ok {
+		return 0, false
+	}
+	id, ok := v.(int64)
+	return id, ok
+}
+
+// Role - роль авторизованного клиента.
+func Role(c *gin.Context) string {
+	return c.GetString(RoleKey)
+}
+
+// AuthMiddleware - проверка JWT из заголовка "Authorization: Bearer <token>".
+func AuthMiddleware(secret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
+		tokenString := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
 		if tokenString == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication Token"})
 			return
 		}
 
+		claims, err := auth.ParseToken(secret, tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication Token"})
+			return
+		}
+
+		customerID, _ := claims.CustomerID()
+		c.Set(CustomerIDKey, customerID)
+		c.Set(RoleKey, claims.Role)
+
+		c.Next()
+	}
+}
+
+// RequireRole - доступ только для клиентов с указанной ролью. Используется после AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Role(c) != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "недостаточно прав"})
+			return
+		}
 		c.Next()
 	}
 }
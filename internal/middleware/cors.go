@@ -1,22 +1,117 @@
 package middleware
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"VapeShop-ClientAPI/internal/config"
+
 	"github.com/gin-gonic/gin"
 )
 
-func Cors() gin.HandlerFunc {
+// Cors - middleware для обработки CORS на основе настроек из конфигурации.
+// Поддерживает список разрешенных источников, в том числе с подстановкой
+// поддоменов вида "https://*.example.com", и "*" для любого источника.
+func Cors(cfg config.CORS) gin.HandlerFunc {
+	origins := normalizeList(cfg.Origins)
+	methods := strings.Join(normalizeList(cfg.Methods), ", ")
+	headers := strings.Join(normalizeList(cfg.Headers), ", ")
+	exposed := strings.Join(normalizeList(cfg.ExposedHeaders), ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	allowAll := false
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := c.GetHeader("Origin")
+		// Ответ зависит от Origin, поэтому кэши должны это учитывать
+		c.Writer.Header().Add("Vary", "Origin")
 
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !allowAll && !originAllowed(origin, origins) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
 
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		// "*" вместе с учетными данными запрещен при проверке конфигурации
+		if allowAll {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if exposed != "" {
+			c.Writer.Header().Set("Access-Control-Expose-Headers", exposed)
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", methods)
+			c.Writer.Header().Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				c.Writer.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// originAllowed - проверка источника по списку разрешенных.
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == origin {
+			return true
+		}
+
+		// Шаблон поддомена: "https://*.example.com"
+		scheme, host, ok := strings.Cut(pattern, "://*.")
+		if !ok {
+			continue
+		}
+		prefix := scheme + "://"
+		suffix := "." + host
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			len(origin) > len(prefix)+len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeList - удаление пробелов, кавычек и скобок, оставшихся после
+// разбора значений вида ["a", "b"] из .env.
+func normalizeList(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.Trim(strings.TrimSpace(part), `[]"' `)
+			if part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://shop.example.com", "https://*.example.org", "http://localhost:3000"}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://shop.example.com", true},
+		{"HTTPS://Shop.Example.com", true},
+		{"http://shop.example.com", false},
+		{"https://shop.example.com.evil.io", false},
+		{"https://evilshop.example.com", false},
+		{"https://admin.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"http://admin.example.org", false},
+		{"https://admin.example.org.evil.io", false},
+		{"https://evilexample.org", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3001", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin, allowed); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, ожидалось %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeList(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{[]string{"GET", "POST"}, []string{"GET", "POST"}},
+		{[]string{`["https://a.example.com", "https://b.example.com"]`}, []string{"https://a.example.com", "https://b.example.com"}},
+		{[]string{"GET, POST", " 'PUT' "}, []string{"GET", "POST", "PUT"}},
+		{[]string{"", "[]", " , "}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := normalizeList(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeList(%q) = %q, ожидалось %q", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"VapeShop-ClientAPI/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey - ключ request ID в gin.Context
+	RequestIDKey = "request_id"
+)
+
+// RequestID - middleware, которое берет X-Request-ID из запроса или генерирует новый,
+// возвращает его в ответе и кладет в контекст запроса логгер с этим ID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Writer.Header().Set(RequestIDHeader, id)
+
+		l := slog.Default().With(slog.String("request_id", id))
+		// Связываем логи с трассировкой, если запрос попал в выборку
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			l = l.With(slog.String("trace_id", sc.TraceID().String()))
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("http.request_id", id))
+		}
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), l))
+
+		c.Next()
+	}
+}
+
+// Logger - middleware для журналирования HTTP-запросов.
+// Строка запроса не логируется, так как может содержать токены.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		logger.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "HTTP-запрос", attrs...)
+	}
+}
+
+// Recovery - восстановление после паники с записью в лог. http.ErrAbortHandler
+// передается серверу: им обработчик обрывает соединение, когда ответ уже начат.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		logger.FromContext(c.Request.Context()).Error("Паника при обработке запроса",
+			slog.Any("panic", err),
+			slog.String("stack", string(debug.Stack())))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "внутренняя ошибка сервера"})
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics - middleware для сбора метрик HTTP-запросов.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Для несуществующих маршрутов шаблон пустой, объединяем их в одну группу
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit - ограничение частоты запросов для группы маршрутов. Ключ корзины -
+// ID клиента, если запрос авторизован, иначе IP-адрес. Если правила для группы
+// нет, используется правило default; если нет и его, ограничение не применяется.
+// При недоступности хранилища запрос пропускается, чтобы не блокировать API.
+func RateLimit(store ratelimit.Store, rules ratelimit.Rules, group string) gin.HandlerFunc {
+	limit, ok := rules.For(group)
+	if !ok {
+		return func(c *gin.Context) { c.Next() }
+	}
+	policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(int(limit.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		key := group + ":ip:" + c.ClientIP()
+		if id, ok := CustomerID(c); ok {
+			key = group + ":customer:" + strconv.FormatInt(id, 10)
+		}
+
+		res, err := store.Take(c.Request.Context(), key, limit)
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Warn("Ошибка ограничения частоты запросов", slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+		if !res.Allowed {
+			h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "слишком много запросов, повторите позже"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
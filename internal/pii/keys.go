@@ -0,0 +1,82 @@
+package pii
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"VapeShop-ClientAPI/internal/config"
+)
+
+// keyFile - формат файла ключей (PII_KEYFILE):
+//
+//	{"primary_key_id": "2025-02", "keys": {"2025-01": "<base64>", "2025-02": "<base64>"}, "blind_index_key": "<base64>"}
+type keyFile struct {
+	PrimaryKeyID  string            `json:"primary_key_id"`
+	Keys          map[string]string `json:"keys"`
+	BlindIndexKey string            `json:"blind_index_key"`
+}
+
+// Load - создание шифратора по конфигурации. Если задан PII_KEYFILE, ключи
+// читаются из файла, иначе из PII_KEYS вида "kid1:<base64>,kid2:<base64>".
+func Load(cfg config.PII) (*Cipher, error) {
+	kf := keyFile{
+		PrimaryKeyID:  cfg.PrimaryKeyID,
+		Keys:          make(map[string]string),
+		BlindIndexKey: cfg.BlindIndexKey,
+	}
+
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("pii: ошибка чтения файла ключей: %w", err)
+		}
+		if err := json.Unmarshal(data, &kf); err != nil {
+			return nil, fmt.Errorf("pii: ошибка разбора файла ключей: %w", err)
+		}
+	} else {
+		for i, item := range strings.Split(cfg.Keys, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			kid, key, ok := strings.Cut(item, ":")
+			if !ok {
+				// Без разделителя вся запись может оказаться ключом, поэтому в ошибке только номер
+				return nil, fmt.Errorf("pii: неверный формат ключа №%d в PII_KEYS, ожидается kid:base64", i+1)
+			}
+			kf.Keys[strings.TrimSpace(kid)] = strings.TrimSpace(key)
+		}
+	}
+
+	if len(kf.Keys) == 0 {
+		return nil, errors.New("pii: не заданы ключи шифрования (PII_KEYS или PII_KEYFILE)")
+	}
+
+	keys := make(map[string][]byte, len(kf.Keys))
+	for kid, encoded := range kf.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("pii: ключ %q не в формате base64: %w", kid, err)
+		}
+		keys[kid] = key
+	}
+
+	// Единственный ключ считается основным, даже если PII_PRIMARY_KEY_ID не задан
+	primary := kf.PrimaryKeyID
+	if primary == "" && len(keys) == 1 {
+		for kid := range keys {
+			primary = kid
+		}
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(kf.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("pii: ключ слепого индекса не в формате base64: %w", err)
+	}
+
+	return New(keys, primary, indexKey)
+}
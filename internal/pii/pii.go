@@ -0,0 +1,277 @@
+// Package pii - шифрование персональных данных на уровне полей.
+//
+// Используется конвертное шифрование: каждое значение шифруется собственным
+// случайным ключом данных (AES-256-GCM), а ключ данных - мастер-ключом с
+// идентификатором kid. Сохраняемое значение имеет вид
+//
+//	pii:v1:<kid>:<зашифрованный ключ данных>:<зашифрованное значение>
+//
+// При ротации достаточно перешифровать ключ данных новым мастер-ключом,
+// само значение не меняется. Для поиска по зашифрованным полям используется
+// слепой индекс - HMAC-SHA256 от нормализованного значения.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	prefix     = "pii:v1:"
+	keySize    = 32
+	minIdxSize = 32
+)
+
+var validKeyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ErrUnknownKey - значение зашифровано ключом, которого нет в конфигурации.
+var ErrUnknownKey = errors.New("pii: неизвестный ключ шифрования")
+
+// Cipher - шифрование полей набором мастер-ключей. Новые значения шифруются
+// основным ключом, расшифровка возможна любым ключом из набора.
+type Cipher struct {
+	primary  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// New - создание шифратора. keys - мастер-ключи по идентификаторам (32 байта),
+// primary - идентификатор ключа для новых значений, indexKey - ключ слепого индекса.
+func New(keys map[string][]byte, primary string, indexKey []byte) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("pii: не задано ни одного ключа шифрования")
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("pii: основной ключ %q не найден среди ключей", primary)
+	}
+	if len(indexKey) < minIdxSize {
+		return nil, fmt.Errorf("pii: ключ слепого индекса должен быть не короче %d байт", minIdxSize)
+	}
+
+	c := &Cipher{
+		primary:  primary,
+		keys:     make(map[string]cipher.AEAD, len(keys)),
+		indexKey: indexKey,
+	}
+	for kid, key := range keys {
+		if !validKeyID.MatchString(kid) {
+			return nil, fmt.Errorf("pii: недопустимый идентификатор ключа %q", kid)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("pii: ключ %q должен быть длиной %d байт", kid, keySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		c.keys[kid] = aead
+	}
+	return c, nil
+}
+
+// PrimaryKeyID - идентификатор ключа, которым шифруются новые значения.
+func (c *Cipher) PrimaryKeyID() string {
+	return c.primary
+}
+
+// PrimaryPrefix - начало значений, зашифрованных основным ключом; для отбора
+// в SQL значений, которые нужно перешифровать (NOT LIKE PrimaryPrefix() || '%').
+func (c *Cipher) PrimaryPrefix() string {
+	return prefix + c.primary + ":"
+}
+
+// Encrypt - шифрование значения основным ключом.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("pii: ошибка генерации ключа данных: %w", err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(c.keys[c.primary], dek)
+	if err != nil {
+		return "", err
+	}
+	return format(c.primary, wrapped, payload), nil
+}
+
+// Decrypt - расшифровка значения. Значения без префикса считаются еще
+// не зашифрованными и возвращаются как есть.
+func (c *Cipher) Decrypt(stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+	_, dek, payload, err := c.open(stored)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := unseal(aead, payload)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// EncryptPtr - шифрование значения, допускающего NULL.
+func (c *Cipher) EncryptPtr(plaintext *string) (*string, error) {
+	if plaintext == nil {
+		return nil, nil
+	}
+	v, err := c.Encrypt(*plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecryptPtr - расшифровка значения, допускающего NULL.
+func (c *Cipher) DecryptPtr(stored *string) (*string, error) {
+	if stored == nil {
+		return nil, nil
+	}
+	v, err := c.Decrypt(*stored)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// EncryptBytes - шифрование двоичных данных (файлов); результат в том же
+// текстовом формате, что и у Encrypt.
+func (c *Cipher) EncryptBytes(plaintext []byte) ([]byte, error) {
+	v, err := c.Encrypt(string(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v), nil
+}
+
+// DecryptBytes - расшифровка данных, зашифрованных EncryptBytes. Данные без
+// префикса считаются еще не зашифрованными и возвращаются как есть.
+func (c *Cipher) DecryptBytes(stored []byte) ([]byte, error) {
+	if !IsEncrypted(string(stored[:min(len(stored), len(prefix))])) {
+		return stored, nil
+	}
+	v, err := c.Decrypt(string(stored))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v), nil
+}
+
+// Rewrap - приведение значения к основному ключу: открытый текст шифруется,
+// а у значений, зашифрованных другим ключом, перешифровывается только ключ данных.
+// Возвращает false, если значение менять не нужно.
+func (c *Cipher) Rewrap(stored string) (string, bool, error) {
+	if !IsEncrypted(stored) {
+		v, err := c.Encrypt(stored)
+		return v, err == nil, err
+	}
+	kid, dek, payload, err := c.open(stored)
+	if err != nil {
+		return "", false, err
+	}
+	if kid == c.primary {
+		return stored, false, nil
+	}
+	wrapped, err := seal(c.keys[c.primary], dek)
+	if err != nil {
+		return "", false, err
+	}
+	return format(c.primary, wrapped, payload), true, nil
+}
+
+// BlindIndex - слепой индекс для поиска по точному совпадению без учета регистра.
+func (c *Cipher) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted - зашифровано ли значение.
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
+
+// open - разбор значения и расшифровка ключа данных.
+func (c *Cipher) open(stored string) (kid string, dek, payload []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(stored, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("pii: неверный формат зашифрованного значения")
+	}
+	kid = parts[0]
+	kek, ok := c.keys[kid]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("pii: неверный формат ключа данных: %w", err)
+	}
+	payload, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("pii: неверный формат значения: %w", err)
+	}
+	dek, err = unseal(kek, wrapped)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return kid, dek, payload, nil
+}
+
+func format(kid string, wrapped, payload []byte) string {
+	return prefix + kid + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(payload)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("pii: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("pii: %w", err)
+	}
+	return aead, nil
+}
+
+// seal - шифрование со случайным nonce, который записывается перед шифротекстом.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("pii: ошибка генерации nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func unseal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("pii: зашифрованное значение слишком короткое")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("pii: не удалось расшифровать значение")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,252 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"VapeShop-ClientAPI/internal/config"
+)
+
+var (
+	testKey1     = bytes.Repeat([]byte{1}, keySize)
+	testKey2     = bytes.Repeat([]byte{2}, keySize)
+	testIndexKey = bytes.Repeat([]byte{3}, minIdxSize)
+)
+
+func newTestCipher(t *testing.T, primary string, keys map[string][]byte) *Cipher {
+	t.Helper()
+	c, err := New(keys, primary, testIndexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string][]byte{"k1": testKey1})
+	for _, plaintext := range []string{"", "ivan@example.com", "Москва, ул. Тверская, д. 1", "a:b:c"} {
+		stored, err := c.Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(stored, c.PrimaryPrefix()) {
+			t.Errorf("значение %q без префикса %q", stored, c.PrimaryPrefix())
+		}
+		if plaintext != "" && strings.Contains(stored, plaintext) {
+			t.Errorf("значение %q содержит открытый текст", stored)
+		}
+		got, err := c.Decrypt(stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != plaintext {
+			t.Errorf("Decrypt = %q, ожидалось %q", got, plaintext)
+		}
+	}
+
+	// Один и тот же текст шифруется по-разному
+	a, _ := c.Encrypt("same")
+	b, _ := c.Encrypt("same")
+	if a == b {
+		t.Error("повторное шифрование дало то же значение")
+	}
+}
+
+func TestEncryptDecryptBytes(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string][]byte{"k1": testKey1})
+	content := []byte{0xff, 0xd8, 0x00, 0x01, 'p', 'i', 'i'}
+	stored, err := c.EncryptBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.DecryptBytes(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("DecryptBytes = %v, ожидалось %v", got, content)
+	}
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string][]byte{"k1": testKey1})
+	for _, legacy := range []string{"", "ivan@example.com", "pii", "pii:v0:k1:a:b"} {
+		got, err := c.Decrypt(legacy)
+		if err != nil {
+			t.Fatalf("Decrypt(%q): %v", legacy, err)
+		}
+		if got != legacy {
+			t.Errorf("Decrypt(%q) = %q", legacy, got)
+		}
+	}
+	for _, legacy := range [][]byte{nil, []byte("p"), []byte("\x89PNG")} {
+		got, err := c.DecryptBytes(legacy)
+		if err != nil {
+			t.Fatalf("DecryptBytes(%q): %v", legacy, err)
+		}
+		if !bytes.Equal(got, legacy) {
+			t.Errorf("DecryptBytes(%q) = %q", legacy, got)
+		}
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := newTestCipher(t, "k1", map[string][]byte{"k1": testKey1})
+	rotated := newTestCipher(t, "k2", map[string][]byte{"k1": testKey1, "k2": testKey2})
+
+	stored, err := old.Encrypt("+7 900 000-00-00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := rotated.Encrypt("ivan@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		stored      string
+		wantChanged bool
+		want        string
+	}{
+		{"значение старого ключа", stored, true, "+7 900 000-00-00"},
+		{"значение основного ключа", plaintext, false, "ivan@example.com"},
+		{"открытый текст", "Адрес", true, "Адрес"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrapped, changed, err := rotated.Rewrap(tt.stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, ожидалось %v", changed, tt.wantChanged)
+			}
+			if !strings.HasPrefix(rewrapped, rotated.PrimaryPrefix()) {
+				t.Errorf("значение %q не зашифровано основным ключом", rewrapped)
+			}
+			got, err := rotated.Decrypt(rewrapped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+
+	// Перешифровывается только ключ данных, само значение не меняется
+	rewrapped, _, _ := rotated.Rewrap(stored)
+	if payload := func(s string) string { return s[strings.LastIndex(s, ":"):] }; payload(rewrapped) != payload(stored) {
+		t.Error("при ротации изменилось зашифрованное значение")
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string][]byte{"k1": testKey1})
+	other := newTestCipher(t, "k2", map[string][]byte{"k2": testKey2})
+	stored, err := c.Encrypt("ivan@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(stored, prefix), ":")
+	payload, _ := base64.RawStdEncoding.DecodeString(parts[2])
+	payload[len(payload)-1] ^= 1
+	tampered := prefix + parts[0] + ":" + parts[1] + ":" + base64.RawStdEncoding.EncodeToString(payload)
+	wrapped, _ := base64.RawStdEncoding.DecodeString(parts[1])
+	wrapped[len(wrapped)-1] ^= 1
+	tamperedKey := prefix + parts[0] + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + parts[2]
+
+	tests := []struct {
+		name    string
+		cipher  *Cipher
+		stored  string
+		wantErr error
+	}{
+		{"измененное значение", c, tampered, nil},
+		{"измененный ключ данных", c, tamperedKey, nil},
+		{"неизвестный ключ", other, stored, ErrUnknownKey},
+		{"подмена идентификатора ключа", other, strings.Replace(stored, ":k1:", ":k2:", 1), nil},
+		{"неверный формат", c, prefix + "k1:abc", nil},
+		{"неверный base64", c, prefix + "k1:!!!:!!!", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cipher.Decrypt(tt.stored)
+			if err == nil {
+				t.Fatalf("Decrypt = %q, ожидалась ошибка", got)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ошибка = %v, ожидалась %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	c := newTestCipher(t, "k1", map[string][]byte{"k1": testKey1})
+	other, err := New(map[string][]byte{"k1": testKey1}, "k1", bytes.Repeat([]byte{4}, minIdxSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := c.BlindIndex("ivan@example.com")
+
+	tests := []struct {
+		value string
+		same  bool
+	}{
+		{"ivan@example.com", true},
+		{"  Ivan@Example.COM\t", true},
+		{"IVAN@EXAMPLE.COM", true},
+		{"ivan@example.org", false},
+		{"ivan @example.com", false},
+	}
+	for _, tt := range tests {
+		if got := c.BlindIndex(tt.value); (got == want) != tt.same {
+			t.Errorf("BlindIndex(%q) совпадает = %v, ожидалось %v", tt.value, got == want, tt.same)
+		}
+	}
+	if len(want) != 64 {
+		t.Errorf("длина индекса = %d, ожидалось 64", len(want))
+	}
+	if other.BlindIndex("ivan@example.com") == want {
+		t.Error("индекс не зависит от ключа")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testKey1)
+	indexKey := base64.StdEncoding.EncodeToString(testIndexKey)
+
+	tests := []struct {
+		name    string
+		cfg     config.PII
+		wantErr string
+	}{
+		{"один ключ без основного", config.PII{Keys: "k1:" + key, BlindIndexKey: indexKey}, ""},
+		{"ключ без идентификатора", config.PII{Keys: "k1:" + key + "," + key, PrimaryKeyID: "k1", BlindIndexKey: indexKey}, "ключа №2"},
+		{"нет ключей", config.PII{BlindIndexKey: indexKey}, "не заданы ключи"},
+		{"нет основного ключа", config.PII{Keys: "k1:" + key + ",k2:" + key, BlindIndexKey: indexKey}, "основной ключ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ошибка = %v, ожидалась %q", err, tt.wantErr)
+			}
+			// Значение ключа не должно попадать в журнал запуска
+			if strings.Contains(err.Error(), key) {
+				t.Errorf("ошибка содержит ключ: %v", err)
+			}
+		})
+	}
+}
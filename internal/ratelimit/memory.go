@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// MemoryStore - хранилище корзин в памяти процесса. Подходит для одного экземпляра сервиса.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryStore - создание хранилища в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take - попытка взять токен из корзины.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), last: now}
+		s.buckets[key] = b
+	}
+
+	var res Result
+	b.tokens, res = bucketState(b.tokens, b.last, now, limit)
+	b.last = now
+	b.period = limit.Period
+	return res, nil
+}
+
+// Cleanup - периодическое удаление корзин, которые давно не использовались
+// и успели полностью восстановиться. Запускается как фоновая задача сервера.
+func (s *MemoryStore) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			now := s.now()
+			for key, b := range s.buckets {
+				if now.Sub(b.last) > b.period {
+					delete(s.buckets, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestMemoryStore - хранилище в памяти с управляемыми часами.
+func newTestMemoryStore() (*MemoryStore, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	store, _ := newTestMemoryStore()
+	ctx := context.Background()
+	limit := Limit{Requests: 3, Period: time.Minute}
+
+	for i := 0; i < limit.Requests; i++ {
+		res, _ := store.Take(ctx, "k", limit)
+		if !res.Allowed {
+			t.Fatalf("запрос %d отклонен", i+1)
+		}
+		if want := limit.Requests - i - 1; res.Remaining != want {
+			t.Errorf("запрос %d: Remaining = %d, ожидалось %d", i+1, res.Remaining, want)
+		}
+	}
+
+	res, _ := store.Take(ctx, "k", limit)
+	if res.Allowed {
+		t.Fatal("запрос сверх лимита разрешен")
+	}
+	// Токен восстанавливается за Period/Requests = 20s, корзина целиком - за Period
+	if res.RetryAfter != 20*time.Second {
+		t.Errorf("RetryAfter = %v, ожидалось 20s", res.RetryAfter)
+	}
+	if res.Reset != time.Minute {
+		t.Errorf("Reset = %v, ожидалось 1m", res.Reset)
+	}
+	if res, _ := store.Take(ctx, "other", limit); !res.Allowed {
+		t.Error("корзина другого ключа не должна зависеть от первой")
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	store, now := newTestMemoryStore()
+	ctx := context.Background()
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	store.Take(ctx, "k", limit)
+	store.Take(ctx, "k", limit)
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		allowed bool
+	}{
+		{"токен еще не восстановился", 29 * time.Second, false},
+		{"токен восстановился", time.Second, true},
+		{"отклоненная попытка не тратит токен", 0, false},
+		// За час корзина наполняется только до емкости
+		{"первый запрос после простоя", time.Hour, true},
+		{"второй запрос после простоя", 0, true},
+		{"третий запрос после простоя", 0, false},
+	}
+	for _, tt := range tests {
+		*now = now.Add(tt.advance)
+		if res, _ := store.Take(ctx, "k", limit); res.Allowed != tt.allowed {
+			t.Errorf("%s: Allowed = %v, ожидалось %v", tt.name, res.Allowed, tt.allowed)
+		}
+	}
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	store, now := newTestMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store.Take(ctx, "old", Limit{Requests: 1, Period: time.Second})
+	store.Take(ctx, "fresh", Limit{Requests: 1, Period: time.Hour})
+	*now = now.Add(time.Minute)
+
+	go store.Cleanup(ctx, time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		_, oldExists := store.buckets["old"]
+		_, freshExists := store.buckets["fresh"]
+		store.mu.Unlock()
+		if !freshExists {
+			t.Fatal("удалена корзина, которая еще не восстановилась")
+		}
+		if !oldExists {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("восстановившаяся корзина не удалена")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(" auth:10/1m, catalog : 300/1m ,default:120/30s,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Rules{
+		"auth":    {Requests: 10, Period: time.Minute},
+		"catalog": {Requests: 300, Period: time.Minute},
+		"default": {Requests: 120, Period: 30 * time.Second},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("правила = %v, ожидалось %v", rules, want)
+	}
+	for group, limit := range want {
+		if rules[group] != limit {
+			t.Errorf("%s = %v, ожидалось %v", group, rules[group], limit)
+		}
+	}
+	if l, ok := rules.For("admin"); !ok || l != want["default"] {
+		t.Errorf("For(admin) = %v, %v, ожидался лимит по умолчанию", l, ok)
+	}
+
+	for _, invalid := range []string{"auth", "auth:10", "auth:0/1m", "auth:x/1m", "auth:10/0s", "auth:10/minute"} {
+		if _, err := ParseRules(invalid); err == nil {
+			t.Errorf("ParseRules(%q): ожидалась ошибка", invalid)
+		}
+	}
+}
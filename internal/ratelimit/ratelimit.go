@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGroup - группа маршрутов, лимит которой применяется, если для группы нет своего правила
+const DefaultGroup = "default"
+
+// Limit - параметры корзины токенов: не более Requests запросов за Period
+// с возможностью всплеска до Requests запросов.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ratePerSecond - скорость пополнения корзины.
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result - результат попытки взять токен.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Время до полного восстановления корзины
+	Reset time.Duration
+	// Время до появления следующего токена (только если запрос отклонен)
+	RetryAfter time.Duration
+}
+
+// Store - хранилище состояния корзин токенов.
+type Store interface {
+	// Take - попытка взять один токен из корзины с ключом key.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Rules - лимиты по группам маршрутов.
+type Rules map[string]Limit
+
+// For - лимит группы или лимит по умолчанию.
+func (r Rules) For(group string) (Limit, bool) {
+	if l, ok := r[group]; ok {
+		return l, true
+	}
+	l, ok := r[DefaultGroup]
+	return l, ok
+}
+
+// ParseRules - разбор правил вида "auth:10/1m,catalog:300/1m,default:120/1m".
+func ParseRules(s string) (Rules, error) {
+	rules := Rules{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		group, spec, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("неверное правило ограничения запросов: %q", part)
+		}
+		count, period, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("неверное правило ограничения запросов: %q", part)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("неверное количество запросов в правиле %q", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(period))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("неверный период в правиле %q", part)
+		}
+
+		rules[strings.TrimSpace(group)] = Limit{Requests: n, Period: d}
+	}
+	return rules, nil
+}
+
+// bucketState - вычисление состояния корзины после пополнения и попытки взять токен.
+// Используется хранилищем в памяти; Redis-хранилище выполняет тот же расчет в Lua.
+func bucketState(tokens float64, last, now time.Time, limit Limit) (float64, Result) {
+	capacity := float64(limit.Requests)
+	rate := limit.ratePerSecond()
+
+	elapsed := now.Sub(last).Seconds()
+	if elapsed > 0 {
+		tokens = min(capacity, tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = secondsToDuration((capacity - tokens) / rate)
+	return tokens, res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Корзина хранится в хэше {tokens, ts}. Расчет совпадает с bucketState и выполняется
+// атомарно на стороне Redis, поэтому лимит общий для всех экземпляров сервиса.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+  tokens = capacity
+  ts = now
+end
+
+local elapsed = (now - ts) / 1000
+if elapsed > 0 then
+  tokens = math.min(capacity, tokens + elapsed * rate)
+end
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore - хранилище корзин в Redis (или совместимом сервере).
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore - создание Redis-хранилища. Ключи корзин получают префикс prefix.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Take - попытка взять токен из корзины.
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	rate := limit.ratePerSecond()
+	// Корзина удаляется после полного восстановления
+	ttl := limit.Period + time.Second
+
+	raw, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		limit.Requests, rate, time.Now().UnixMilli(), ttl.Milliseconds()).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ошибка ограничения запросов в Redis: %w", err)
+	}
+	if len(raw) != 2 {
+		return Result{}, fmt.Errorf("неожиданный ответ Redis: %v", raw)
+	}
+
+	allowed, _ := raw[0].(int64)
+	str, _ := raw[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("неожиданный ответ Redis: %v", raw)
+	}
+
+	res := Result{
+		Allowed:   allowed == 1,
+		Limit:     limit.Requests,
+		Remaining: int(math.Max(0, tokens)),
+		Reset:     secondsToDuration((float64(limit.Requests) - tokens) / rate),
+	}
+	if !res.Allowed {
+		res.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client, "rl:"), mr
+}
+
+func TestRedisStoreTake(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	limit := Limit{Requests: 3, Period: time.Minute}
+
+	for i := 0; i < limit.Requests; i++ {
+		res, err := store.Take(ctx, "auth:ip:10.0.0.1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed {
+			t.Fatalf("запрос %d отклонен", i+1)
+		}
+		if want := limit.Requests - i - 1; res.Remaining != want {
+			t.Errorf("запрос %d: Remaining = %d, ожидалось %d", i+1, res.Remaining, want)
+		}
+		if res.Limit != limit.Requests {
+			t.Errorf("Limit = %d, ожидалось %d", res.Limit, limit.Requests)
+		}
+	}
+
+	res, err := store.Take(ctx, "auth:ip:10.0.0.1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed {
+		t.Fatal("запрос сверх лимита разрешен")
+	}
+	if res.Remaining != 0 {
+		t.Errorf("Remaining = %d, ожидалось 0", res.Remaining)
+	}
+	// Токен восстанавливается за Period/Requests = 20s
+	if res.RetryAfter <= 0 || res.RetryAfter > 20*time.Second {
+		t.Errorf("RetryAfter = %v, ожидалось (0, 20s]", res.RetryAfter)
+	}
+	if res.Reset <= 0 || res.Reset > limit.Period {
+		t.Errorf("Reset = %v, ожидалось (0, %v]", res.Reset, limit.Period)
+	}
+}
+
+func TestRedisStoreSeparateKeys(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	limit := Limit{Requests: 1, Period: time.Minute}
+
+	if res, err := store.Take(ctx, "catalog:customer:1", limit); err != nil || !res.Allowed {
+		t.Fatalf("первый запрос клиента 1: %+v, %v", res, err)
+	}
+	if res, err := store.Take(ctx, "catalog:customer:1", limit); err != nil || res.Allowed {
+		t.Fatalf("второй запрос клиента 1: %+v, %v", res, err)
+	}
+	if res, err := store.Take(ctx, "catalog:customer:2", limit); err != nil || !res.Allowed {
+		t.Fatalf("запрос клиента 2 не должен зависеть от корзины клиента 1: %+v, %v", res, err)
+	}
+}
+
+func TestRedisStoreRefill(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	limit := Limit{Requests: 1, Period: 200 * time.Millisecond}
+
+	if res, err := store.Take(ctx, "k", limit); err != nil || !res.Allowed {
+		t.Fatalf("первый запрос: %+v, %v", res, err)
+	}
+	if res, err := store.Take(ctx, "k", limit); err != nil || res.Allowed {
+		t.Fatalf("второй запрос: %+v, %v", res, err)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if res, err := store.Take(ctx, "k", limit); err != nil || !res.Allowed {
+		t.Fatalf("запрос после восстановления корзины: %+v, %v", res, err)
+	}
+}
+
+func TestRedisStoreKeyExpiry(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	limit := Limit{Requests: 5, Period: time.Minute}
+
+	if _, err := store.Take(context.Background(), "auth:ip:10.0.0.1", limit); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "rl:auth:ip:10.0.0.1"
+	if !mr.Exists(key) {
+		t.Fatalf("ключ %q не создан; ключи: %v", key, mr.Keys())
+	}
+	if ttl := mr.TTL(key); ttl <= 0 || ttl > limit.Period+time.Second {
+		t.Errorf("TTL = %v, ожидалось (0, %v]", ttl, limit.Period+time.Second)
+	}
+
+	// После полного восстановления корзина не нужна и удаляется
+	mr.FastForward(limit.Period + time.Second)
+	if mr.Exists(key) {
+		t.Error("ключ не удален после истечения TTL")
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	mr.Close()
+
+	if _, err := store.Take(context.Background(), "k", Limit{Requests: 1, Period: time.Minute}); err == nil {
+		t.Fatal("ожидалась ошибка при недоступном Redis")
+	}
+}
@@ -1,71 +1,445 @@
 package router
 
 import (
+	"VapeShop-ClientAPI/internal/auth"
 	"VapeShop-ClientAPI/internal/config"
 	"VapeShop-ClientAPI/internal/controllers"
 	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/docs"
+	"VapeShop-ClientAPI/internal/mailer"
 	"VapeShop-ClientAPI/internal/middleware"
+	"VapeShop-ClientAPI/internal/pii"
+	"VapeShop-ClientAPI/internal/ratelimit"
 	"VapeShop-ClientAPI/internal/services"
+	"VapeShop-ClientAPI/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// Controllers - контроллеры, маршруты которых регистрирует RegisterRoutes.
+type Controllers struct {
+	Category  *controllers.CategoryController
+	Product   *controllers.ProductController
+	Purchase  *controllers.PurchaseController
+	Auth      *controllers.AuthController
+	Account   *controllers.AccountController
+	AgeCheck  *controllers.AgeVerificationController
+	Region    *controllers.RegionRuleController
+	Customer  *controllers.CustomerController
+	Privacy   *controllers.PrivacyController
+	Audit     *controllers.AuditController
+	Attribute *controllers.AttributeController
+	Variant   *controllers.VariantController
+	Image     *controllers.ImageController
+	Import    *controllers.ImportController
+	Export    *controllers.ExportController
+	Review    *controllers.ReviewController
+}
+
+// Worker - фоновая задача, работающая до отмены контекста.
+type Worker func(ctx context.Context)
+
 type Server struct {
+	cfg             *config.Config
+	db              *db.DB
 	router          *gin.Engine
+	workers         map[string]Worker
+	closers         []func() error
 	categoryService services.CategoryService
 	productService  services.ProductService
 	purchaseService services.PurchaseService
 }
 
-func NewServer(cfg *config.Config, db *db.DB) *Server {
-	router := gin.Default()
+func NewServer(cfg *config.Config, db *db.DB) (*Server, error) {
+	if !cfg.Debug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	// gin.Context передается в сервисы как context.Context, поэтому значения
+	// (логгер, request ID) должны браться из контекста запроса
+	router.ContextWithFallback = true
+	// ClientIP используется как ключ ограничения частоты запросов и блокировки входа,
+	// поэтому X-Forwarded-For принимается только от известных прокси
+	if err := router.SetTrustedProxies(trustedProxies(cfg.TrustedProxies)); err != nil {
+		return nil, fmt.Errorf("неверный SERVER_TRUSTED_PROXIES: %w", err)
+	}
 
 	// Middleware
-	router.Use(middleware.Cors())
-	router.Use(middleware.AuthMiddleware())
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		// Служебные запросы оркестратора и Prometheus не трассируем
+		switch r.URL.Path {
+		case "/healthz", "/readyz", "/metrics":
+			return false
+		}
+		return true
+	})))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Logger())
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Cors(cfg.CORS))
+	router.Use(middleware.Metrics())
+
+	// Метрики пула соединений (open, in_use, wait_count и др.)
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, cfg.Database.Name))
+
+	s := &Server{
+		cfg:     cfg,
+		db:      db,
+		router:  router,
+		workers: make(map[string]Worker),
+	}
+
+	rateLimit, err := s.newRateLimiter()
+	if err != nil {
+		return nil, err
+	}
+
+	mail, err := newMailer(cfg.Mailer)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := pii.Load(cfg.PII)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	categoryService := services.NewCategoryService(db.DB)
-	productService := services.NewProductService(db.DB)
-	purchaseService := services.NewPurchaseService(db.DB)
+	categoryService := services.NewCategoryService(db)
+	productService := services.NewProductService(db)
+	purchaseService := services.NewPurchaseService(db, cfg.AgeCheck.MinimumAge, cipher)
+	healthService := services.NewHealthService(db, cfg.HealthCheckTimeout)
+	authService := services.NewAuthService(db, cfg.JWT, cfg.Login, cipher)
+	accountService := services.NewAccountService(db, mail, cfg.Account, cipher)
+	ageVerificationService := services.NewAgeVerificationService(db, cipher)
+	regionRuleService := services.NewRegionRuleService(db)
+	customerService := services.NewCustomerService(db, cipher)
+	privacyService := services.NewPrivacyService(db, cipher, cfg.Privacy.ErasureGracePeriod)
+	piiService := services.NewPIIService(db, cipher)
+	auditService := services.NewAuditService(db)
+	attributeService := services.NewAttributeService(db)
+	variantService := services.NewVariantService(db)
+	imageService := services.NewImageService(db, blobs, cfg.Images)
+	importService := services.NewImportService(db)
+	exportService := services.NewExportService(db)
+	reviewService := services.NewReviewService(db)
+	purgeService := services.NewPurgeService(db, blobs, cfg.Catalog.PurgeRetention)
 
 	// Controllers
-	categoryController := controllers.NewCategoryController(categoryService)
-	productController := controllers.NewProductController(productService)
-	purchaseController := controllers.NewPurchaseController(purchaseService)
+	healthController := controllers.NewHealthController(healthService)
+	ctrls := Controllers{
+		Category:  controllers.NewCategoryController(categoryService),
+		Product:   controllers.NewProductController(productService),
+		Purchase:  controllers.NewPurchaseController(purchaseService),
+		Auth:      controllers.NewAuthController(authService),
+		Account:   controllers.NewAccountController(accountService),
+		AgeCheck:  controllers.NewAgeVerificationController(ageVerificationService, cfg.AgeCheck.DocumentMaxSize),
+		Region:    controllers.NewRegionRuleController(regionRuleService),
+		Customer:  controllers.NewCustomerController(customerService),
+		Privacy:   controllers.NewPrivacyController(privacyService),
+		Audit:     controllers.NewAuditController(auditService),
+		Attribute: controllers.NewAttributeController(attributeService),
+		Variant:   controllers.NewVariantController(variantService),
+		Image:     controllers.NewImageController(imageService, cfg.Images.MaxSize, cfg.Images.CacheMaxAge),
+		Import:    controllers.NewImportController(importService, cfg.Import.MaxSize),
+		Export:    controllers.NewExportController(exportService),
+		Review:    controllers.NewReviewController(reviewService),
+	}
+
+	s.AddWorker("privacy-erasure", func(ctx context.Context) {
+		privacyService.RunErasures(ctx, cfg.Privacy.ErasureInterval)
+	})
+	s.AddWorker("pii-reencrypt", func(ctx context.Context) {
+		piiService.RunReencryption(ctx, cfg.PII.ReencryptInterval)
+	})
+	s.AddWorker("catalog-purge", func(ctx context.Context) {
+		purgeService.RunPurge(ctx, cfg.Catalog.PurgeInterval)
+	})
+	s.AddWorker("product-import", func(ctx context.Context) {
+		importService.RunImports(ctx, cfg.Import.Interval)
+	})
+
+	RegisterHealthRoutes(router, healthController)
+	RegisterRoutes(router, ctrls, middleware.AuthMiddleware(cfg.JWT.Secret), rateLimit)
+	// Описание маршрутов в спецификации проверяет TestRoutesDocumented
+	docs.Register(router)
+
+	s.categoryService = categoryService
+	s.productService = productService
+	s.purchaseService = purchaseService
+	return s, nil
+}
+
+// trustedProxies - список прокси из конфигурации; значение из переменной
+// окружения может прийти одной строкой через запятую.
+func trustedProxies(values []string) []string {
+	var proxies []string
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				proxies = append(proxies, p)
+			}
+		}
+	}
+	return proxies
+}
 
-	RegisterRoutes(router, categoryController, productController, purchaseController)
+// newRateLimiter - создание хранилища лимитов по конфигурации. Возвращает фабрику
+// middleware для групп маршрутов; при выключенном ограничении middleware ничего не делает.
+func (s *Server) newRateLimiter() (func(group string) gin.HandlerFunc, error) {
+	if !s.cfg.RateLimit.Enabled {
+		return func(string) gin.HandlerFunc { return func(c *gin.Context) { c.Next() } }, nil
+	}
+
+	rules, err := ratelimit.ParseRules(s.cfg.RateLimit.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var store ratelimit.Store
+	switch s.cfg.RateLimit.Store {
+	case "", "memory":
+		memory := ratelimit.NewMemoryStore()
+		s.AddWorker("ratelimit-cleanup", func(ctx context.Context) {
+			memory.Cleanup(ctx, time.Minute)
+		})
+		store = memory
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     s.cfg.Redis.Addr,
+			Password: s.cfg.Redis.Password,
+			DB:       s.cfg.Redis.DB,
+		})
+		s.closers = append(s.closers, client.Close)
+		store = ratelimit.NewRedisStore(client, "ratelimit:")
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище ограничения запросов: %s", s.cfg.RateLimit.Store)
+	}
+
+	return func(group string) gin.HandlerFunc {
+		return middleware.RateLimit(store, rules, group)
+	}, nil
+}
 
-	return &Server{
-		router:          router,
-		categoryService: categoryService,
-		productService:  productService,
-		purchaseService: purchaseService,
+// newMailer - создание способа отправки писем по конфигурации.
+func newMailer(cfg config.Mailer) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "", "file":
+		return mailer.NewFileMailer(cfg.FileDir, cfg.From), nil
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("неизвестный способ отправки писем: %s", cfg.Driver)
 	}
 }
 
-func (s *Server) Run(addr string) error {
-	return http.ListenAndServe(addr, s.router)
+// newBlobStore - создание хранилища файлов изображений по конфигурации.
+func newBlobStore(cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.Images.Store {
+	case "", "file":
+		return storage.NewFileStore(cfg.Images.Dir), nil
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PathStyle: cfg.S3.PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище изображений: %s", cfg.Images.Store)
+	}
+}
+
+// AddWorker - регистрация фоновой задачи, которая запускается вместе с сервером
+// и останавливается после завершения HTTP-запросов.
+func (s *Server) AddWorker(name string, w Worker) {
+	s.workers[name] = w
+}
+
+// Run - запуск HTTP-сервера и фоновых задач. Блокируется до отмены ctx
+// (например, по SIGINT/SIGTERM), после чего выполняет остановку в порядке:
+// HTTP-сервер (с ожиданием активных запросов), фоновые задачи, внешние клиенты, база данных.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.router,
+		ReadHeaderTimeout: s.cfg.Timeout,
+		ReadTimeout:       s.cfg.Timeout,
+		WriteTimeout:      s.cfg.Timeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var wg sync.WaitGroup
+	for name, w := range s.workers {
+		wg.Add(1)
+		go func(name string, w Worker) {
+			defer wg.Done()
+			slog.Info("Фоновая задача запущена", slog.String("worker", name))
+			w(workersCtx)
+			slog.Info("Фоновая задача остановлена", slog.String("worker", name))
+		}(name, w)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Сервер запущен", slog.String("addr", addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("Получен сигнал остановки, ожидание завершения запросов",
+			slog.Duration("shutdown_timeout", s.cfg.ShutdownTimeout))
+	case err := <-serveErr:
+		runErr = fmt.Errorf("ошибка HTTP-сервера: %w", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		runErr = errors.Join(runErr, fmt.Errorf("ошибка остановки HTTP-сервера: %w", err))
+	}
+
+	stopWorkers()
+	wg.Wait()
+
+	for _, closeFn := range s.closers {
+		if err := closeFn(); err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+	}
+
+	if err := s.db.Close(); err != nil {
+		runErr = errors.Join(runErr, fmt.Errorf("ошибка закрытия базы данных: %w", err))
+	}
+
+	slog.Info("Сервер остановлен")
+	return runErr
+}
+
+// RegisterRoutes - маршруты API. Все маршруты, кроме входа и восстановления доступа, требуют авторизации;
+// rateLimit возвращает middleware ограничения запросов для группы маршрутов.
+func RegisterRoutes(router *gin.Engine, c Controllers, authMiddleware gin.HandlerFunc, rateLimit func(group string) gin.HandlerFunc) {
+	public := router.Group("/api/v1")
+	authGroup := public.Group("/auth", rateLimit("auth"))
+	authGroup.POST("/login", c.Auth.LoginHandler)
+	authGroup.POST("/password/forgot", c.Account.ForgotPasswordHandler)
+	authGroup.POST("/password/reset", c.Account.ResetPasswordHandler)
+	authGroup.POST("/email/verify", c.Account.VerifyEmailHandler)
+	// Файлы изображений загружаются тегами <img>, которые не передают заголовок Authorization
+	public.GET("/products/:id/images/:image_id/file", rateLimit("catalog"), c.Image.GetImageFileHandler)
+
+	v1 := router.Group("/api/v1", authMiddleware, middleware.AuditActor())
+
+	catalog := v1.Group("", rateLimit("catalog"))
+	// Изменение каталога доступно только администраторам: флаг age_restricted
+	// категории и характеристики товаров определяют, кому и где он продается
+	catalogAdmin := catalog.Group("", middleware.RequireRole(auth.RoleAdmin))
+	catalog.GET("/categories", c.Category.GetCategoriesHandler)
+	catalog.GET("/categories/tree", c.Category.GetCategoryTreeHandler)
+	catalogAdmin.POST("/categories", c.Category.CreateCategoriesHandler)
+	catalog.GET("/categories/:id", c.Category.GetCategoryByIDHandler)
+	catalogAdmin.PUT("/categories/:id", c.Category.UpdateCategoryHandler)
+	catalogAdmin.DELETE("/categories/:id", c.Category.DeleteCategoryHandler)
+	catalogAdmin.POST("/categories/:id/restore", c.Category.RestoreCategoryHandler)
+	catalog.GET("/categories/:id/attributes", c.Attribute.GetCategoryAttributesHandler)
+	catalogAdmin.PUT("/categories/:id/attributes", c.Attribute.SaveCategoryAttributesHandler)
+
+	catalog.GET("/products", c.Product.GetProductsHandler)
+	catalog.GET("/products/facets", c.Product.GetProductFacetsHandler)
+	catalogAdmin.POST("/products", c.Product.CreateProductHandler)
+	catalog.GET("/products/:id", c.Product.GetProductByIDHandler)
+	catalogAdmin.PUT("/products/:id", c.Product.UpdateProductHandler)
+	catalogAdmin.DELETE("/products/:id", c.Product.DeleteProductHandler)
+	catalogAdmin.POST("/products/:id/restore", c.Product.RestoreProductHandler)
+	catalog.GET("/products/:id/variants", c.Variant.GetVariantsHandler)
+	catalogAdmin.POST("/products/:id/variants", c.Variant.CreateVariantHandler)
+	catalogAdmin.PUT("/products/:id/variants/:variant_id", c.Variant.UpdateVariantHandler)
+	catalogAdmin.DELETE("/products/:id/variants/:variant_id", c.Variant.DeleteVariantHandler)
+	catalog.GET("/products/:id/images", c.Image.GetImagesHandler)
+	catalogAdmin.POST("/products/:id/images", c.Image.UploadImagesHandler)
+	catalogAdmin.PUT("/products/:id/images/order", c.Image.ReorderImagesHandler)
+	catalogAdmin.DELETE("/products/:id/images/:image_id", c.Image.DeleteImageHandler)
+	catalog.GET("/products/:id/reviews", c.Review.GetProductReviewsHandler)
+	catalog.POST("/products/:id/reviews", c.Review.CreateReviewHandler)
+
+	catalog.GET("/region-rules", c.Region.GetRegionRulesHandler)
+	catalog.GET("/region-rules/:region", c.Region.GetRegionRuleHandler)
+
+	v1.POST("/auth/email/verification", rateLimit("auth"), c.Account.SendEmailVerificationHandler)
+
+	account := v1.Group("", rateLimit("account"))
+	account.GET("/me", c.Customer.GetProfileHandler)
+	account.PATCH("/me", c.Customer.UpdateProfileHandler)
+	account.DELETE("/me", c.Privacy.RequestErasureHandler)
+	account.POST("/me/erasure/cancel", c.Privacy.CancelErasureHandler)
+	account.GET("/me/data-export", c.Privacy.ExportDataHandler)
+	account.GET("/me/addresses", c.Customer.GetAddressesHandler)
+	account.POST("/me/addresses", c.Customer.CreateAddressHandler)
+	account.GET("/me/addresses/:id", c.Customer.GetAddressHandler)
+	account.PUT("/me/addresses/:id", c.Customer.UpdateAddressHandler)
+	account.DELETE("/me/addresses/:id", c.Customer.DeleteAddressHandler)
+	account.POST("/me/addresses/:id/default", c.Customer.SetDefaultAddressHandler)
+	account.GET("/age-verification", c.AgeCheck.GetAgeVerificationHandler)
+	account.POST("/age-verification/documents", c.AgeCheck.SubmitDocumentHandler)
+
+	purchases := v1.Group("", rateLimit("purchases"))
+	purchases.GET("/purchases", c.Purchase.GetPurchasesHandler)
+	purchases.POST("/purchases", c.Purchase.CreatePurchaseHandler)
+	purchases.GET("/purchases/:id", c.Purchase.GetPurchaseByIDHandler)
+	purchases.PUT("/purchases/:id", c.Purchase.UpdatePurchaseHandler)
+	purchases.DELETE("/purchases/:id", c.Purchase.DeletePurchaseHandler)
+
+	admin := v1.Group("/admin", middleware.RequireRole(auth.RoleAdmin), rateLimit("admin"))
+	admin.POST("/customers/:id/unlock", c.Auth.UnlockCustomerHandler)
+	admin.GET("/security-events", c.Auth.GetSecurityEventsHandler)
+	admin.GET("/audit", c.Audit.GetAuditLogHandler)
+	admin.POST("/products/import", c.Import.ImportProductsHandler)
+	admin.GET("/products/import/:id", c.Import.GetImportJobHandler)
+	admin.GET("/exports/products", c.Export.ExportProductsHandler)
+	admin.GET("/exports/purchases", c.Export.ExportPurchasesHandler)
+	admin.GET("/reviews", c.Review.GetReviewsHandler)
+	admin.POST("/reviews/:id/approve", c.Review.ApproveReviewHandler)
+	admin.POST("/reviews/:id/reject", c.Review.RejectReviewHandler)
+	admin.DELETE("/reviews/:id", c.Review.DeleteReviewHandler)
+	admin.GET("/id-documents", c.AgeCheck.GetDocumentsHandler)
+	admin.GET("/id-documents/:id/file", c.AgeCheck.GetDocumentFileHandler)
+	admin.POST("/id-documents/:id/approve", c.AgeCheck.ApproveDocumentHandler)
+	admin.POST("/id-documents/:id/reject", c.AgeCheck.RejectDocumentHandler)
+	admin.PUT("/region-rules/:region", c.Region.SaveRegionRuleHandler)
+	admin.DELETE("/region-rules/:region", c.Region.DeleteRegionRuleHandler)
 }
 
-func RegisterRoutes(router *gin.Engine, categoryController *controllers.CategoryController, productController *controllers.ProductController, purchaseController *controllers.PurchaseController) {
-	v1 := router.Group("/api/v1")
-
-	v1.GET("/categories", categoryController.GetCategoriesHandler)
-	v1.POST("/categories", categoryController.CreateCategoriesHandler)
-	v1.GET("/categories/:id", categoryController.GetCategoryByIDHandler)
-	v1.PUT("/categories/:id", categoryController.UpdateCategoryHandler)
-	v1.DELETE("/categories/:id", categoryController.DeleteCategoryHandler)
-
-	v1.GET("/products", productController.GetProductsHandler)
-	v1.POST("/products", productController.CreateProductHandler)
-	v1.GET("/products/:id", productController.GetProductByIDHandler)
-	v1.PUT("/products/:id", productController.UpdateProductHandler)
-	v1.DELETE("/products/:id", productController.DeleteProductHandler)
-
-	v1.GET("/purchases", purchaseController.GetPurchasesHandler)
-	v1.POST("/purchases", purchaseController.CreatePurchaseHandler)
-	v1.GET("/purchases/:id", purchaseController.GetPurchaseByIDHandler)
-	v1.PUT("/purchases/:id", purchaseController.UpdatePurchaseHandler)
-	v1.DELETE("/purchases/:id", purchaseController.DeletePurchaseHandler)
+// RegisterHealthRoutes - служебные маршруты для оркестратора, доступные без авторизации.
+func RegisterHealthRoutes(router *gin.Engine, healthController *controllers.HealthController) {
+	router.GET("/healthz", healthController.LivenessHandler)
+	router.GET("/readyz", healthController.ReadinessHandler)
+	router.GET("/version", healthController.VersionHandler)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 }
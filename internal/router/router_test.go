@@ -0,0 +1,29 @@
+package router
+
+import (
+	"testing"
+
+	"VapeShop-ClientAPI/internal/docs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesDocumented - каждый маршрут должен быть описан в internal/docs/swagger.yaml.
+// Обработчики не вызываются, поэтому контроллеры не создаются.
+func TestRoutesDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	noop := func(c *gin.Context) { c.Next() }
+
+	RegisterHealthRoutes(router, nil)
+	RegisterRoutes(router, Controllers{}, noop, func(string) gin.HandlerFunc { return noop })
+	docs.Register(router)
+
+	missing, err := docs.MissingRoutes(router.Routes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) > 0 {
+		t.Errorf("маршруты не описаны в спецификации OpenAPI: %v", missing)
+	}
+}
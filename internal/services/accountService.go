@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/auth"
+	"VapeShop-ClientAPI/internal/config"
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/mailer"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+// Назначение одноразовых токенов
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+var (
+	ErrInvalidAccountToken  = errors.New("ссылка недействительна или устарела")
+	ErrEmailAlreadyVerified = errors.New("email уже подтвержден")
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type AccountService interface {
+	ForgotPassword(ctx context.Context, email, locale string) error
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
+	SendEmailVerification(ctx context.Context, customerID int64, locale string) error
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+// AccountServiceImpl - сброс пароля и подтверждение email одноразовыми токенами.
+type AccountServiceImpl struct {
+	db      *db.DB
+	mailer  mailer.Mailer
+	account config.Account
+	cipher  *pii.Cipher
+}
+
+// NewAccountService - функция для создания нового сервиса учетных записей.
+func NewAccountService(db *db.DB, m mailer.Mailer, account config.Account, cipher *pii.Cipher) *AccountServiceImpl {
+	return &AccountServiceImpl{
+		db:      db,
+		mailer:  m,
+		account: account,
+		cipher:  cipher,
+	}
+}
+
+// ForgotPassword - отправка ссылки для сброса пароля. Если клиент не найден,
+// ошибка не возвращается, чтобы по ответу нельзя было проверить наличие учетной записи.
+func (s *AccountServiceImpl) ForgotPassword(ctx context.Context, email, locale string) error {
+	var customerID int64
+	var name, address string
+	email = strings.ToLower(strings.TrimSpace(email))
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, first_name, email FROM customers WHERE "+customerByEmail+" AND deleted_at IS NULL",
+		s.cipher.BlindIndex(email), email).Scan(&customerID, &name, &address)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.FromContext(ctx).InfoContext(ctx, "Запрошен сброс пароля для несуществующего email")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+	if address, err = s.cipher.Decrypt(address); err != nil {
+		return err
+	}
+
+	token, err := s.issueToken(ctx, customerID, TokenPurposePasswordReset, s.account.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, locale, mailer.TemplatePasswordReset, address, name, "/reset-password", token, s.account.PasswordResetTTL)
+}
+
+// ResetPassword - установка нового пароля по токену. Заодно снимается блокировка входа,
+// а остальные неиспользованные токены сброса становятся недействительными.
+func (s *AccountServiceImpl) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return fmt.Errorf("ошибка хэширования пароля: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	customerID, err := consumeToken(ctx, tx, req.Token, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE customers
+        SET password = $2, failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL
+        WHERE id = $1`, customerID, hash); err != nil {
+		return fmt.Errorf("ошибка обновления пароля: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE auth_tokens SET used_at = now()
+        WHERE customer_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		customerID, TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("ошибка отзыва токенов: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Пароль сброшен", slog.Int64("customer_id", customerID))
+	return nil
+}
+
+// SendEmailVerification - отправка ссылки для подтверждения email.
+func (s *AccountServiceImpl) SendEmailVerification(ctx context.Context, customerID int64, locale string) error {
+	var name, address string
+	var verifiedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		"SELECT first_name, email, email_verified_at FROM customers WHERE id = $1",
+		customerID).Scan(&name, &address, &verifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCustomerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+	if verifiedAt.Valid {
+		return ErrEmailAlreadyVerified
+	}
+	if address, err = s.cipher.Decrypt(address); err != nil {
+		return err
+	}
+
+	token, err := s.issueToken(ctx, customerID, TokenPurposeEmailVerification, s.account.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, locale, mailer.TemplateEmailVerification, address, name, "/verify-email", token, s.account.EmailVerificationTTL)
+}
+
+// VerifyEmail - подтверждение email по токену.
+func (s *AccountServiceImpl) VerifyEmail(ctx context.Context, token string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	customerID, err := consumeToken(ctx, tx, token, TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE customers SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1",
+		customerID); err != nil {
+		return fmt.Errorf("ошибка подтверждения email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// issueToken - выпуск нового токена; предыдущие неиспользованные токены того же
+// назначения отзываются, чтобы действовала только последняя ссылка.
+func (s *AccountServiceImpl) issueToken(ctx context.Context, customerID int64, purpose string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE auth_tokens SET used_at = now()
+        WHERE customer_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		customerID, purpose); err != nil {
+		return "", fmt.Errorf("ошибка отзыва токенов: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO auth_tokens (customer_id, purpose, token_hash, expires_at)
+        VALUES ($1, $2, $3, $4)`,
+		customerID, purpose, hashToken(token), time.Now().Add(ttl)); err != nil {
+		return "", fmt.Errorf("ошибка сохранения токена: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return token, nil
+}
+
+// consumeToken - проверка токена и отметка об использовании. Возвращает ID клиента.
+func consumeToken(ctx context.Context, tx *db.Tx, token, purpose string) (int64, error) {
+	var customerID int64
+	err := tx.QueryRowContext(ctx, `
+        UPDATE auth_tokens SET used_at = now()
+        WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+        RETURNING customer_id`, hashToken(token), purpose).Scan(&customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidAccountToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка проверки токена: %w", err)
+	}
+	return customerID, nil
+}
+
+// send - формирование и отправка письма со ссылкой на клиентское приложение.
+func (s *AccountServiceImpl) send(ctx context.Context, locale, template, to, name, path, token string, ttl time.Duration) error {
+	link := strings.TrimRight(s.account.AppBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+
+	msg, err := mailer.Render(locale, template, to, map[string]any{
+		"Name": name,
+		"Link": link,
+		"TTL":  mailer.FormatTTL(locale, ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("ошибка отправки письма: %w", err)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
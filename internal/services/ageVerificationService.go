@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+// Статусы проверки возраста клиента
+const (
+	AgeVerificationUnverified = "unverified"
+	AgeVerificationPending    = "pending"
+	AgeVerificationVerified   = "verified"
+	AgeVerificationRejected   = "rejected"
+)
+
+// Статусы документа
+const (
+	DocumentStatusPending  = "pending"
+	DocumentStatusApproved = "approved"
+	DocumentStatusRejected = "rejected"
+)
+
+// DateLayout - формат дат без времени (дата рождения).
+const DateLayout = "2006-01-02"
+
+var (
+	ErrAlreadyAgeVerified      = errors.New("возраст уже подтвержден")
+	ErrDocumentNotFound        = errors.New("документ не найден")
+	ErrDocumentAlreadyReviewed = errors.New("документ уже проверен")
+)
+
+// AgeVerification - состояние проверки возраста клиента.
+type AgeVerification struct {
+	Status      string     `json:"status"`
+	DateOfBirth *string    `json:"date_of_birth"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+}
+
+// SubmitDocumentRequest - сведения, которые клиент указывает при загрузке документа.
+type SubmitDocumentRequest struct {
+	DocumentType string `form:"document_type" validate:"required,oneof=passport id_card driver_license"`
+	DateOfBirth  string `form:"date_of_birth" validate:"required,datetime=2006-01-02"`
+}
+
+// IDDocumentFile - загруженный файл документа.
+type IDDocumentFile struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+type IDDocument struct {
+	ID              int64      `json:"id"`
+	CustomerID      int64      `json:"customer_id"`
+	DocumentType    string     `json:"document_type"`
+	DateOfBirth     string     `json:"date_of_birth"`
+	FileName        string     `json:"file_name"`
+	ContentType     string     `json:"content_type"`
+	Status          string     `json:"status"`
+	RejectionReason *string    `json:"rejection_reason"`
+	ReviewedBy      *int64     `json:"reviewed_by"`
+	ReviewedAt      *time.Time `json:"reviewed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// IDDocumentFilter - фильтр документов; нулевые значения не ограничивают выборку.
+type IDDocumentFilter struct {
+	Status     string
+	CustomerID int64
+	Limit      int
+}
+
+// ApproveDocumentRequest - подтверждение документа. Если дата рождения в документе
+// отличается от указанной клиентом, администратор передает исправленную.
+type ApproveDocumentRequest struct {
+	DateOfBirth string `json:"date_of_birth" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type RejectDocumentRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type AgeVerificationService interface {
+	GetAgeVerification(ctx context.Context, customerID int64) (*AgeVerification, error)
+	SubmitDocument(ctx context.Context, customerID int64, req SubmitDocumentRequest, file IDDocumentFile) (*IDDocument, error)
+	GetDocuments(ctx context.Context, filter IDDocumentFilter) ([]IDDocument, error)
+	GetDocumentFile(ctx context.Context, id int64) (*IDDocumentFile, error)
+	ApproveDocument(ctx context.Context, id, adminID int64, req ApproveDocumentRequest) error
+	RejectDocument(ctx context.Context, id, adminID int64, req RejectDocumentRequest) error
+}
+
+// AgeVerificationServiceImpl - проверка возраста клиентов по документам.
+type AgeVerificationServiceImpl struct {
+	db *db.DB
+	// Файлы документов хранятся зашифрованными
+	cipher *pii.Cipher
+}
+
+// NewAgeVerificationService - функция для создания нового сервиса проверки возраста.
+func NewAgeVerificationService(db *db.DB, cipher *pii.Cipher) *AgeVerificationServiceImpl {
+	return &AgeVerificationServiceImpl{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+// GetAgeVerification - текущий статус проверки возраста клиента.
+func (s *AgeVerificationServiceImpl) GetAgeVerification(ctx context.Context, customerID int64) (*AgeVerification, error) {
+	var v AgeVerification
+	var dob sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		"SELECT age_verification_status, date_of_birth, age_verified_at FROM customers WHERE id = $1",
+		customerID).Scan(&v.Status, &dob, &v.VerifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса проверки возраста: %w", err)
+	}
+	if dob.Valid {
+		d := dob.Time.Format(DateLayout)
+		v.DateOfBirth = &d
+	}
+	return &v, nil
+}
+
+// SubmitDocument - загрузка документа клиентом. Статус клиента меняется на pending
+// до проверки администратором.
+func (s *AgeVerificationServiceImpl) SubmitDocument(ctx context.Context, customerID int64, req SubmitDocumentRequest, file IDDocumentFile) (*IDDocument, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx,
+		"SELECT age_verification_status FROM customers WHERE id = $1 FOR UPDATE",
+		customerID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+	if status == AgeVerificationVerified {
+		return nil, ErrAlreadyAgeVerified
+	}
+
+	content, err := s.cipher.EncryptBytes(file.Content)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка шифрования документа: %w", err)
+	}
+
+	doc := IDDocument{
+		CustomerID:   customerID,
+		DocumentType: req.DocumentType,
+		DateOfBirth:  req.DateOfBirth,
+		FileName:     file.FileName,
+		ContentType:  file.ContentType,
+		Status:       DocumentStatusPending,
+	}
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO id_documents (customer_id, document_type, date_of_birth, file_name, content_type, content)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at`,
+		customerID, req.DocumentType, req.DateOfBirth, file.FileName, file.ContentType, content,
+	).Scan(&doc.ID, &doc.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения документа: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE customers SET age_verification_status = $2 WHERE id = $1",
+		customerID, AgeVerificationPending); err != nil {
+		return nil, fmt.Errorf("ошибка обновления статуса проверки возраста: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Загружен документ для проверки возраста",
+		slog.Int64("customer_id", customerID), slog.Int64("document_id", doc.ID))
+	return &doc, nil
+}
+
+// GetDocuments - список документов без содержимого файлов, новые первыми.
+func (s *AgeVerificationServiceImpl) GetDocuments(ctx context.Context, filter IDDocumentFilter) ([]IDDocument, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, customer_id, document_type, date_of_birth, file_name, content_type,
+               status, rejection_reason, reviewed_by, reviewed_at, created_at
+        FROM id_documents
+        WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR customer_id = $2)
+        ORDER BY created_at DESC
+        LIMIT $3`, filter.Status, filter.CustomerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	documents := []IDDocument{}
+	for rows.Next() {
+		var doc IDDocument
+		var dob time.Time
+		if err := rows.Scan(
+			&doc.ID,
+			&doc.CustomerID,
+			&doc.DocumentType,
+			&dob,
+			&doc.FileName,
+			&doc.ContentType,
+			&doc.Status,
+			&doc.RejectionReason,
+			&doc.ReviewedBy,
+			&doc.ReviewedAt,
+			&doc.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		doc.DateOfBirth = dob.Format(DateLayout)
+		documents = append(documents, doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	return documents, nil
+}
+
+// GetDocumentFile - содержимое загруженного документа для просмотра администратором.
+func (s *AgeVerificationServiceImpl) GetDocumentFile(ctx context.Context, id int64) (*IDDocumentFile, error) {
+	var file IDDocumentFile
+	err := s.db.QueryRowContext(ctx,
+		"SELECT file_name, content_type, content FROM id_documents WHERE id = $1",
+		id).Scan(&file.FileName, &file.ContentType, &file.Content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения документа: %w", err)
+	}
+	if file.Content, err = s.cipher.DecryptBytes(file.Content); err != nil {
+		return nil, fmt.Errorf("ошибка расшифровки документа: %w", err)
+	}
+	return &file, nil
+}
+
+// ApproveDocument - подтверждение документа: клиенту сохраняется дата рождения
+// и выставляется статус verified.
+func (s *AgeVerificationServiceImpl) ApproveDocument(ctx context.Context, id, adminID int64, req ApproveDocumentRequest) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	customerID, dob, err := lockPendingDocument(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if req.DateOfBirth != "" {
+		dob = req.DateOfBirth
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE id_documents
+        SET status = $2, date_of_birth = $3, reviewed_by = $4, reviewed_at = now()
+        WHERE id = $1`, id, DocumentStatusApproved, dob, adminID); err != nil {
+		return fmt.Errorf("ошибка обновления документа: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE customers
+        SET date_of_birth = $2, age_verification_status = $3, age_verified_at = now()
+        WHERE id = $1`, customerID, dob, AgeVerificationVerified); err != nil {
+		return fmt.Errorf("ошибка обновления статуса проверки возраста: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Документ подтвержден",
+		slog.Int64("document_id", id),
+		slog.Int64("customer_id", customerID),
+		slog.Int64("admin_id", adminID))
+	return nil
+}
+
+// RejectDocument - отклонение документа. Если других документов на проверке нет,
+// клиент получает статус rejected и может загрузить документ повторно.
+func (s *AgeVerificationServiceImpl) RejectDocument(ctx context.Context, id, adminID int64, req RejectDocumentRequest) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	customerID, _, err := lockPendingDocument(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE id_documents
+        SET status = $2, rejection_reason = $3, reviewed_by = $4, reviewed_at = now()
+        WHERE id = $1`, id, DocumentStatusRejected, req.Reason, adminID); err != nil {
+		return fmt.Errorf("ошибка обновления документа: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE customers SET age_verification_status = $2
+        WHERE id = $1 AND age_verification_status = $3
+          AND NOT EXISTS (SELECT 1 FROM id_documents WHERE customer_id = $1 AND status = $4)`,
+		customerID, AgeVerificationRejected, AgeVerificationPending, DocumentStatusPending); err != nil {
+		return fmt.Errorf("ошибка обновления статуса проверки возраста: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Документ отклонен",
+		slog.Int64("document_id", id),
+		slog.Int64("customer_id", customerID),
+		slog.Int64("admin_id", adminID))
+	return nil
+}
+
+// lockPendingDocument - блокировка документа, ожидающего проверки.
+// Возвращает ID клиента и указанную им дату рождения.
+func lockPendingDocument(ctx context.Context, tx *db.Tx, id int64) (int64, string, error) {
+	var customerID int64
+	var dob time.Time
+	var status string
+	err := tx.QueryRowContext(ctx,
+		"SELECT customer_id, date_of_birth, status FROM id_documents WHERE id = $1 FOR UPDATE",
+		id).Scan(&customerID, &dob, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrDocumentNotFound
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("ошибка получения документа: %w", err)
+	}
+	if status != DocumentStatusPending {
+		return 0, "", ErrDocumentAlreadyReviewed
+	}
+	return customerID, dob.Format(DateLayout), nil
+}
+
+// isAdult - исполнилось ли minimumAge полных лет на дату now. Родившимся 29 февраля
+// в невисокосный год возраст засчитывается с 1 марта.
+func isAdult(dob time.Time, minimumAge int, now time.Time) bool {
+	y, m, d := dob.Date()
+	adultFrom := time.Date(y+minimumAge, m, d, 0, 0, 0, 0, time.UTC)
+	ny, nm, nd := now.Date()
+	return !time.Date(ny, nm, nd, 0, 0, 0, 0, time.UTC).Before(adultFrom)
+}
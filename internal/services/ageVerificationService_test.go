@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsAdult(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name string
+		dob  time.Time
+		now  time.Time
+		want bool
+	}{
+		{"день рождения", date(2006, time.May, 15), date(2024, time.May, 15), true},
+		{"накануне дня рождения", date(2006, time.May, 15), date(2024, time.May, 14), false},
+		{"через год после совершеннолетия", date(2005, time.December, 31), date(2024, time.January, 1), true},
+		{"конец года до совершеннолетия", date(2006, time.December, 31), date(2024, time.December, 30), false},
+		{"29 февраля, невисокосный год, 28 февраля", date(2004, time.February, 29), date(2022, time.February, 28), false},
+		{"29 февраля, невисокосный год, 1 марта", date(2004, time.February, 29), date(2022, time.March, 1), true},
+		{"29 февраля, невисокосный год, 27 февраля", date(2008, time.February, 29), date(2026, time.February, 27), false},
+		// Учитывается календарная дата now в ее часовом поясе, а не время суток
+		{"полночь дня рождения по Москве", date(2006, time.May, 15), time.Date(2024, time.May, 15, 0, 30, 0, 0, moscow), true},
+		{"последняя минута накануне", date(2006, time.May, 15), time.Date(2024, time.May, 14, 23, 59, 0, 0, moscow), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAdult(tt.dob, 18, tt.now); got != tt.want {
+				t.Errorf("isAdult(%s, 18, %s) = %v, ожидалось %v",
+					tt.dob.Format(DateLayout), tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+
+	// Совершеннолетие в високосный год наступает 29 февраля
+	if !isAdult(date(2004, time.February, 29), 20, date(2024, time.February, 29)) {
+		t.Error("isAdult(2004-02-29, 20, 2024-02-29) = false, ожидалось true")
+	}
+	if isAdult(date(2004, time.February, 29), 20, date(2024, time.February, 28)) {
+		t.Error("isAdult(2004-02-29, 20, 2024-02-28) = true, ожидалось false")
+	}
+}
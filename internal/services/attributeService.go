@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+
+	"VapeShop-ClientAPI/internal/db"
+
+	"github.com/lib/pq"
+)
+
+// Типы характеристик
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeInteger = "integer"
+	AttributeTypeBoolean = "boolean"
+)
+
+// attributeCodePattern - допустимый код характеристики: он же ключ в products.attributes
+// и имя параметра фильтра attr.<code>.
+var attributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// AttributeDefinition - описание характеристики товаров категории.
+type AttributeDefinition struct {
+	ID int64 `json:"id"`
+	// Категория, в которой задано описание; у унаследованных - предок
+	CategoryID int64  `json:"category_id"`
+	Code       string `json:"code" validate:"required,max=64"`
+	Name       string `json:"name" validate:"required,max=255"`
+	Type       string `json:"type" validate:"required,oneof=string number integer boolean"`
+	// Единица измерения, например "Вт"
+	Unit *string `json:"unit" validate:"omitempty,max=32"`
+	// Допустимые значения строковой характеристики; пустой список - любые
+	AllowedValues []string `json:"allowed_values" validate:"dive,required,max=255"`
+	Required      bool     `json:"required"`
+	Position      int      `json:"position"`
+}
+
+// AttributeError - недопустимое описание или значение характеристики.
+type AttributeError struct {
+	Code   string
+	Reason string
+}
+
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("характеристика %s: %s", e.Code, e.Reason)
+}
+
+// queryer - выполнение запросов вне транзакции или в ней.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*db.Rows, error)
+}
+
+type AttributeService interface {
+	GetCategoryAttributes(ctx context.Context, categoryID int64) ([]AttributeDefinition, error)
+	SaveCategoryAttributes(ctx context.Context, categoryID int64, definitions []AttributeDefinition) ([]AttributeDefinition, error)
+}
+
+// AttributeServiceImpl - описания характеристик товаров по категориям.
+// Подкатегория наследует описания предков и может переопределить их по коду.
+type AttributeServiceImpl struct {
+	db *db.DB
+}
+
+// NewAttributeService - функция для создания нового сервиса характеристик.
+func NewAttributeService(db *db.DB) *AttributeServiceImpl {
+	return &AttributeServiceImpl{
+		db: db,
+	}
+}
+
+const attributeColumns = "a.id, a.category_id, a.code, a.name, a.type, a.unit, a.allowed_values, a.required, a.position"
+
+// scanAttribute - чтение строки, выбранной с attributeColumns.
+func scanAttribute(row interface{ Scan(dest ...any) error }, d *AttributeDefinition) error {
+	var allowed []string
+	if err := row.Scan(&d.ID, &d.CategoryID, &d.Code, &d.Name, &d.Type, &d.Unit,
+		pq.Array(&allowed), &d.Required, &d.Position); err != nil {
+		return err
+	}
+	d.AllowedValues = allowed
+	return nil
+}
+
+// GetCategoryAttributes - характеристики товаров категории с учетом унаследованных.
+func (s *AttributeServiceImpl) GetCategoryAttributes(ctx context.Context, categoryID int64) ([]AttributeDefinition, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)", categoryID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки категории: %w", err)
+	}
+	if !exists {
+		return nil, ErrCategoryNotFound
+	}
+	return loadAttributeSchema(ctx, s.db, categoryID)
+}
+
+// SaveCategoryAttributes - замена собственных характеристик категории. Сохраненные
+// значения товаров не меняются: новая схема проверяется при следующем сохранении товара.
+func (s *AttributeServiceImpl) SaveCategoryAttributes(ctx context.Context, categoryID int64, definitions []AttributeDefinition) ([]AttributeDefinition, error) {
+	if err := checkAttributeDefinitions(definitions); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM categories WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", categoryID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки категории: %w", err)
+	}
+
+	before, err := ownAttributes(ctx, tx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM category_attributes WHERE category_id = $1", categoryID); err != nil {
+		return nil, fmt.Errorf("ошибка удаления характеристик: %w", err)
+	}
+	after := make([]AttributeDefinition, 0, len(definitions))
+	for _, d := range definitions {
+		d.CategoryID = categoryID
+		if len(d.AllowedValues) == 0 {
+			d.AllowedValues = nil
+		}
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO category_attributes (category_id, code, name, type, unit, allowed_values, required, position)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING id`,
+			d.CategoryID, d.Code, d.Name, d.Type, d.Unit, pq.Array(d.AllowedValues), d.Required, d.Position).Scan(&d.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сохранения характеристики %s: %w", d.Code, err)
+		}
+		after = append(after, d)
+	}
+
+	if err := recordAudit(ctx, tx, AuditEntityCategory, categoryID, AuditActionUpdate,
+		map[string]any{"attributes": before}, map[string]any{"attributes": after}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return loadAttributeSchema(ctx, s.db, categoryID)
+}
+
+// checkAttributeDefinitions - проверка описаний, которую не выражают теги validate.
+func checkAttributeDefinitions(definitions []AttributeDefinition) error {
+	seen := make(map[string]bool, len(definitions))
+	for _, d := range definitions {
+		if !attributeCodePattern.MatchString(d.Code) {
+			return &AttributeError{Code: d.Code, Reason: "код может содержать только латинские буквы в нижнем регистре, цифры и подчеркивания"}
+		}
+		if seen[d.Code] {
+			return &AttributeError{Code: d.Code, Reason: "код указан несколько раз"}
+		}
+		seen[d.Code] = true
+		if len(d.AllowedValues) > 0 && d.Type != AttributeTypeString {
+			return &AttributeError{Code: d.Code, Reason: "список допустимых значений задается только для строковых характеристик"}
+		}
+	}
+	return nil
+}
+
+// ownAttributes - характеристики, заданные в самой категории.
+func ownAttributes(ctx context.Context, q queryer, categoryID int64) ([]AttributeDefinition, error) {
+	return queryAttributes(ctx, q, `
+        SELECT `+attributeColumns+`
+        FROM category_attributes a
+        WHERE a.category_id = $1
+        ORDER BY a.position, a.code`, categoryID)
+}
+
+// loadAttributeSchema - действующие характеристики категории: собственные и предков.
+// При совпадении кода используется описание ближайшей к категории.
+func loadAttributeSchema(ctx context.Context, q queryer, categoryID int64) ([]AttributeDefinition, error) {
+	return queryAttributes(ctx, q, `
+        WITH RECURSIVE path AS (
+            SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1
+            UNION ALL
+            SELECT c.id, c.parent_id, p.depth + 1
+            FROM categories c JOIN path p ON c.id = p.parent_id
+        )
+        SELECT * FROM (
+            SELECT DISTINCT ON (a.code) `+attributeColumns+`
+            FROM category_attributes a JOIN path p ON a.category_id = p.id
+            ORDER BY a.code, p.depth
+        ) a
+        ORDER BY a.position, a.code`, categoryID)
+}
+
+func queryAttributes(ctx context.Context, q queryer, query string, categoryID int64) ([]AttributeDefinition, error) {
+	rows, err := q.QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения характеристик: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := []AttributeDefinition{}
+	for rows.Next() {
+		var d AttributeDefinition
+		if err := scanAttribute(rows, &d); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		definitions = append(definitions, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return definitions, nil
+}
+
+// validateAttributes - проверка значений характеристик товара по схеме категории.
+// Значения null удаляются, как если бы характеристика не была указана.
+func validateAttributes(schema []AttributeDefinition, values map[string]any) error {
+	byCode := make(map[string]AttributeDefinition, len(schema))
+	for _, d := range schema {
+		byCode[d.Code] = d
+	}
+	for code, v := range values {
+		if v == nil {
+			delete(values, code)
+			continue
+		}
+		d, ok := byCode[code]
+		if !ok {
+			return &AttributeError{Code: code, Reason: "не задана для категории товара"}
+		}
+		if err := checkAttributeValue(d, v); err != nil {
+			return err
+		}
+	}
+	for _, d := range schema {
+		if _, ok := values[d.Code]; d.Required && !ok {
+			return &AttributeError{Code: d.Code, Reason: "обязательна для категории товара"}
+		}
+	}
+	return nil
+}
+
+// checkAttributeValue - соответствие значения типу и списку допустимых значений.
+// Числа приходят из JSON как float64.
+func checkAttributeValue(d AttributeDefinition, v any) error {
+	switch d.Type {
+	case AttributeTypeString:
+		s, ok := v.(string)
+		if !ok {
+			return &AttributeError{Code: d.Code, Reason: "ожидается строка"}
+		}
+		if len(d.AllowedValues) > 0 && !slices.Contains(d.AllowedValues, s) {
+			return &AttributeError{Code: d.Code, Reason: fmt.Sprintf("недопустимое значение %q", s)}
+		}
+	case AttributeTypeNumber:
+		if _, ok := v.(float64); !ok {
+			return &AttributeError{Code: d.Code, Reason: "ожидается число"}
+		}
+	case AttributeTypeInteger:
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return &AttributeError{Code: d.Code, Reason: "ожидается целое число"}
+		}
+	case AttributeTypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return &AttributeError{Code: d.Code, Reason: "ожидается true или false"}
+		}
+	}
+	return nil
+}
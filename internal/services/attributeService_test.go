@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateAttributes(t *testing.T) {
+	schema := []AttributeDefinition{
+		{Code: "color", Type: AttributeTypeString, AllowedValues: []string{"Черный", "Синий"}},
+		{Code: "flavor", Type: AttributeTypeString},
+		{Code: "power", Type: AttributeTypeInteger, Required: true},
+		{Code: "resistance", Type: AttributeTypeNumber},
+		{Code: "refillable", Type: AttributeTypeBoolean},
+	}
+
+	tests := []struct {
+		name       string
+		values     map[string]any
+		wantCode   string
+		wantReason string
+	}{
+		{
+			name:   "все значения допустимы",
+			values: map[string]any{"color": "Синий", "flavor": "Манго", "power": float64(80), "resistance": 0.15, "refillable": true},
+		},
+		{name: "только обязательная", values: map[string]any{"power": float64(40)}},
+		{name: "null вместо значения", values: map[string]any{"power": float64(40), "color": nil}},
+		{name: "нет обязательной", values: map[string]any{"color": "Черный"}, wantCode: "power", wantReason: "обязательна для категории товара"},
+		{name: "обязательная со значением null", values: map[string]any{"power": nil}, wantCode: "power", wantReason: "обязательна для категории товара"},
+		{name: "неизвестная характеристика", values: map[string]any{"power": float64(40), "weight": float64(1)}, wantCode: "weight", wantReason: "не задана для категории товара"},
+		{name: "недопустимое значение", values: map[string]any{"power": float64(40), "color": "Красный"}, wantCode: "color", wantReason: `недопустимое значение "Красный"`},
+		{name: "число вместо строки", values: map[string]any{"power": float64(40), "flavor": float64(1)}, wantCode: "flavor", wantReason: "ожидается строка"},
+		{name: "дробное вместо целого", values: map[string]any{"power": 40.5}, wantCode: "power", wantReason: "ожидается целое число"},
+		{name: "строка вместо целого", values: map[string]any{"power": "40"}, wantCode: "power", wantReason: "ожидается целое число"},
+		{name: "строка вместо числа", values: map[string]any{"power": float64(40), "resistance": "0.15"}, wantCode: "resistance", wantReason: "ожидается число"},
+		{name: "строка вместо логического", values: map[string]any{"power": float64(40), "refillable": "да"}, wantCode: "refillable", wantReason: "ожидается true или false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAttributes(schema, tt.values)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("validateAttributes = %v, ожидалось nil", err)
+				}
+				return
+			}
+			var attrErr *AttributeError
+			if !errors.As(err, &attrErr) {
+				t.Fatalf("validateAttributes = %v, ожидалась AttributeError", err)
+			}
+			if attrErr.Code != tt.wantCode || attrErr.Reason != tt.wantReason {
+				t.Errorf("ошибка = {%s %q}, ожидалось {%s %q}", attrErr.Code, attrErr.Reason, tt.wantCode, tt.wantReason)
+			}
+		})
+	}
+
+	// Значения null удаляются из характеристик товара
+	values := map[string]any{"power": float64(40), "color": nil}
+	if err := validateAttributes(schema, values); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := values["color"]; ok {
+		t.Error("значение null не удалено")
+	}
+}
+
+func TestCheckAttributeDefinitions(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions []AttributeDefinition
+		wantErr     bool
+	}{
+		{"допустимые описания", []AttributeDefinition{{Code: "coil_2", Type: AttributeTypeString, AllowedValues: []string{"a"}}, {Code: "power", Type: AttributeTypeInteger}}, false},
+		{"код с заглавными буквами", []AttributeDefinition{{Code: "Power", Type: AttributeTypeInteger}}, true},
+		{"код с кириллицей", []AttributeDefinition{{Code: "вкус", Type: AttributeTypeString}}, true},
+		{"повторный код", []AttributeDefinition{{Code: "power", Type: AttributeTypeInteger}, {Code: "power", Type: AttributeTypeNumber}}, true},
+		{"список значений у числа", []AttributeDefinition{{Code: "power", Type: AttributeTypeInteger, AllowedValues: []string{"40"}}}, true},
+	}
+	for _, tt := range tests {
+		if err := checkAttributeDefinitions(tt.definitions); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка = %v, ожидалась ошибка: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"VapeShop-ClientAPI/internal/audit"
+	"VapeShop-ClientAPI/internal/db"
+)
+
+// Действия журнала аудита
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// Восстановление после мягкого удаления
+	AuditActionRestore = "restore"
+	// Окончательное удаление фоновой задачей
+	AuditActionPurge = "purge"
+)
+
+// Типы сущностей журнала аудита
+const (
+	AuditEntityCategory = "category"
+	AuditEntityProduct  = "product"
+	AuditEntityVariant  = "product_variant"
+	AuditEntityImage    = "product_image"
+	AuditEntityPurchase = "purchase"
+	AuditEntityReview   = "review"
+)
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	IP         *string         `json:"ip"`
+	RequestID  *string         `json:"request_id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int64           `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter - фильтр журнала аудита; нулевые значения не ограничивают выборку.
+type AuditFilter struct {
+	EntityType string
+	EntityID   int64
+	ActorID    int64
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+type AuditService interface {
+	GetAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// AuditServiceImpl - просмотр журнала аудита. Записи добавляются сервисами
+// в транзакции изменения через recordAudit.
+type AuditServiceImpl struct {
+	db *db.DB
+}
+
+// NewAuditService - функция для создания нового сервиса журнала аудита.
+func NewAuditService(db *db.DB) *AuditServiceImpl {
+	return &AuditServiceImpl{
+		db: db,
+	}
+}
+
+const auditColumns = "id, actor_id, actor_role, ip, request_id, entity_type, entity_id, action, before, after, created_at"
+
+// scanAuditEntry - чтение строки, выбранной с auditColumns.
+func scanAuditEntry(row interface{ Scan(dest ...any) error }, e *AuditEntry) error {
+	var before, after []byte
+	if err := row.Scan(&e.ID, &e.ActorID, &e.ActorRole, &e.IP, &e.RequestID,
+		&e.EntityType, &e.EntityID, &e.Action, &before, &after, &e.CreatedAt); err != nil {
+		return err
+	}
+	// json.RawMessage(nil) кодируется как null
+	e.Before = before
+	e.After = after
+	return nil
+}
+
+// GetAuditLog - журнал аудита, новые записи первыми.
+func (s *AuditServiceImpl) GetAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+auditColumns+`
+        FROM audit_log
+        WHERE ($1 = '' OR entity_type = $1)
+          AND ($2 = 0 OR entity_id = $2)
+          AND ($3 = 0 OR actor_id = $3)
+          AND ($4::timestamptz IS NULL OR created_at >= $4)
+          AND ($5::timestamptz IS NULL OR created_at < $5)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $6`,
+		filter.EntityType, filter.EntityID, filter.ActorID, from, to, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := scanAuditEntry(rows, &e); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return entries, nil
+}
+
+// recordAudit - запись изменения в журнал аудита в той же транзакции, что и само
+// изменение: без записи в журнале изменение не сохраняется. Автор берется из контекста.
+func recordAudit(ctx context.Context, tx *db.Tx, entityType string, entityID int64, action string, before, after any) error {
+	b, a, err := audit.Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки записи аудита: %w", err)
+	}
+	// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+	beforeJSON, err := jsonString(b)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := jsonString(a)
+	if err != nil {
+		return err
+	}
+
+	actor := audit.ActorFromContext(ctx)
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO audit_log (actor_id, actor_role, ip, request_id, entity_type, entity_id, action, before, after)
+        VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7, $8, $9)`,
+		actor.ID, actor.Role, actor.IP, actor.RequestID, entityType, entityID, action, beforeJSON, afterJSON); err != nil {
+		return fmt.Errorf("ошибка записи аудита: %w", err)
+	}
+	return nil
+}
+
+// jsonString - JSON-строка для столбца JSONB; nil сохраняется как NULL.
+func jsonString(v map[string]any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки записи аудита: %w", err)
+	}
+	str := string(data)
+	return &str, nil
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/auth"
+	"VapeShop-ClientAPI/internal/config"
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/metrics"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+// Типы событий журнала подозрительной активности
+const (
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventIPBlocked       = "ip_blocked"
+	SecurityEventLockedLogin     = "locked_account_login"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("неверный email или пароль")
+	ErrCustomerNotFound   = errors.New("клиент не найден")
+)
+
+// LoginThrottledError - вход временно запрещен: учетная запись или IP заблокированы,
+// либо не выдержана пауза после предыдущей неудачной попытки.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "слишком много неудачных попыток входа, повторите позже"
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type SecurityEvent struct {
+	ID         int64           `json:"id"`
+	EventType  string          `json:"event_type"`
+	CustomerID *int64          `json:"customer_id"`
+	ActorID    *int64          `json:"actor_id"`
+	Email      *string         `json:"email"`
+	IP         *string         `json:"ip"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// SecurityEventFilter - фильтр журнала; нулевые значения не ограничивают выборку.
+type SecurityEventFilter struct {
+	CustomerID int64
+	IP         string
+	EventType  string
+	Limit      int
+}
+
+type AuthService interface {
+	Login(ctx context.Context, req LoginRequest, ip string) (*Token, error)
+	UnlockCustomer(ctx context.Context, customerID, adminID int64, ip string) error
+	GetSecurityEvents(ctx context.Context, filter SecurityEventFilter) ([]SecurityEvent, error)
+}
+
+// AuthServiceImpl - вход клиентов с защитой от подбора пароля.
+type AuthServiceImpl struct {
+	db     *db.DB
+	jwt    config.JWT
+	login  config.Login
+	cipher *pii.Cipher
+}
+
+// NewAuthService - функция для создания нового сервиса авторизации.
+func NewAuthService(db *db.DB, jwt config.JWT, login config.Login, cipher *pii.Cipher) *AuthServiceImpl {
+	return &AuthServiceImpl{
+		db:     db,
+		jwt:    jwt,
+		login:  login,
+		cipher: cipher,
+	}
+}
+
+// Login - проверка учетных данных и выпуск токена.
+func (s *AuthServiceImpl) Login(ctx context.Context, req LoginRequest, ip string) (*Token, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// IP и строка клиента блокируются до записи результата попытки: иначе параллельные
+	// запросы прочли бы одни и те же счетчики и обошли порог IP и прогрессивную задержку.
+	// FOR NO KEY UPDATE не мешает вставке событий со ссылкой на клиента
+	// с другого соединения (recordEvent)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка по IP: слишком много неудачных попыток за окно
+	ipFailures, err := s.countIPFailures(ctx, tx, ip)
+	if err != nil {
+		return nil, err
+	}
+	if s.login.IPMaxFailedAttempts > 0 && ipFailures >= s.login.IPMaxFailedAttempts {
+		return nil, &LoginThrottledError{RetryAfter: s.login.IPWindow}
+	}
+
+	// fail - учет неудачной попытки в транзакции и ее фиксация
+	fail := func(customerID *int64) error {
+		if err := s.registerFailure(ctx, tx, customerID, email, ip, ipFailures); !errors.Is(err, ErrInvalidCredentials) {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+		}
+		return ErrInvalidCredentials
+	}
+
+	var (
+		customerID   int64
+		password     string
+		role         string
+		failed       int
+		lastFailedAt sql.NullTime
+		lockedUntil  sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, password, role, failed_login_attempts, last_failed_login_at, locked_until
+        FROM customers WHERE `+customerByEmail+`
+        FOR NO KEY UPDATE`, s.cipher.BlindIndex(email), email).Scan(
+		&customerID, &password, &role, &failed, &lastFailedAt, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		auth.CheckDummyPassword(req.Password)
+		return nil, fail(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+
+	now := time.Now()
+	if lockedUntil.Valid && lockedUntil.Time.After(now) {
+		s.recordEvent(ctx, SecurityEventLockedLogin, &customerID, nil, email, ip, nil)
+		return nil, &LoginThrottledError{RetryAfter: lockedUntil.Time.Sub(now)}
+	}
+
+	// Прогрессивная задержка: следующая попытка не раньше, чем через DelayBase * 2^(failed-1)
+	if failed > 0 && lastFailedAt.Valid {
+		if next := lastFailedAt.Time.Add(s.delay(failed)); next.After(now) {
+			return nil, &LoginThrottledError{RetryAfter: next.Sub(now)}
+		}
+	}
+
+	if !auth.CheckPassword(password, req.Password) {
+		return nil, fail(&customerID)
+	}
+
+	if err := s.registerSuccess(ctx, tx, customerID, email, ip, password, req.Password); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	token, expiresAt, err := auth.GenerateToken(s.jwt.Secret, customerID, role, s.jwt.TTL)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// delay - пауза после failed неудачных попыток подряд.
+func (s *AuthServiceImpl) delay(failed int) time.Duration {
+	d := s.login.DelayBase
+	for i := 1; i < failed && d < s.login.DelayMax; i++ {
+		d *= 2
+	}
+	return min(d, s.login.DelayMax)
+}
+
+// countIPFailures - количество неудачных попыток с ip за окно. Попытки с одного IP
+// сериализуются блокировкой до конца транзакции tx, поэтому счетчик не устаревает
+// до записи результата попытки.
+func (s *AuthServiceImpl) countIPFailures(ctx context.Context, tx *db.Tx, ip string) (int, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('login_attempts.ip:' || $1))", ip); err != nil {
+		return 0, fmt.Errorf("ошибка блокировки IP: %w", err)
+	}
+
+	var count int
+	err := tx.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM login_attempts
+        WHERE ip = $1 AND NOT success AND created_at > $2`,
+		ip, time.Now().Add(-s.login.IPWindow)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчета попыток входа: %w", err)
+	}
+	return count, nil
+}
+
+// registerFailure - учет неудачной попытки в транзакции tx: счетчик учетной записи, журнал попыток,
+// блокировка учетной записи или IP при достижении порога. Возвращает ErrInvalidCredentials.
+func (s *AuthServiceImpl) registerFailure(ctx context.Context, tx *db.Tx, customerID *int64, email, ip string, ipFailures int) error {
+	metrics.FailedLogins.Inc()
+	log := logger.FromContext(ctx)
+
+	if err := s.recordAttempt(ctx, tx, customerID, email, ip, false); err != nil {
+		return err
+	}
+
+	if s.login.IPMaxFailedAttempts > 0 && ipFailures+1 == s.login.IPMaxFailedAttempts {
+		log.WarnContext(ctx, "IP заблокирован после неудачных попыток входа", slog.String("ip", ip))
+		s.recordEvent(ctx, SecurityEventIPBlocked, customerID, nil, email, ip, map[string]any{
+			"failed_attempts": ipFailures + 1,
+			"window":          s.login.IPWindow.String(),
+		})
+	}
+
+	if customerID == nil {
+		return ErrInvalidCredentials
+	}
+
+	maxFailed := s.login.MaxFailedAttempts
+	if maxFailed <= 0 {
+		maxFailed = math.MaxInt32 // блокировка учетных записей отключена
+	}
+
+	var failed int
+	var lockedUntil sql.NullTime
+	err := tx.QueryRowContext(ctx, `
+        UPDATE customers
+        SET failed_login_attempts = failed_login_attempts + 1,
+            last_failed_login_at = now(),
+            locked_until = CASE WHEN failed_login_attempts + 1 >= $2 THEN now() + $3::interval ELSE locked_until END
+        WHERE id = $1
+        RETURNING failed_login_attempts, locked_until`,
+		*customerID, maxFailed, fmt.Sprintf("%d milliseconds", s.login.LockoutDuration.Milliseconds())).Scan(&failed, &lockedUntil)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления счетчика попыток входа: %w", err)
+	}
+
+	// Блокировка ставится при каждой неудаче начиная с порога, в том числе повторно
+	// после истечения прежней блокировки
+	if s.login.MaxFailedAttempts > 0 && failed >= s.login.MaxFailedAttempts {
+		log.WarnContext(ctx, "Учетная запись заблокирована после неудачных попыток входа",
+			slog.Int64("customer_id", *customerID))
+		s.recordEvent(ctx, SecurityEventAccountLocked, customerID, nil, email, ip, map[string]any{
+			"failed_attempts": failed,
+			"locked_until":    lockedUntil.Time,
+		})
+	}
+
+	return ErrInvalidCredentials
+}
+
+// registerSuccess - сброс счетчиков и перехэширование пароля, хранящегося открытым текстом,
+// в транзакции tx.
+func (s *AuthServiceImpl) registerSuccess(ctx context.Context, tx *db.Tx, customerID int64, email, ip, stored, password string) error {
+	hash := stored
+	if !auth.IsHashed(stored) {
+		var err error
+		if hash, err = auth.HashPassword(password); err != nil {
+			return fmt.Errorf("ошибка хэширования пароля: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE customers
+        SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL, password = $2
+        WHERE id = $1`, customerID, hash); err != nil {
+		return fmt.Errorf("ошибка сброса счетчика попыток входа: %w", err)
+	}
+
+	return s.recordAttempt(ctx, tx, &customerID, email, ip, true)
+}
+
+// recordAttempt - запись попытки входа в журнал. Email хранится зашифрованным,
+// для поиска и удаления записей по email сохраняется его слепой индекс.
+func (s *AuthServiceImpl) recordAttempt(ctx context.Context, tx *db.Tx, customerID *int64, email, ip string, success bool) error {
+	encrypted, err := s.cipher.Encrypt(email)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO login_attempts (customer_id, email, email_hash, ip, success)
+        VALUES ($1, $2, $3, $4, $5)`,
+		customerID, encrypted, s.cipher.BlindIndex(email), ip, success); err != nil {
+		return fmt.Errorf("ошибка записи попытки входа: %w", err)
+	}
+	return nil
+}
+
+// UnlockCustomer - снятие блокировки учетной записи администратором.
+func (s *AuthServiceImpl) UnlockCustomer(ctx context.Context, customerID, adminID int64, ip string) error {
+	var email string
+	err := s.db.QueryRowContext(ctx, `
+        UPDATE customers
+        SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL
+        WHERE id = $1
+        RETURNING email`, customerID).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCustomerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка разблокировки клиента: %w", err)
+	}
+	if email, err = s.cipher.Decrypt(email); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, SecurityEventAccountUnlocked, &customerID, &adminID, email, ip, nil)
+	return nil
+}
+
+// GetSecurityEvents - журнал подозрительной активности, новые записи первыми.
+func (s *AuthServiceImpl) GetSecurityEvents(ctx context.Context, filter SecurityEventFilter) ([]SecurityEvent, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, event_type, customer_id, actor_id, email, ip, COALESCE(details, '{}'::jsonb), created_at
+        FROM security_events
+        WHERE ($1 = 0 OR customer_id = $1)
+          AND ($2 = '' OR ip = $2)
+          AND ($3 = '' OR event_type = $3)
+        ORDER BY created_at DESC
+        LIMIT $4`,
+		filter.CustomerID, filter.IP, filter.EventType, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	events := []SecurityEvent{}
+	for rows.Next() {
+		var e SecurityEvent
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.EventType, &e.CustomerID, &e.ActorID, &e.Email, &e.IP, &details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		if e.Email, err = s.cipher.DecryptPtr(e.Email); err != nil {
+			return nil, fmt.Errorf("ошибка расшифровки email события %d: %w", e.ID, err)
+		}
+		e.Details = details
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return events, nil
+}
+
+// recordEvent - запись в журнал подозрительной активности. Email хранится зашифрованным
+// вместе со слепым индексом. Ошибка записи журнала не должна ломать вход, поэтому она
+// только логируется.
+func (s *AuthServiceImpl) recordEvent(ctx context.Context, eventType string, customerID, actorID *int64, email, ip string, details map[string]any) {
+	log := logger.FromContext(ctx)
+
+	var encrypted, emailHash *string
+	if email != "" {
+		stored, err := s.cipher.Encrypt(email)
+		if err != nil {
+			log.ErrorContext(ctx, "Ошибка шифрования email события безопасности",
+				slog.String("event_type", eventType), slog.Any("error", err))
+			return
+		}
+		hash := s.cipher.BlindIndex(email)
+		encrypted, emailHash = &stored, &hash
+	}
+
+	// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+	var payload *string
+	if details != nil {
+		if b, err := json.Marshal(details); err == nil {
+			str := string(b)
+			payload = &str
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+        INSERT INTO security_events (event_type, customer_id, actor_id, email, email_hash, ip, details)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		eventType, customerID, actorID, encrypted, emailHash, ip, payload); err != nil {
+		log.ErrorContext(ctx, "Ошибка записи события безопасности",
+			slog.String("event_type", eventType), slog.Any("error", err))
+	}
+}
@@ -4,34 +4,154 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/slug"
+)
+
+var (
+	// ErrCategoryNotFound - категория не найдена или удалена.
+	ErrCategoryNotFound = errors.New("категория не найдена")
+	// ErrParentCategoryNotFound - указанная родительская категория не найдена или удалена.
+	ErrParentCategoryNotFound = errors.New("родительская категория не найдена")
+	// ErrCategoryCycle - категорию нельзя переместить в саму себя или в свою подкатегорию.
+	ErrCategoryCycle = errors.New("категорию нельзя переместить в саму себя или в свою подкатегорию")
+	// ErrInvalidSlug - слаг содержит недопустимые символы.
+	ErrInvalidSlug = errors.New("слаг может содержать только латинские буквы в нижнем регистре, цифры и дефисы")
+	// ErrCategorySlugTaken - слаг уже занят другой категорией.
+	ErrCategorySlugTaken = errors.New("слаг уже используется другой категорией")
 )
 
 type Category struct {
 	ID      int64  `json:"id"`
 	Name    string `json:"name"`
 	StoreID *int64 `json:"store_id"` // Используем sql.NullInt64 для поддержки NULL
+	// Товары категории продаются только клиентам с подтвержденным возрастом
+	AgeRestricted bool `json:"age_restricted"`
+	// Родительская категория; nil у корневых
+	ParentID *int64 `json:"parent_id"`
+	// Порядок среди соседних категорий
+	Position int `json:"position"`
+	// Если не указан, формируется из названия
+	Slug string `json:"slug" validate:"max=255"`
+	// Момент удаления; удаленные категории видны только администратору с include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// CategoryNode - категория с подкатегориями.
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Breadcrumb - звено пути от корня дерева категорий.
+type Breadcrumb struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// CategoryFilter - фильтр списка категорий.
+type CategoryFilter struct {
+	IncludeDeleted bool
+}
+
+const categoryColumns = "id, name, store_id, age_restricted, parent_id, position, slug, deleted_at"
+
+// scanCategory - чтение строки, выбранной с categoryColumns.
+func scanCategory(row interface{ Scan(dest ...any) error }, category *Category) error {
+	return row.Scan(
+		&category.ID,
+		&category.Name,
+		&category.StoreID,
+		&category.AgeRestricted,
+		&category.ParentID,
+		&category.Position,
+		&category.Slug,
+		&category.DeletedAt,
+	)
+}
+
+// categoryDescendants - подзапрос ID категории и всех ее неудаленных подкатегорий;
+// param - плейсхолдер с ID категории.
+func categoryDescendants(param string) string {
+	return `
+        WITH RECURSIVE tree AS (
+            SELECT id FROM categories WHERE id = ` + param + `
+            UNION ALL
+            SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+            WHERE c.deleted_at IS NULL
+        )
+        SELECT id FROM tree`
+}
+
+// categoryAgeRestricted - условие «категория или один из ее предков только для
+// совершеннолетних»; param - выражение с ID категории.
+func categoryAgeRestricted(param string) string {
+	return `EXISTS (
+            WITH RECURSIVE path AS (
+                SELECT id, parent_id, age_restricted FROM categories WHERE id = ` + param + `
+                UNION ALL
+                SELECT c.id, c.parent_id, c.age_restricted
+                FROM categories c JOIN path p ON c.id = p.parent_id
+            )
+            SELECT 1 FROM path WHERE age_restricted
+        )`
+}
+
+// categoryBreadcrumbs - путь от корня дерева до категории.
+func categoryBreadcrumbs(ctx context.Context, q *db.DB, categoryID int64) ([]Breadcrumb, error) {
+	rows, err := q.QueryContext(ctx, `
+        WITH RECURSIVE path AS (
+            SELECT id, name, slug, parent_id, 0 AS depth FROM categories WHERE id = $1
+            UNION ALL
+            SELECT c.id, c.name, c.slug, c.parent_id, p.depth + 1
+            FROM categories c JOIN path p ON c.id = p.parent_id
+        )
+        SELECT id, name, slug FROM path ORDER BY depth DESC`, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пути категории: %w", err)
+	}
+	defer rows.Close()
+
+	breadcrumbs := []Breadcrumb{}
+	for rows.Next() {
+		var b Breadcrumb
+		if err := rows.Scan(&b.ID, &b.Name, &b.Slug); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		breadcrumbs = append(breadcrumbs, b)
+	}
+	return breadcrumbs, rows.Err()
 }
 
 type CategoryService interface {
-	GetAllCategories(ctx context.Context) ([]Category, error)
+	GetAllCategories(ctx context.Context, filter CategoryFilter) ([]Category, error)
+	GetCategoryTree(ctx context.Context) ([]CategoryNode, error)
 	GetCategoryByID(ctx context.Context, id string) (*Category, error)
 	CreateCategory(ctx context.Context, category Category) (*Category, error)
 	UpdateCategory(ctx context.Context, category Category) (*Category, error)
 	DeleteCategory(ctx context.Context, id string) error
+	RestoreCategory(ctx context.Context, id string) (*Category, error)
 }
 
 type CategoryServiceImpl struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewCategoryService(db *sql.DB) *CategoryServiceImpl {
+func NewCategoryService(db *db.DB) *CategoryServiceImpl {
 	return &CategoryServiceImpl{
 		db: db,
 	}
 }
 
-func (s *CategoryServiceImpl) GetAllCategories(ctx context.Context) ([]Category, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, name, store_id FROM categories")
+func (s *CategoryServiceImpl) GetAllCategories(ctx context.Context, filter CategoryFilter) ([]Category, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+categoryColumns+" FROM categories WHERE $1 OR deleted_at IS NULL ORDER BY position, id",
+		filter.IncludeDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -40,20 +160,9 @@ func (s *CategoryServiceImpl) GetAllCategories(ctx context.Context) ([]Category,
 	var categories []Category
 	for rows.Next() {
 		var category Category
-		var storeID sql.NullInt64 // Временная переменная для считывания значения из базы данных
-
-		if err := rows.Scan(&category.ID, &category.Name, &storeID); err != nil {
+		if err := scanCategory(rows, &category); err != nil {
 			return nil, err
 		}
-
-		// Присваиваем значение StoreID
-		if storeID.Valid {
-			category.StoreID = new(int64) // Создаем новый int64 и присваиваем значение
-			*category.StoreID = storeID.Int64
-		} else {
-			category.StoreID = nil // Устанавливаем в nil, если значение NULL в базе данных
-		}
-
 		categories = append(categories, category)
 	}
 
@@ -64,19 +173,44 @@ func (s *CategoryServiceImpl) GetAllCategories(ctx context.Context) ([]Category,
 	return categories, nil
 }
 
+// GetCategoryTree - дерево неудаленных категорий. Подкатегории удаленной
+// категории в дерево не попадают.
+func (s *CategoryServiceImpl) GetCategoryTree(ctx context.Context) ([]CategoryNode, error) {
+	categories, err := s.GetAllCategories(ctx, CategoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]Category)
+	var roots []Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func(list []Category) []CategoryNode
+	build = func(list []Category) []CategoryNode {
+		nodes := make([]CategoryNode, 0, len(list))
+		for _, c := range list {
+			nodes = append(nodes, CategoryNode{Category: c, Children: build(children[c.ID])})
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
 func (s *CategoryServiceImpl) GetCategoryByID(ctx context.Context, id string) (*Category, error) {
 	var category Category
 
 	// Выполнение запроса с явным указанием столбцов
-	err := s.db.QueryRowContext(ctx, "SELECT id, name, store_id FROM categories WHERE id = $1", id).Scan(
-		&category.ID,
-		&category.Name,
-		&category.StoreID,
-	)
+	err := scanCategory(s.db.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id = $1 AND deleted_at IS NULL", id), &category)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("категория не найдена")
+			return nil, ErrCategoryNotFound
 		}
 		return nil, err // Возврат ошибки при других проблемах
 	}
@@ -85,48 +219,257 @@ func (s *CategoryServiceImpl) GetCategoryByID(ctx context.Context, id string) (*
 }
 
 func (s *CategoryServiceImpl) CreateCategory(ctx context.Context, category Category) (*Category, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	category.DeletedAt = nil
+
+	if err := checkParentCategory(ctx, tx, 0, category.ParentID); err != nil {
+		return nil, err
+	}
+	if category.Slug, err = categorySlug(ctx, tx, 0, category.Slug, category.Name); err != nil {
+		return nil, err
+	}
+
 	// Используем RETURNING для получения ID вставленной записи
 	query := `
-        INSERT INTO categories (name, store_id) 
-        VALUES ($1, $2) 
+        INSERT INTO categories (name, store_id, age_restricted, parent_id, position, slug) 
+        VALUES ($1, $2, $3, $4, $5, $6) 
         RETURNING id`
 
 	// Выполняем запрос и сканируем возвращаемый ID в структуру category
-	err := s.db.QueryRowContext(ctx, query, category.Name, category.StoreID).Scan(&category.ID)
+	err = tx.QueryRowContext(ctx, query, category.Name, category.StoreID, category.AgeRestricted,
+		category.ParentID, category.Position, category.Slug).Scan(&category.ID)
 
 	if err != nil {
 		return nil, err // Возврат ошибки при выполнении запроса
 	}
 
+	if err := recordAudit(ctx, tx, AuditEntityCategory, category.ID, AuditActionCreate, nil, category); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
 	return &category, nil // Возврат созданной категории с установленным ID
 }
 
 func (s *CategoryServiceImpl) UpdateCategory(ctx context.Context, category Category) (*Category, error) {
-	// Проверка существования категории
-	var exists bool
-	err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id=$1)", category.ID).Scan(&exists)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Проверка существования категории; прежние значения нужны для журнала аудита
+	var before Category
+	err = scanCategory(tx.QueryRowContext(ctx,
+		"SELECT "+categoryColumns+" FROM categories WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", category.ID), &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
 	if err != nil {
 		return nil, errors.New("ошибка при проверке существования категории")
 	}
-	if !exists {
-		return nil, errors.New("категория не найдена")
+
+	if !sameParent(before.ParentID, category.ParentID) {
+		if err := checkParentCategory(ctx, tx, category.ID, category.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	// Без слага в запросе сохраняется прежний, чтобы не менять адреса страниц
+	if category.Slug == "" {
+		category.Slug = before.Slug
+	} else if category.Slug, err = categorySlug(ctx, tx, category.ID, category.Slug, category.Name); err != nil {
+		return nil, err
 	}
 
 	// Обновление категории
 	query := `
         UPDATE categories 
-        SET name = $1, store_id = $2 
-        WHERE id = $3`
+        SET name = $1, store_id = $2, age_restricted = $3, parent_id = $4, position = $5, slug = $6 
+        WHERE id = $7`
 
-	_, err = s.db.ExecContext(ctx, query, category.Name, category.StoreID, category.ID)
+	_, err = tx.ExecContext(ctx, query, category.Name, category.StoreID, category.AgeRestricted,
+		category.ParentID, category.Position, category.Slug, category.ID)
 	if err != nil {
 		return nil, errors.New("ошибка при обновлении категории")
 	}
+	category.DeletedAt = nil
+
+	if err := recordAudit(ctx, tx, AuditEntityCategory, category.ID, AuditActionUpdate, before, category); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
 
 	return &category, nil // Возврат обновленной категории с установленным ID
 }
 
+// DeleteCategory - мягкое удаление категории: на нее ссылаются товары, поэтому
+// строка остается в базе до окончательного удаления фоновой задачей.
 func (s *CategoryServiceImpl) DeleteCategory(ctx context.Context, id string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
-	return err
+	return s.setDeleted(ctx, id, true, nil)
+}
+
+// RestoreCategory - восстановление удаленной категории.
+func (s *CategoryServiceImpl) RestoreCategory(ctx context.Context, id string) (*Category, error) {
+	var category Category
+	if err := s.setDeleted(ctx, id, false, &category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// setDeleted - установка или снятие отметки об удалении с записью в журнал аудита.
+// Удаление уже удаленной или отсутствующей категории ничего не делает.
+func (s *CategoryServiceImpl) setDeleted(ctx context.Context, id string, deleted bool, result *Category) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before Category
+	err = scanCategory(tx.QueryRowContext(ctx,
+		"SELECT "+categoryColumns+" FROM categories WHERE id = $1 AND (deleted_at IS NULL) = $2 FOR UPDATE",
+		id, deleted), &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		if deleted {
+			return nil
+		}
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	after := before
+	action := AuditActionRestore
+	if deleted {
+		now := time.Now()
+		after.DeletedAt = &now
+		action = AuditActionDelete
+	} else {
+		after.DeletedAt = nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE categories SET deleted_at = $2 WHERE id = $1", before.ID, after.DeletedAt); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, AuditEntityCategory, before.ID, action, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	if result != nil {
+		*result = after
+	}
+	return nil
+}
+
+// sameParent - совпадают ли родительские категории.
+func sameParent(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkParentCategory - проверка, что родитель существует и перемещение не создает цикл.
+// Перемещения сериализуются блокировкой, иначе два встречных перемещения
+// в параллельных транзакциях могли бы вместе образовать цикл.
+func checkParentCategory(ctx context.Context, tx *db.Tx, id int64, parentID *int64) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == id {
+		return ErrCategoryCycle
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('categories.parent_id'))"); err != nil {
+		return fmt.Errorf("ошибка блокировки дерева категорий: %w", err)
+	}
+
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)", *parentID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки родительской категории: %w", err)
+	}
+	if !exists {
+		return ErrParentCategoryNotFound
+	}
+
+	// Новая категория не может быть предком существующей
+	if id == 0 {
+		return nil
+	}
+	var cycle bool
+	err = tx.QueryRowContext(ctx, `
+        WITH RECURSIVE ancestors AS (
+            SELECT id, parent_id FROM categories WHERE id = $1
+            UNION ALL
+            SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+        )
+        SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)`, *parentID, id).Scan(&cycle)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки дерева категорий: %w", err)
+	}
+	if cycle {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// categorySlug - проверка указанного слага или формирование уникального слага из названия.
+func categorySlug(ctx context.Context, tx *db.Tx, id int64, value, name string) (string, error) {
+	taken := func(candidate string) (bool, error) {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM categories WHERE slug = $1 AND id <> $2)", candidate, id).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("ошибка проверки слага: %w", err)
+		}
+		return exists, nil
+	}
+
+	if value != "" {
+		if !slug.Valid(value) {
+			return "", ErrInvalidSlug
+		}
+		exists, err := taken(value)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "", ErrCategorySlugTaken
+		}
+		return value, nil
+	}
+
+	base := slug.Make(name)
+	if base == "" {
+		base = "category"
+	}
+	// Оставляем место для числового суффикса
+	if len(base) > slug.MaxLength-8 {
+		base = strings.TrimRight(base[:slug.MaxLength-8], "-")
+	}
+	candidate := base
+	for i := 2; ; i++ {
+		exists, err := taken(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
 }
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+var ErrAddressNotFound = errors.New("адрес не найден")
+
+// customerByEmail - условие поиска клиента по email ($1 - слепой индекс, $2 - email
+// в нижнем регистре). Открытый email проверяется, пока фоновая задача не заполнила индекс.
+const customerByEmail = "(email_hash = $1 OR (email_hash IS NULL AND lower(email) = $2))"
+
+// Profile - профиль текущего клиента.
+type Profile struct {
+	ID                    int64      `json:"id"`
+	FirstName             string     `json:"first_name"`
+	LastName              string     `json:"last_name"`
+	Email                 string     `json:"email"`
+	Phone                 *string    `json:"phone"`
+	Role                  string     `json:"role"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	AgeVerificationStatus string     `json:"age_verification_status"`
+}
+
+// UpdateProfileRequest - частичное обновление профиля; не переданные поля не меняются.
+// Email меняется отдельно, так как требует повторного подтверждения.
+type UpdateProfileRequest struct {
+	FirstName *string `json:"first_name" validate:"omitempty,min=1,max=255"`
+	LastName  *string `json:"last_name" validate:"omitempty,min=1,max=255"`
+	Phone     *string `json:"phone" validate:"omitempty,max=20"`
+}
+
+// Address - адрес из адресной книги клиента.
+type Address struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	City      string    `json:"city" validate:"required,max=255"`
+	Street    string    `json:"street" validate:"required,max=255"`
+	Postcode  string    `json:"postcode" validate:"required,max=20"`
+	Region    string    `json:"region" validate:"required,max=16"`
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AddressSnapshot - копия адреса, сохраняемая в покупке.
+type AddressSnapshot struct {
+	Name     string `json:"name"`
+	City     string `json:"city"`
+	Street   string `json:"street"`
+	Postcode string `json:"postcode"`
+	Region   string `json:"region"`
+}
+
+type CustomerService interface {
+	GetProfile(ctx context.Context, customerID int64) (*Profile, error)
+	UpdateProfile(ctx context.Context, customerID int64, req UpdateProfileRequest) (*Profile, error)
+	GetAddresses(ctx context.Context, customerID int64) ([]Address, error)
+	GetAddress(ctx context.Context, customerID, id int64) (*Address, error)
+	CreateAddress(ctx context.Context, customerID int64, address Address) (*Address, error)
+	UpdateAddress(ctx context.Context, customerID int64, address Address) (*Address, error)
+	DeleteAddress(ctx context.Context, customerID, id int64) error
+	SetDefaultAddress(ctx context.Context, customerID, id int64) (*Address, error)
+}
+
+// CustomerServiceImpl - профиль и адресная книга клиента. Email, телефон
+// и адреса хранятся зашифрованными.
+type CustomerServiceImpl struct {
+	db     *db.DB
+	cipher *pii.Cipher
+}
+
+// NewCustomerService - функция для создания нового сервиса клиентов.
+func NewCustomerService(db *db.DB, cipher *pii.Cipher) *CustomerServiceImpl {
+	return &CustomerServiceImpl{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+const addressColumns = "id, name, city, street, postcode, region, is_default, created_at, updated_at"
+
+// scanAddress - чтение строки, выбранной с addressColumns, с расшифровкой полей.
+func scanAddress(row interface{ Scan(dest ...any) error }, c *pii.Cipher, address *Address) error {
+	if err := row.Scan(
+		&address.ID,
+		&address.Name,
+		&address.City,
+		&address.Street,
+		&address.Postcode,
+		&address.Region,
+		&address.IsDefault,
+		&address.CreatedAt,
+		&address.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	return decryptFields(c, &address.City, &address.Street, &address.Postcode)
+}
+
+// encryptFields - шифрование строковых полей на месте.
+func encryptFields(c *pii.Cipher, fields ...*string) error {
+	for _, f := range fields {
+		v, err := c.Encrypt(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+// decryptFields - расшифровка строковых полей на месте.
+func decryptFields(c *pii.Cipher, fields ...*string) error {
+	for _, f := range fields {
+		v, err := c.Decrypt(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+// GetProfile - получение профиля клиента.
+func (s *CustomerServiceImpl) GetProfile(ctx context.Context, customerID int64) (*Profile, error) {
+	var p Profile
+	err := s.db.QueryRowContext(ctx, `
+        SELECT id, first_name, last_name, email, phone, role, email_verified_at, age_verification_status
+        FROM customers WHERE id = $1`, customerID).Scan(
+		&p.ID,
+		&p.FirstName,
+		&p.LastName,
+		&p.Email,
+		&p.Phone,
+		&p.Role,
+		&p.EmailVerifiedAt,
+		&p.AgeVerificationStatus,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения профиля: %w", err)
+	}
+	if err := decryptFields(s.cipher, &p.Email); err != nil {
+		return nil, err
+	}
+	if p.Phone, err = s.cipher.DecryptPtr(p.Phone); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateProfile - обновление переданных полей профиля.
+func (s *CustomerServiceImpl) UpdateProfile(ctx context.Context, customerID int64, req UpdateProfileRequest) (*Profile, error) {
+	phone, err := s.cipher.EncryptPtr(req.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+        UPDATE customers
+        SET first_name = COALESCE($2, first_name),
+            last_name = COALESCE($3, last_name),
+            phone = COALESCE($4, phone)
+        WHERE id = $1`, customerID, req.FirstName, req.LastName, phone)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления профиля: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrCustomerNotFound
+	}
+
+	return s.GetProfile(ctx, customerID)
+}
+
+// GetAddresses - адресная книга клиента; адрес по умолчанию первым.
+func (s *CustomerServiceImpl) GetAddresses(ctx context.Context, customerID int64) ([]Address, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+addressColumns+` FROM customer_addresses
+        WHERE customer_id = $1
+        ORDER BY is_default DESC, id`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	addresses := []Address{}
+	for rows.Next() {
+		var address Address
+		if err := scanAddress(rows, s.cipher, &address); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		addresses = append(addresses, address)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	return addresses, nil
+}
+
+// GetAddress - получение адреса клиента по ID.
+func (s *CustomerServiceImpl) GetAddress(ctx context.Context, customerID, id int64) (*Address, error) {
+	var address Address
+	err := scanAddress(s.db.QueryRowContext(ctx,
+		"SELECT "+addressColumns+" FROM customer_addresses WHERE id = $1 AND customer_id = $2",
+		id, customerID), s.cipher, &address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения адреса: %w", err)
+	}
+	return &address, nil
+}
+
+// CreateAddress - добавление адреса. Первый адрес клиента становится адресом по умолчанию.
+func (s *CustomerServiceImpl) CreateAddress(ctx context.Context, customerID int64, address Address) (*Address, error) {
+	address.Region = NormalizeRegion(address.Region)
+	if err := encryptFields(s.cipher, &address.City, &address.Street, &address.Postcode); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM customer_addresses WHERE customer_id = $1", customerID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("ошибка получения адресов: %w", err)
+	}
+	if count == 0 {
+		address.IsDefault = true
+	}
+	if address.IsDefault {
+		if err := resetDefaultAddress(ctx, tx, customerID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = scanAddress(tx.QueryRowContext(ctx, `
+        INSERT INTO customer_addresses (customer_id, name, city, street, postcode, region, is_default)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING `+addressColumns,
+		customerID, address.Name, address.City, address.Street, address.Postcode, address.Region, address.IsDefault,
+	), s.cipher, &address)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения адреса: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return &address, nil
+}
+
+// UpdateAddress - замена адреса. Снять признак адреса по умолчанию нельзя,
+// можно только назначить другой адрес по умолчанию.
+func (s *CustomerServiceImpl) UpdateAddress(ctx context.Context, customerID int64, address Address) (*Address, error) {
+	address.Region = NormalizeRegion(address.Region)
+	if err := encryptFields(s.cipher, &address.City, &address.Street, &address.Postcode); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if address.IsDefault {
+		if err := resetDefaultAddress(ctx, tx, customerID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = scanAddress(tx.QueryRowContext(ctx, `
+        UPDATE customer_addresses
+        SET name = $3, city = $4, street = $5, postcode = $6, region = $7,
+            is_default = is_default OR $8, updated_at = now()
+        WHERE id = $1 AND customer_id = $2
+        RETURNING `+addressColumns,
+		address.ID, customerID, address.Name, address.City, address.Street, address.Postcode, address.Region, address.IsDefault,
+	), s.cipher, &address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления адреса: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return &address, nil
+}
+
+// DeleteAddress - удаление адреса. Если удален адрес по умолчанию, им становится
+// последний добавленный из оставшихся. Покупки сохраняют свою копию адреса.
+func (s *CustomerServiceImpl) DeleteAddress(ctx context.Context, customerID, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	err = tx.QueryRowContext(ctx,
+		"DELETE FROM customer_addresses WHERE id = $1 AND customer_id = $2 RETURNING is_default",
+		id, customerID).Scan(&wasDefault)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAddressNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка удаления адреса: %w", err)
+	}
+
+	if wasDefault {
+		if _, err := tx.ExecContext(ctx, `
+            UPDATE customer_addresses SET is_default = TRUE
+            WHERE id = (SELECT id FROM customer_addresses WHERE customer_id = $1 ORDER BY id DESC LIMIT 1)`,
+			customerID); err != nil {
+			return fmt.Errorf("ошибка назначения адреса по умолчанию: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// SetDefaultAddress - назначение адреса по умолчанию.
+func (s *CustomerServiceImpl) SetDefaultAddress(ctx context.Context, customerID, id int64) (*Address, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := resetDefaultAddress(ctx, tx, customerID); err != nil {
+		return nil, err
+	}
+
+	var address Address
+	err = scanAddress(tx.QueryRowContext(ctx, `
+        UPDATE customer_addresses SET is_default = TRUE, updated_at = now()
+        WHERE id = $1 AND customer_id = $2
+        RETURNING `+addressColumns, id, customerID), s.cipher, &address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка назначения адреса по умолчанию: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return &address, nil
+}
+
+func resetDefaultAddress(ctx context.Context, tx *db.Tx, customerID int64) error {
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE customer_addresses SET is_default = FALSE WHERE customer_id = $1 AND is_default",
+		customerID); err != nil {
+		return fmt.Errorf("ошибка сброса адреса по умолчанию: %w", err)
+	}
+	return nil
+}
+
+// deliveryAddress - адрес доставки для покупки: указанный клиентом или адрес по умолчанию.
+// Возвращает nil, если addressID не указан и адреса по умолчанию нет. Поля копии
+// остаются зашифрованными, как в адресной книге.
+func deliveryAddress(ctx context.Context, tx *db.Tx, customerID int64, addressID *int64) (*int64, *AddressSnapshot, error) {
+	query := "SELECT id, name, city, street, postcode, region FROM customer_addresses WHERE customer_id = $1 AND is_default"
+	args := []any{customerID}
+	if addressID != nil {
+		query = "SELECT id, name, city, street, postcode, region FROM customer_addresses WHERE customer_id = $1 AND id = $2"
+		args = append(args, *addressID)
+	}
+
+	var id int64
+	var snapshot AddressSnapshot
+	err := tx.QueryRowContext(ctx, query, args...).Scan(
+		&id,
+		&snapshot.Name,
+		&snapshot.City,
+		&snapshot.Street,
+		&snapshot.Postcode,
+		&snapshot.Region,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		if addressID != nil {
+			return nil, nil, ErrAddressNotFound
+		}
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения адреса доставки: %w", err)
+	}
+	return &id, &snapshot, nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/spreadsheet"
+)
+
+// productExportColumns - столбцы выгрузки товаров; названия совпадают с полями импорта,
+// поэтому выгрузку можно изменить и загрузить обратно. Характеристики идут
+// в конце столбцами attr.<code>.
+var productExportColumns = []string{
+	"product_id", "sku", "name", "description", "price", "category", "manufacturer_id",
+	"color", "coil_resistance", "nicotine_strength", "variant_price", "stock", "deleted_at",
+}
+
+// purchaseExportColumns - столбцы выгрузки покупок: строка на позицию покупки.
+var purchaseExportColumns = []string{
+	"purchase_id", "created_at", "customer_id", "store_id", "region", "status",
+	"product_id", "variant_id", "sku", "quantity", "price", "amount",
+}
+
+// PurchaseExportFilter - условия выборки покупок для выгрузки.
+type PurchaseExportFilter struct {
+	// Покупки, оформленные не раньше From и раньше To
+	From *time.Time
+	To   *time.Time
+	// Покупки магазина
+	StoreID int64
+	// Последний статус доставки
+	Status string
+}
+
+type ExportService interface {
+	ExportProducts(ctx context.Context, filter ProductFilter, w spreadsheet.Writer) error
+	ExportPurchases(ctx context.Context, filter PurchaseExportFilter, w spreadsheet.Writer) error
+}
+
+// ExportServiceImpl - выгрузка каталога и покупок. Строки записываются по мере чтения
+// из базы, поэтому память не зависит от размера выгрузки. Ошибка до первой
+// записанной строки означает, что в w ничего не отправлено.
+type ExportServiceImpl struct {
+	db *db.DB
+}
+
+// NewExportService - функция для создания нового сервиса выгрузок.
+func NewExportService(db *db.DB) *ExportServiceImpl {
+	return &ExportServiceImpl{
+		db: db,
+	}
+}
+
+// ExportProducts - выгрузка товаров, подходящих под фильтр каталога: строка на вариант,
+// товары без вариантов - одной строкой без артикула.
+func (s *ExportServiceImpl) ExportProducts(ctx context.Context, filter ProductFilter, w spreadsheet.Writer) error {
+	where, args, err := productWhere(filter)
+	if err != nil {
+		return err
+	}
+
+	// Набор характеристик и строки должны быть из одного снимка базы
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	codeRows, err := tx.QueryContext(ctx, `
+        SELECT DISTINCT jsonb_object_keys(p.attributes)
+        FROM products p
+        WHERE `+where+`
+        ORDER BY 1`, args...)
+	if err != nil {
+		return fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer codeRows.Close()
+	var codes []string
+	for codeRows.Next() {
+		var code string
+		if err := codeRows.Scan(&code); err != nil {
+			return fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	if err := codeRows.Err(); err != nil {
+		return fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT p.id, v.sku, p.name, p.description, p.price, c.slug, p.manufacturer_id,
+            v.color, v.coil_resistance, v.nicotine_strength, v.price, v.stock, p.deleted_at, p.attributes
+        FROM products p
+        LEFT JOIN categories c ON c.id = p.category_id
+        LEFT JOIN product_variants v ON v.product_id = p.id AND v.deleted_at IS NULL
+        WHERE `+where+`
+        ORDER BY p.id, v.position, v.id`, args...)
+	if err != nil {
+		return fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	columns := slices.Clone(productExportColumns)
+	for _, code := range codes {
+		columns = append(columns, importAttributePrefix+code)
+	}
+	if err := w.WriteHeader(columns); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var (
+			id                                int64
+			sku, description, category, color *string
+			name                              string
+			price                             float64
+			manufacturerID, stock             *int64
+			coil, nicotine, variantPrice      *float64
+			deletedAt                         *time.Time
+			attributesJSON                    []byte
+		)
+		if err := rows.Scan(&id, &sku, &name, &description, &price, &category, &manufacturerID,
+			&color, &coil, &nicotine, &variantPrice, &stock, &deletedAt, &attributesJSON); err != nil {
+			return fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		var attributes map[string]any
+		if attributesJSON != nil {
+			if err := json.Unmarshal(attributesJSON, &attributes); err != nil {
+				return fmt.Errorf("ошибка чтения характеристик товара %d: %w", id, err)
+			}
+		}
+
+		values := []any{id, exportValue(sku), name, exportValue(description), price, exportValue(category),
+			exportValue(manufacturerID), exportValue(color), exportValue(coil), exportValue(nicotine),
+			exportValue(variantPrice), exportValue(stock), exportValue(deletedAt)}
+		for _, code := range codes {
+			values = append(values, attributeCell(attributes[code]))
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return w.Close()
+}
+
+// ExportPurchases - выгрузка покупок со строками. Покупка без строк в purchase_items
+// выгружается одной строкой своего товара по цене на момент покупки; у покупок,
+// оформленных до сохранения цены, - по текущей цене варианта или товара.
+// Статус - последний статус доставки.
+// Персональные данные клиента и адрес доставки в выгрузку не попадают.
+func (s *ExportServiceImpl) ExportPurchases(ctx context.Context, filter PurchaseExportFilter, w spreadsheet.Writer) error {
+	var args []any
+	param := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where := "TRUE"
+	if filter.From != nil {
+		where += "\n          AND p.created_at >= " + param(*filter.From)
+	}
+	if filter.To != nil {
+		where += "\n          AND p.created_at < " + param(*filter.To)
+	}
+	if filter.StoreID != 0 {
+		where += "\n          AND p.store_id = " + param(filter.StoreID)
+	}
+	if filter.Status != "" {
+		where += "\n          AND d.status = " + param(filter.Status)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT p.id, p.created_at, p.customer_id, p.store_id, p.delivery_region, d.status,
+            l.product_id, l.variant_id, l.sku, l.quantity, l.price, l.quantity * l.price
+        FROM purchases p
+        LEFT JOIN LATERAL (
+            SELECT d.status FROM deliveries d
+            WHERE d.order_id = p.id
+            ORDER BY d.created_at DESC, d.id DESC
+            LIMIT 1
+        ) d ON TRUE
+        CROSS JOIN LATERAL (
+            SELECT i.id AS item_id, i.product_id, i.variant_id, v.sku, i.quantity, i.price
+            FROM purchase_items i
+            LEFT JOIN product_variants v ON v.id = i.variant_id
+            WHERE i.purchase_id = p.id
+            UNION ALL
+            SELECT 0, p.product_id, p.variant_id, p.sku, p.quantity,
+                COALESCE(p.price, (SELECT COALESCE(v.price, pr.price)
+                 FROM products pr
+                 LEFT JOIN product_variants v ON v.id = p.variant_id
+                 WHERE pr.id = p.product_id))
+            WHERE NOT EXISTS (SELECT 1 FROM purchase_items i WHERE i.purchase_id = p.id)
+        ) l
+        WHERE `+where+`
+        ORDER BY p.id, l.item_id`, args...)
+	if err != nil {
+		return fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	if err := w.WriteHeader(purchaseExportColumns); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id                                        int64
+			createdAt                                 *time.Time
+			customerID, storeID, productID, variantID *int64
+			quantity                                  *int64
+			region, status, sku                       *string
+			price, amount                             *float64
+		)
+		if err := rows.Scan(&id, &createdAt, &customerID, &storeID, &region, &status,
+			&productID, &variantID, &sku, &quantity, &price, &amount); err != nil {
+			return fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		if err := w.WriteRow([]any{id, exportValue(createdAt), exportValue(customerID), exportValue(storeID),
+			exportValue(region), exportValue(status), exportValue(productID), exportValue(variantID),
+			exportValue(sku), exportValue(quantity), exportValue(price), exportValue(amount)}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return w.Close()
+}
+
+// exportValue - значение ячейки из столбца, допускающего NULL.
+func exportValue[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// attributeCell - значение характеристики в виде, который принимает импорт.
+func attributeCell(v any) any {
+	switch v := v.(type) {
+	case nil, string, bool, float64:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
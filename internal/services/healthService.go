@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+)
+
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// DependencyStatus - состояние отдельной зависимости сервиса. Текст ошибки и подробности
+// (например, список миграций) не отдаются наружу: эндпоинт доступен без авторизации.
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"-"`
+	Details   any    `json:"-"`
+}
+
+// HealthReport - результат проверки готовности сервиса.
+type HealthReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+type HealthService interface {
+	Readiness(ctx context.Context) HealthReport
+}
+
+// HealthServiceImpl - проверка зависимостей сервиса (база данных, миграции).
+type HealthServiceImpl struct {
+	db      *db.DB
+	timeout time.Duration
+}
+
+// NewHealthService - создание сервиса проверки состояния с таймаутом на каждую проверку.
+func NewHealthService(db *db.DB, timeout time.Duration) *HealthServiceImpl {
+	return &HealthServiceImpl{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Readiness - проверка всех зависимостей. Сервис готов, только если готовы все зависимости.
+func (s *HealthServiceImpl) Readiness(ctx context.Context) HealthReport {
+	report := HealthReport{
+		Status: HealthStatusOK,
+		Dependencies: map[string]DependencyStatus{
+			"database":   s.check(ctx, s.checkDatabase),
+			"migrations": s.check(ctx, s.checkMigrations),
+		},
+	}
+
+	for _, dep := range report.Dependencies {
+		if dep.Status != HealthStatusOK {
+			report.Status = HealthStatusUnavailable
+		}
+	}
+	return report
+}
+
+// check - выполнение одной проверки с таймаутом и замером времени.
+func (s *HealthServiceImpl) check(ctx context.Context, fn func(ctx context.Context) (any, error)) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := fn(ctx)
+	status := DependencyStatus{
+		Status:    HealthStatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		status.Status = HealthStatusUnavailable
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func (s *HealthServiceImpl) checkDatabase(ctx context.Context) (any, error) {
+	return nil, s.db.PingContext(ctx)
+}
+
+func (s *HealthServiceImpl) checkMigrations(ctx context.Context) (any, error) {
+	status, err := s.db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Pending) > 0 {
+		return status, fmt.Errorf("не применено миграций: %d", len(status.Pending))
+	}
+	return status, nil
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/config"
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/imaging"
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrImageNotFound - изображение не найдено, относится к другому товару или нет копии такого размера.
+	ErrImageNotFound = errors.New("изображение не найдено")
+	// ErrInvalidImage - файл не удалось прочитать как изображение JPEG или PNG.
+	ErrInvalidImage = errors.New("файл не является изображением JPEG или PNG")
+	// ErrImageTooLarge - изображение содержит больше пикселей, чем разрешено.
+	ErrImageTooLarge = errors.New("слишком большое разрешение изображения")
+	// ErrInvalidImageOrder - новый порядок не перечисляет все изображения товара ровно по одному разу.
+	ErrInvalidImageOrder = errors.New("порядок должен содержать каждое изображение товара ровно один раз")
+)
+
+// ProductImage - изображение товара.
+type ProductImage struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"product_id"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	// Размер исходного файла в байтах
+	Size     int    `json:"size"`
+	Position int    `json:"position"`
+	URL      string `json:"url"`
+	// Адреса уменьшенных копий: сторона квадрата -> адрес
+	Thumbnails map[string]string `json:"thumbnails"`
+	CreatedAt  time.Time         `json:"created_at"`
+
+	key   string
+	sizes []int64
+}
+
+// ImageUpload - загружаемый файл изображения.
+type ImageUpload struct {
+	FileName string
+	Content  []byte
+}
+
+// ImageOrderRequest - новый порядок изображений товара: все ID изображений товара.
+type ImageOrderRequest struct {
+	IDs []int64 `json:"ids" validate:"required"`
+}
+
+// ImageFile - файл изображения или его уменьшенной копии. Содержимое по адресу
+// изображения не меняется, поэтому ETag зависит только от ключа в хранилище.
+type ImageFile struct {
+	ETag        string
+	ContentType string
+
+	key   string
+	store storage.BlobStore
+}
+
+// Open - чтение файла из хранилища; Body нужно закрыть.
+func (f *ImageFile) Open(ctx context.Context) (*storage.Object, error) {
+	obj, err := f.store.Get(ctx, f.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения изображения: %w", err)
+	}
+	return obj, nil
+}
+
+const imageColumns = "i.id, i.product_id, i.key, i.content_type, i.width, i.height, i.size, i.thumbnails, i.position, i.created_at"
+
+// scanImage - чтение строки, выбранной с imageColumns.
+func scanImage(row interface{ Scan(dest ...any) error }, img *ProductImage) error {
+	if err := row.Scan(&img.ID, &img.ProductID, &img.key, &img.ContentType, &img.Width, &img.Height,
+		&img.Size, pq.Array(&img.sizes), &img.Position, &img.CreatedAt); err != nil {
+		return err
+	}
+	img.setURLs()
+	return nil
+}
+
+// setURLs - адреса файла и уменьшенных копий.
+func (img *ProductImage) setURLs() {
+	img.URL = fmt.Sprintf("/api/v1/products/%d/images/%d/file", img.ProductID, img.ID)
+	img.Thumbnails = make(map[string]string, len(img.sizes))
+	for _, size := range img.sizes {
+		img.Thumbnails[strconv.FormatInt(size, 10)] = fmt.Sprintf("%s?size=%d", img.URL, size)
+	}
+}
+
+// keys - ключи оригинала и уменьшенных копий в хранилище.
+func (img *ProductImage) keys() []string {
+	keys := []string{img.key}
+	for _, size := range img.sizes {
+		keys = append(keys, thumbnailKey(img.key, int(size)))
+	}
+	return keys
+}
+
+// thumbnailKey - ключ уменьшенной копии: рядом с оригиналом, с тем же расширением.
+func thumbnailKey(key string, size int) string {
+	return path.Join(path.Dir(key), strconv.Itoa(size)+path.Ext(key))
+}
+
+type ImageService interface {
+	GetProductImages(ctx context.Context, productID int64) ([]ProductImage, error)
+	UploadImages(ctx context.Context, productID int64, uploads []ImageUpload) ([]ProductImage, error)
+	ReorderImages(ctx context.Context, productID int64, ids []int64) ([]ProductImage, error)
+	DeleteImage(ctx context.Context, productID, imageID int64) error
+	GetImageFile(ctx context.Context, productID, imageID int64, size int) (*ImageFile, error)
+}
+
+// ImageServiceImpl - изображения товаров: файлы в хранилище, описания и порядок в базе.
+type ImageServiceImpl struct {
+	db    *db.DB
+	store storage.BlobStore
+	cfg   config.Images
+}
+
+// NewImageService - функция для создания нового сервиса изображений товаров.
+func NewImageService(db *db.DB, store storage.BlobStore, cfg config.Images) *ImageServiceImpl {
+	return &ImageServiceImpl{
+		db:    db,
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// GetProductImages - изображения неудаленного товара по порядку.
+func (s *ImageServiceImpl) GetProductImages(ctx context.Context, productID int64) ([]ProductImage, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки продукта: %w", err)
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+	return productImages(ctx, s.db, productID)
+}
+
+// UploadImages - добавление изображений в конец списка изображений товара.
+// Файлы проверяются до записи в хранилище; если сохранить изображения не удалось,
+// записанные файлы удаляются.
+func (s *ImageServiceImpl) UploadImages(ctx context.Context, productID int64, uploads []ImageUpload) ([]ProductImage, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки продукта: %w", err)
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+
+	infos := make([]imaging.Info, len(uploads))
+	for i, u := range uploads {
+		info, err := imaging.DecodeConfig(u.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", u.FileName, ErrInvalidImage)
+		}
+		if info.Width <= 0 || info.Height <= 0 {
+			return nil, fmt.Errorf("%s: %w", u.FileName, ErrInvalidImage)
+		}
+		if info.Width*info.Height > s.cfg.MaxPixels {
+			return nil, fmt.Errorf("%s: %w: %dx%d", u.FileName, ErrImageTooLarge, info.Width, info.Height)
+		}
+		infos[i] = info
+	}
+
+	images := make([]ProductImage, 0, len(uploads))
+	var stored []string
+	ok := false
+	defer func() {
+		if !ok {
+			deleteImageFiles(context.WithoutCancel(ctx), s.store, stored)
+		}
+	}()
+	for i, u := range uploads {
+		img, keys, err := s.storeImage(ctx, productID, u, infos[i])
+		stored = append(stored, keys...)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка товара упорядочивает параллельные загрузки при выборе позиций
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", productID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки продукта: %w", err)
+	}
+	var position int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(max(position) + 1, 0) FROM product_images WHERE product_id = $1", productID).Scan(&position)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения изображений: %w", err)
+	}
+
+	for i := range images {
+		img := &images[i]
+		img.Position = position + i
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO product_images (product_id, key, content_type, width, height, size, thumbnails, position)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING id, created_at`,
+			img.ProductID, img.key, img.ContentType, img.Width, img.Height, img.Size,
+			pq.Array(img.sizes), img.Position).Scan(&img.ID, &img.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при вставке изображения: %w", err)
+		}
+		img.setURLs()
+		if err := recordAudit(ctx, tx, AuditEntityImage, img.ID, AuditActionCreate, nil, img); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	ok = true
+	return images, nil
+}
+
+// storeImage - запись оригинала и уменьшенных копий в хранилище. Возвращает
+// записанные ключи и при ошибке, чтобы их можно было удалить.
+func (s *ImageServiceImpl) storeImage(ctx context.Context, productID int64, u ImageUpload, info imaging.Info) (*ProductImage, []string, error) {
+	src, format, err := imaging.Decode(u.Content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", u.FileName, ErrInvalidImage)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, nil, fmt.Errorf("ошибка генерации ключа изображения: %w", err)
+	}
+	ext, contentType := "png", "image/png"
+	if format == "jpeg" {
+		ext, contentType = "jpg", "image/jpeg"
+	}
+
+	img := &ProductImage{
+		ProductID:   productID,
+		ContentType: contentType,
+		Width:       info.Width,
+		Height:      info.Height,
+		Size:        len(u.Content),
+		key:         fmt.Sprintf("products/%d/%s/original.%s", productID, hex.EncodeToString(token), ext),
+	}
+
+	var keys []string
+	if err := s.store.Put(ctx, img.key, u.Content, contentType); err != nil {
+		return nil, keys, fmt.Errorf("ошибка записи изображения: %w", err)
+	}
+	keys = append(keys, img.key)
+
+	for _, size := range s.cfg.ThumbnailSizes {
+		content, err := imaging.Encode(imaging.Thumbnail(src, size), format)
+		if err != nil {
+			return nil, keys, err
+		}
+		key := thumbnailKey(img.key, size)
+		if err := s.store.Put(ctx, key, content, contentType); err != nil {
+			return nil, keys, fmt.Errorf("ошибка записи уменьшенной копии: %w", err)
+		}
+		keys = append(keys, key)
+		img.sizes = append(img.sizes, int64(size))
+	}
+	return img, keys, nil
+}
+
+// ReorderImages - новый порядок изображений товара: ids перечисляет все изображения товара.
+func (s *ImageServiceImpl) ReorderImages(ctx context.Context, productID int64, ids []int64) ([]ProductImage, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", productID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки продукта: %w", err)
+	}
+
+	current, err := productImages(ctx, tx, productID)
+	if err != nil {
+		return nil, err
+	}
+	before := make([]int64, len(current))
+	for i, img := range current {
+		before[i] = img.ID
+	}
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, slices.Sorted(slices.Values(before))) {
+		return nil, ErrInvalidImageOrder
+	}
+
+	for position, imageID := range ids {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE product_images SET position = $2 WHERE id = $1", imageID, position); err != nil {
+			return nil, fmt.Errorf("ошибка изменения порядка изображений: %w", err)
+		}
+	}
+
+	if err := recordAudit(ctx, tx, AuditEntityProduct, productID, AuditActionUpdate,
+		map[string]any{"images": before}, map[string]any{"images": ids}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return productImages(ctx, s.db, productID)
+}
+
+// DeleteImage - удаление изображения товара. Файлы удаляются из хранилища после
+// фиксации транзакции; ошибка удаления файлов только записывается в журнал.
+func (s *ImageServiceImpl) DeleteImage(ctx context.Context, productID, imageID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var img ProductImage
+	err = scanImage(tx.QueryRowContext(ctx, `
+        SELECT `+imageColumns+`
+        FROM product_images i JOIN products p ON p.id = i.product_id
+        WHERE i.id = $1 AND i.product_id = $2 AND p.deleted_at IS NULL
+        FOR UPDATE OF i`, imageID, productID), &img)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrImageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения изображения: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_images WHERE id = $1", imageID); err != nil {
+		return fmt.Errorf("ошибка удаления изображения: %w", err)
+	}
+	if err := recordAudit(ctx, tx, AuditEntityImage, imageID, AuditActionDelete, img, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	deleteImageFiles(context.WithoutCancel(ctx), s.store, img.keys())
+	return nil
+}
+
+// GetImageFile - файл изображения неудаленного товара; size - сторона уменьшенной
+// копии или 0 для оригинала.
+func (s *ImageServiceImpl) GetImageFile(ctx context.Context, productID, imageID int64, size int) (*ImageFile, error) {
+	var img ProductImage
+	err := scanImage(s.db.QueryRowContext(ctx, `
+        SELECT `+imageColumns+`
+        FROM product_images i JOIN products p ON p.id = i.product_id
+        WHERE i.id = $1 AND i.product_id = $2 AND p.deleted_at IS NULL`, imageID, productID), &img)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения изображения: %w", err)
+	}
+
+	key := img.key
+	if size != 0 {
+		if !slices.Contains(img.sizes, int64(size)) {
+			return nil, ErrImageNotFound
+		}
+		key = thumbnailKey(img.key, size)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return &ImageFile{
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		ContentType: img.ContentType,
+		key:         key,
+		store:       s.store,
+	}, nil
+}
+
+// deleteImageFiles - удаление файлов изображений; оставшиеся файлы только занимают место,
+// поэтому ошибки записываются в журнал.
+func deleteImageFiles(ctx context.Context, store storage.BlobStore, keys []string) {
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "Ошибка удаления файла изображения",
+				slog.String("key", key), slog.Any("error", err))
+		}
+	}
+}
+
+// productImages - изображения товара по порядку.
+func productImages(ctx context.Context, q queryer, productID int64) ([]ProductImage, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT `+imageColumns+`
+        FROM product_images i
+        WHERE i.product_id = $1
+        ORDER BY i.position, i.id`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения изображений: %w", err)
+	}
+	defer rows.Close()
+
+	images := []ProductImage{}
+	for rows.Next() {
+		var img ProductImage
+		if err := scanImage(rows, &img); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return images, nil
+}
@@ -0,0 +1,904 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/audit"
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/spreadsheet"
+)
+
+const (
+	// importBatchSize - количество строк, импортируемых в одной транзакции.
+	importBatchSize = 100
+	// importMaxErrors - сколько ошибок строк сохраняется в отчете; счетчик failed учитывает все.
+	importMaxErrors = 1000
+	// importStaleAfter - через сколько без обновлений задание в статусе running считается
+	// прерванным (например, перезапуском сервера) и продолжается заново.
+	importStaleAfter = 10 * time.Minute
+	// importAttributePrefix - префикс столбцов характеристик: attr.<code>.
+	importAttributePrefix = "attr."
+)
+
+// importFields - поля, в которые можно загрузить столбцы файла, кроме характеристик.
+var importFields = []string{
+	"sku", "parent_sku", "name", "description", "price", "category", "manufacturer_id",
+	"color", "coil_resistance", "nicotine_strength", "variant_price", "stock",
+}
+
+var (
+	// ErrInvalidImport - файл не удалось прочитать или сопоставить его столбцы с полями.
+	ErrInvalidImport = errors.New("недопустимый файл импорта")
+	// ErrImportJobNotFound - задание импорта не найдено.
+	ErrImportJobNotFound = errors.New("задание импорта не найдено")
+)
+
+// ImportFile - файл импорта и сопоставление его столбцов с полями.
+type ImportFile struct {
+	FileName string
+	Content  []byte
+	// Поле -> заголовок столбца файла; столбцы с заголовком, совпадающим с полем
+	// (без учета регистра), сопоставляются автоматически
+	Mapping map[string]string
+}
+
+// ImportRowError - ошибка строки файла.
+type ImportRowError struct {
+	// Номер строки в файле; заголовок - строка 1
+	Row int `json:"row"`
+	// Поле, к которому относится ошибка
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ImportRowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("строка %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("строка %d, %s: %s", e.Row, e.Column, e.Message)
+}
+
+// ImportReport - результат импорта или проверки файла.
+type ImportReport struct {
+	TotalRows     int              `json:"total_rows"`
+	ProcessedRows int              `json:"processed_rows"`
+	Created       int              `json:"created"`
+	Updated       int              `json:"updated"`
+	Failed        int              `json:"failed"`
+	Errors        []ImportRowError `json:"errors"`
+}
+
+// ImportJob - задание импорта, выполняемое фоновой задачей.
+type ImportJob struct {
+	ID int64 `json:"id"`
+	// pending, running, completed или failed
+	Status   string `json:"status"`
+	FileName string `json:"file_name"`
+	// Только проверка файла: изменения строк откатываются, сохраняется отчет
+	DryRun bool `json:"dry_run"`
+	ImportReport
+	// Ошибка, из-за которой задание прервано
+	Error      *string    `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// importRow - непустая строка файла: значения сопоставленных полей без пробелов по краям.
+type importRow struct {
+	Line   int
+	Values map[string]string
+}
+
+// value - непустое значение поля.
+func (r importRow) value(field string) (string, bool) {
+	v, ok := r.Values[field]
+	return v, ok && v != ""
+}
+
+const importJobColumns = `id, status, file_name, dry_run, total_rows, processed_rows, created_count, updated_count,
+    failed_count, errors, error, created_at, started_at, finished_at`
+
+// scanImportJob - чтение строки, выбранной с importJobColumns.
+func scanImportJob(row interface{ Scan(dest ...any) error }, job *ImportJob) error {
+	var errorsJSON []byte
+	if err := row.Scan(&job.ID, &job.Status, &job.FileName, &job.DryRun, &job.TotalRows, &job.ProcessedRows,
+		&job.Created, &job.Updated, &job.Failed, &errorsJSON, &job.Error,
+		&job.CreatedAt, &job.StartedAt, &job.FinishedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(errorsJSON, &job.Errors)
+}
+
+type ImportService interface {
+	StartImport(ctx context.Context, file ImportFile, dryRun bool) (*ImportJob, error)
+	GetImportJob(ctx context.Context, id int64) (*ImportJob, error)
+}
+
+// ImportServiceImpl - импорт товаров из CSV и XLSX. Строка описывает вариант товара
+// и определяется артикулом: существующий вариант обновляется, новый добавляется
+// к товару варианта parent_sku или создается вместе с новым товаром.
+type ImportServiceImpl struct {
+	db *db.DB
+}
+
+// NewImportService - функция для создания нового сервиса импорта товаров.
+func NewImportService(db *db.DB) *ImportServiceImpl {
+	return &ImportServiceImpl{
+		db: db,
+	}
+}
+
+// StartImport - постановка файла в очередь импорта; с dryRun файл только проверяется.
+// Ошибки чтения файла и сопоставления столбцов возвращаются сразу.
+func (s *ImportServiceImpl) StartImport(ctx context.Context, file ImportFile, dryRun bool) (*ImportJob, error) {
+	rows, err := parseImport(file)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := json.Marshal(file.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки задания импорта: %w", err)
+	}
+	actor, err := json.Marshal(audit.ActorFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки задания импорта: %w", err)
+	}
+
+	var job ImportJob
+	// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+	err = scanImportJob(s.db.QueryRowContext(ctx, `
+        INSERT INTO import_jobs (file_name, content, mapping, actor, total_rows, dry_run)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING `+importJobColumns,
+		file.FileName, file.Content, string(mapping), string(actor), len(rows), dryRun), &job)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания задания импорта: %w", err)
+	}
+	return &job, nil
+}
+
+// GetImportJob - состояние задания импорта.
+func (s *ImportServiceImpl) GetImportJob(ctx context.Context, id int64) (*ImportJob, error) {
+	var job ImportJob
+	err := scanImportJob(s.db.QueryRowContext(ctx,
+		"SELECT "+importJobColumns+" FROM import_jobs WHERE id = $1", id), &job)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задания импорта: %w", err)
+	}
+	return &job, nil
+}
+
+// RunImports - выполнение заданий импорта до отмены контекста.
+func (s *ImportServiceImpl) RunImports(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Задания выполняются подряд, пока очередь не опустеет
+			for ctx.Err() == nil {
+				ok, err := s.runNextImport(ctx)
+				if err != nil && ctx.Err() == nil {
+					slog.Error("Ошибка импорта товаров", slog.Any("error", err))
+				}
+				if !ok || err != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// runNextImport - выполнение очередного задания; false, если очередь пуста.
+func (s *ImportServiceImpl) runNextImport(ctx context.Context) (bool, error) {
+	var (
+		job                                ImportJob
+		content                            []byte
+		mappingJSON, actorJSON, errorsJSON []byte
+	)
+	err := s.db.QueryRowContext(ctx, `
+        UPDATE import_jobs
+        SET status = 'running', started_at = COALESCE(started_at, now()), updated_at = now()
+        WHERE id = (
+            SELECT id FROM import_jobs
+            WHERE status = 'pending' OR (status = 'running' AND updated_at < $1)
+            ORDER BY id
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED)
+        RETURNING id, file_name, dry_run, content, mapping, actor, processed_rows, created_count, updated_count, failed_count, errors`,
+		time.Now().Add(-importStaleAfter)).Scan(&job.ID, &job.FileName, &job.DryRun, &content, &mappingJSON, &actorJSON,
+		&job.ProcessedRows, &job.Created, &job.Updated, &job.Failed, &errorsJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка получения задания импорта: %w", err)
+	}
+	if err := json.Unmarshal(errorsJSON, &job.Errors); err != nil {
+		return true, fmt.Errorf("ошибка чтения отчета импорта: %w", err)
+	}
+
+	if err := s.runImport(ctx, &job, content, mappingJSON, actorJSON); err != nil {
+		if ctx.Err() != nil {
+			// Задание продолжится после перезапуска
+			return true, nil
+		}
+		slog.Error("Задание импорта прервано", slog.Int64("job_id", job.ID), slog.Any("error", err))
+		if _, err := s.db.ExecContext(ctx, `
+            UPDATE import_jobs
+            SET status = 'failed', error = $2, content = NULL, updated_at = now(), finished_at = now()
+            WHERE id = $1`, job.ID, err.Error()); err != nil {
+			return true, fmt.Errorf("ошибка сохранения задания импорта: %w", err)
+		}
+		return true, nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+        UPDATE import_jobs
+        SET status = 'completed', content = NULL, updated_at = now(), finished_at = now()
+        WHERE id = $1`, job.ID); err != nil {
+		return true, fmt.Errorf("ошибка сохранения задания импорта: %w", err)
+	}
+	slog.Info("Импорт товаров завершен", slog.Int64("job_id", job.ID), slog.Bool("dry_run", job.DryRun),
+		slog.Int("created", job.Created), slog.Int("updated", job.Updated), slog.Int("failed", job.Failed))
+	return true, nil
+}
+
+// runImport - импорт необработанных строк задания пакетами; прогресс сохраняется
+// в транзакции пакета.
+func (s *ImportServiceImpl) runImport(ctx context.Context, job *ImportJob, content, mappingJSON, actorJSON []byte) error {
+	file := ImportFile{FileName: job.FileName, Content: content}
+	if err := json.Unmarshal(mappingJSON, &file.Mapping); err != nil {
+		return fmt.Errorf("ошибка чтения сопоставления столбцов: %w", err)
+	}
+	var actor audit.Actor
+	if err := json.Unmarshal(actorJSON, &actor); err != nil {
+		return fmt.Errorf("ошибка чтения автора импорта: %w", err)
+	}
+	ctx = audit.WithActor(ctx, actor)
+
+	rows, err := parseImport(file)
+	if err != nil {
+		return err
+	}
+	job.TotalRows = len(rows)
+
+	// Строки, создавшие варианты при проверке, по артикулу. Они не сохраняются между
+	// перезапусками, поэтому прерванная проверка начинается заново
+	var created map[string]importRow
+	if job.DryRun {
+		created = map[string]importRow{}
+		job.ImportReport = ImportReport{TotalRows: len(rows), Errors: []ImportRowError{}}
+	}
+
+	for job.ProcessedRows < len(rows) {
+		batch := rows[job.ProcessedRows:min(job.ProcessedRows+importBatchSize, len(rows))]
+		if err := s.importBatch(ctx, job, batch, created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importBatch - импорт пакета строк вместе с сохранением прогресса задания. При проверке
+// файла изменения пакета откатываются до точки сохранения, и блокировки строк снимаются
+// вместе с ними, поэтому проверка не задерживает покупки дольше обычного импорта.
+// Чтобы строки пакета видели варианты, созданные строками прошлых пакетов (parent_sku,
+// повторный артикул), эти строки сначала выполняются повторно без учета в отчете;
+// created пополняется артикулами, созданными в пакете.
+func (s *ImportServiceImpl) importBatch(ctx context.Context, job *ImportJob, batch []importRow, created map[string]importRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if job.DryRun {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_batch"); err != nil {
+			return fmt.Errorf("ошибка проверки пакета: %w", err)
+		}
+		if err := importRows(ctx, tx, dryRunReplay(created, batch), &ImportReport{}, nil); err != nil {
+			return err
+		}
+	}
+	report := job.ImportReport
+	report.Errors = append([]ImportRowError{}, job.Errors...)
+	var onCreated func(importRow)
+	if created != nil {
+		onCreated = func(row importRow) {
+			sku, _ := row.value("sku")
+			created[sku] = row
+		}
+	}
+	if err := importRows(ctx, tx, batch, &report, onCreated); err != nil {
+		return err
+	}
+	if job.DryRun {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_batch"); err != nil {
+			return fmt.Errorf("ошибка проверки пакета: %w", err)
+		}
+	}
+
+	errorsJSON, err := json.Marshal(report.Errors)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки отчета импорта: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE import_jobs
+        SET processed_rows = $2, created_count = $3, updated_count = $4, failed_count = $5,
+            errors = $6, updated_at = now()
+        WHERE id = $1`,
+		job.ID, report.ProcessedRows, report.Created, report.Updated, report.Failed, string(errorsJSON)); err != nil {
+		return fmt.Errorf("ошибка сохранения прогресса импорта: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	job.ImportReport = report
+	return nil
+}
+
+// dryRunReplay - строки прошлых пакетов, создавшие артикулы, на которые ссылаются строки
+// пакета batch, вместе со строками, создавшими их parent_sku, в порядке файла.
+func dryRunReplay(created map[string]importRow, batch []importRow) []importRow {
+	seen := map[string]bool{}
+	var replay []importRow
+	var need func(sku string)
+	need = func(sku string) {
+		row, ok := created[sku]
+		if !ok || seen[sku] {
+			return
+		}
+		seen[sku] = true
+		if parent, ok := row.value("parent_sku"); ok {
+			need(parent)
+		}
+		replay = append(replay, row)
+	}
+	for _, row := range batch {
+		for _, field := range []string{"sku", "parent_sku"} {
+			if sku, ok := row.value(field); ok {
+				need(sku)
+			}
+		}
+	}
+	sort.Slice(replay, func(i, j int) bool { return replay[i].Line < replay[j].Line })
+	return replay
+}
+
+// importRows - импорт строк в транзакции tx. Каждая строка выполняется в своей точке
+// сохранения: ошибка строки попадает в отчет и не отменяет остальные строки. onCreated,
+// если указан, вызывается для строк, создавших вариант.
+func importRows(ctx context.Context, tx *db.Tx, rows []importRow, report *ImportReport, onCreated func(importRow)) error {
+	for _, row := range rows {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return fmt.Errorf("ошибка импорта строки %d: %w", row.Line, err)
+		}
+		created, err := importProductRow(ctx, tx, row)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return fmt.Errorf("ошибка импорта строки %d: %w", row.Line, err)
+			}
+			report.addError(row.Line, err)
+		} else {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row"); err != nil {
+				return fmt.Errorf("ошибка импорта строки %d: %w", row.Line, err)
+			}
+			if created {
+				report.Created++
+				if onCreated != nil {
+					onCreated(row)
+				}
+			} else {
+				report.Updated++
+			}
+		}
+		report.ProcessedRows++
+	}
+	return nil
+}
+
+// addError - учет ошибки строки.
+func (r *ImportReport) addError(line int, err error) {
+	r.Failed++
+	if len(r.Errors) >= importMaxErrors {
+		return
+	}
+	var rowErr *ImportRowError
+	var attrErr *AttributeError
+	switch {
+	case errors.As(err, &rowErr):
+		rowErr.Row = line
+		r.Errors = append(r.Errors, *rowErr)
+	case errors.As(err, &attrErr):
+		r.Errors = append(r.Errors, ImportRowError{Row: line, Column: importAttributePrefix + attrErr.Code, Message: attrErr.Reason})
+	default:
+		r.Errors = append(r.Errors, ImportRowError{Row: line, Message: err.Error()})
+	}
+}
+
+// parseImport - чтение файла и сопоставление столбцов с полями. Пустые строки пропускаются.
+func parseImport(file ImportFile) ([]importRow, error) {
+	table, err := spreadsheet.Read(file.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("%w: файл пуст", ErrInvalidImport)
+	}
+
+	headers := make(map[string]int, len(table[0]))
+	for i, h := range table[0] {
+		if h = strings.TrimSpace(h); h != "" {
+			if _, ok := headers[h]; !ok {
+				headers[h] = i
+			}
+		}
+	}
+
+	columns := map[string]int{}
+	for h, i := range headers {
+		if field := strings.ToLower(h); isImportField(field) {
+			columns[field] = i
+		}
+	}
+	for field, header := range file.Mapping {
+		if !isImportField(field) {
+			return nil, fmt.Errorf("%w: неизвестное поле %q в сопоставлении столбцов", ErrInvalidImport, field)
+		}
+		i, ok := headers[strings.TrimSpace(header)]
+		if !ok {
+			return nil, fmt.Errorf("%w: нет столбца %q для поля %s", ErrInvalidImport, header, field)
+		}
+		columns[field] = i
+	}
+	if _, ok := columns["sku"]; !ok {
+		return nil, fmt.Errorf("%w: не найден столбец артикула (sku)", ErrInvalidImport)
+	}
+
+	var rows []importRow
+	for n, cells := range table[1:] {
+		row := importRow{Line: n + 2, Values: make(map[string]string, len(columns))}
+		empty := true
+		for field, i := range columns {
+			if i < len(cells) {
+				if v := strings.TrimSpace(cells[i]); v != "" {
+					row.Values[field] = v
+					empty = false
+				}
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// isImportField - поле, в которое можно загрузить столбец.
+func isImportField(field string) bool {
+	if code, ok := strings.CutPrefix(field, importAttributePrefix); ok {
+		return attributeCodePattern.MatchString(code)
+	}
+	for _, f := range importFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// importValues - разобранные значения строки; nil - значение не указано.
+type importValues struct {
+	Name             *string
+	Description      *string
+	Price            *float64
+	Category         *int64
+	ManufacturerID   *int
+	Color            *string
+	CoilResistance   *float64
+	NicotineStrength *float64
+	VariantPrice     *float64
+	Stock            *int
+	// Код характеристики -> значение из файла
+	Attributes map[string]string
+}
+
+// hasProductFields - указаны поля товара, а не только варианта.
+func (v *importValues) hasProductFields() bool {
+	return v.Name != nil || v.Description != nil || v.Price != nil || v.Category != nil ||
+		v.ManufacturerID != nil || len(v.Attributes) > 0
+}
+
+// importProductRow - импорт строки; true, если создан новый вариант.
+func importProductRow(ctx context.Context, tx *db.Tx, row importRow) (bool, error) {
+	sku, _ := row.value("sku")
+	if len(sku) > 64 {
+		return false, &ImportRowError{Column: "sku", Message: "длина артикула больше 64 символов"}
+	}
+	values, err := parseImportValues(ctx, tx, row)
+	if err != nil {
+		return false, err
+	}
+
+	var variant ProductVariant
+	err = scanVariant(tx.QueryRowContext(ctx, `
+        SELECT `+variantColumns+`
+        FROM product_variants v JOIN products p ON p.id = v.product_id
+        WHERE v.sku = $1
+        FOR UPDATE OF v`, sku), &variant)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("ошибка получения варианта: %w", err)
+	}
+	if exists && variant.DeletedAt != nil {
+		return false, &ImportRowError{Column: "sku", Message: "артикул принадлежит удаленному варианту"}
+	}
+
+	var productID int64
+	switch parent, ok := row.value("parent_sku"); {
+	case exists:
+		productID = variant.ProductID
+	case ok:
+		err := tx.QueryRowContext(ctx,
+			"SELECT product_id FROM product_variants WHERE sku = $1 AND deleted_at IS NULL", parent).Scan(&productID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, &ImportRowError{Column: "parent_sku", Message: fmt.Sprintf("вариант с артикулом %q не найден", parent)}
+		}
+		if err != nil {
+			return false, fmt.Errorf("ошибка получения варианта: %w", err)
+		}
+	default:
+		return true, importNewProduct(ctx, tx, sku, values)
+	}
+
+	var before Product
+	err = scanProduct(tx.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products p WHERE p.id = $1 AND p.deleted_at IS NULL FOR UPDATE", productID), &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, &ImportRowError{Column: "sku", Message: "товар варианта удален"}
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка получения продукта: %w", err)
+	}
+
+	if values.hasProductFields() {
+		if err := importUpdateProduct(ctx, tx, before, values); err != nil {
+			return false, err
+		}
+	}
+
+	if exists {
+		after := variant
+		applyImportVariant(&after, values)
+		if _, err := tx.ExecContext(ctx, `
+            UPDATE product_variants
+            SET color = $2, coil_resistance = $3, nicotine_strength = $4, price = $5, stock = $6
+            WHERE id = $1`,
+			after.ID, after.Color, after.CoilResistance, after.NicotineStrength, after.PriceOverride, after.Stock); err != nil {
+			return false, fmt.Errorf("ошибка обновления варианта: %w", err)
+		}
+		updated, err := lockVariant(ctx, tx, after.ProductID, after.ID)
+		if err != nil {
+			return false, err
+		}
+		if err := recordAudit(ctx, tx, AuditEntityVariant, variant.ID, AuditActionUpdate, variant, updated); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	variant = ProductVariant{ProductID: productID, SKU: sku}
+	applyImportVariant(&variant, values)
+	var price float64
+	if err := tx.QueryRowContext(ctx, "SELECT price FROM products WHERE id = $1", productID).Scan(&price); err != nil {
+		return false, fmt.Errorf("ошибка получения продукта: %w", err)
+	}
+	if err := insertVariant(ctx, tx, &variant, price); err != nil {
+		return false, err
+	}
+	if err := recordAudit(ctx, tx, AuditEntityVariant, variant.ID, AuditActionCreate, nil, variant); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// importNewProduct - создание товара с единственным вариантом sku.
+func importNewProduct(ctx context.Context, tx *db.Tx, sku string, values *importValues) error {
+	switch {
+	case values.Name == nil:
+		return &ImportRowError{Column: "name", Message: "обязательно для нового товара"}
+	case values.Price == nil:
+		return &ImportRowError{Column: "price", Message: "обязательно для нового товара"}
+	case values.Category == nil:
+		return &ImportRowError{Column: "category", Message: "обязательно для нового товара"}
+	case values.ManufacturerID == nil:
+		return &ImportRowError{Column: "manufacturer_id", Message: "обязательно для нового товара"}
+	}
+
+	product := Product{
+		Name:           *values.Name,
+		Price:          *values.Price,
+		CategoryID:     int(*values.Category),
+		ManufacturerID: *values.ManufacturerID,
+	}
+	if values.Description != nil {
+		product.Description = *values.Description
+	}
+	attributes, err := importAttributes(ctx, tx, *values.Category, nil, values.Attributes)
+	if err != nil {
+		return err
+	}
+	product.Attributes = attributes
+
+	variant := ProductVariant{SKU: sku}
+	applyImportVariant(&variant, values)
+	product.Variants = []ProductVariant{variant}
+	if err := insertProduct(ctx, tx, &product); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, AuditEntityProduct, product.ID, AuditActionCreate, nil, product)
+}
+
+// importUpdateProduct - изменение указанных в строке полей товара.
+func importUpdateProduct(ctx context.Context, tx *db.Tx, before Product, values *importValues) error {
+	after := before
+	if values.Name != nil {
+		after.Name = *values.Name
+	}
+	if values.Description != nil {
+		after.Description = *values.Description
+	}
+	if values.Price != nil {
+		after.Price = *values.Price
+	}
+	if values.Category != nil {
+		after.CategoryID = int(*values.Category)
+	}
+	if values.ManufacturerID != nil {
+		after.ManufacturerID = *values.ManufacturerID
+	}
+	attributes, err := importAttributes(ctx, tx, int64(after.CategoryID), before.Attributes, values.Attributes)
+	if err != nil {
+		return err
+	}
+	after.Attributes = attributes
+	// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+	attributesJSON, err := json.Marshal(after.Attributes)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки характеристик: %w", err)
+	}
+
+	schema, err := loadAttributeSchema(ctx, tx, int64(after.CategoryID))
+	if err != nil {
+		return err
+	}
+	after.applyLegacyAttributes(schema, &before)
+
+	err = scanProduct(tx.QueryRowContext(ctx, `
+        UPDATE products AS p
+        SET name = $2, description = $3, price = $4, category_id = $5, manufacturer_id = $6, attributes = $7,
+            vape_type = $8, power = $9, battery_capacity = $10, tank_capacity = $11,
+            coil_resistance = $12, material = $13, color = $14
+        WHERE p.id = $1
+        RETURNING `+productColumns,
+		after.ID, after.Name, after.Description, after.Price, after.CategoryID, after.ManufacturerID,
+		string(attributesJSON), after.VapeType, after.Power, after.BatteryCapacity, after.TankCapacity,
+		after.CoilResistance, after.Material, after.Color), &after)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления продукта: %w", err)
+	}
+	return recordAudit(ctx, tx, AuditEntityProduct, after.ID, AuditActionUpdate, before, after)
+}
+
+// importAttributes - характеристики товара категории categoryID: текущие значения current,
+// дополненные значениями из файла, с проверкой по схеме категории.
+func importAttributes(ctx context.Context, tx *db.Tx, categoryID int64, current map[string]any, cells map[string]string) (map[string]any, error) {
+	schema, err := loadAttributeSchema(ctx, tx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]AttributeDefinition, len(schema))
+	for _, d := range schema {
+		byCode[d.Code] = d
+	}
+
+	attributes := maps.Clone(current)
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	codes := make([]string, 0, len(cells))
+	for code := range cells {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		d, ok := byCode[code]
+		if !ok {
+			return nil, &AttributeError{Code: code, Reason: "не задана для категории товара"}
+		}
+		v, err := parseAttributeCell(d, cells[code])
+		if err != nil {
+			return nil, err
+		}
+		attributes[code] = v
+	}
+	if err := validateAttributes(schema, attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// parseAttributeCell - значение характеристики из ячейки по типу характеристики.
+func parseAttributeCell(d AttributeDefinition, cell string) (any, error) {
+	switch d.Type {
+	case AttributeTypeNumber, AttributeTypeInteger:
+		f, err := parseImportNumber(cell)
+		if err != nil {
+			return nil, &AttributeError{Code: d.Code, Reason: "ожидается число"}
+		}
+		return f, nil
+	case AttributeTypeBoolean:
+		switch strings.ToLower(cell) {
+		case "1", "true", "да", "yes":
+			return true, nil
+		case "0", "false", "нет", "no":
+			return false, nil
+		}
+		return nil, &AttributeError{Code: d.Code, Reason: "ожидается да или нет"}
+	default:
+		return cell, nil
+	}
+}
+
+// parseImportValues - разбор значений строки и проверка ссылок на категорию и производителя.
+func parseImportValues(ctx context.Context, tx *db.Tx, row importRow) (*importValues, error) {
+	values := &importValues{Attributes: map[string]string{}}
+	for field, v := range row.Values {
+		if code, ok := strings.CutPrefix(field, importAttributePrefix); ok {
+			values.Attributes[code] = v
+		}
+	}
+
+	if v, ok := row.value("name"); ok {
+		if len([]rune(v)) > 255 {
+			return nil, &ImportRowError{Column: "name", Message: "длина больше 255 символов"}
+		}
+		values.Name = &v
+	}
+	if v, ok := row.value("description"); ok {
+		values.Description = &v
+	}
+	if v, ok := row.value("color"); ok {
+		if len([]rune(v)) > 255 {
+			return nil, &ImportRowError{Column: "color", Message: "длина больше 255 символов"}
+		}
+		values.Color = &v
+	}
+
+	for _, f := range []struct {
+		field string
+		dest  **float64
+		check func(float64) bool
+		rule  string
+	}{
+		{"price", &values.Price, func(x float64) bool { return x >= 0 }, "ожидается неотрицательное число"},
+		{"variant_price", &values.VariantPrice, func(x float64) bool { return x >= 0 }, "ожидается неотрицательное число"},
+		{"coil_resistance", &values.CoilResistance, func(x float64) bool { return x > 0 }, "ожидается положительное число"},
+		{"nicotine_strength", &values.NicotineStrength, func(x float64) bool { return x >= 0 }, "ожидается неотрицательное число"},
+	} {
+		v, ok := row.value(f.field)
+		if !ok {
+			continue
+		}
+		x, err := parseImportNumber(v)
+		if err != nil || !f.check(x) {
+			return nil, &ImportRowError{Column: f.field, Message: f.rule}
+		}
+		*f.dest = &x
+	}
+
+	if v, ok := row.value("stock"); ok {
+		stock, err := strconv.Atoi(v)
+		if err != nil || stock < 0 {
+			return nil, &ImportRowError{Column: "stock", Message: "ожидается неотрицательное целое число"}
+		}
+		values.Stock = &stock
+	}
+
+	if v, ok := row.value("category"); ok {
+		id, err := resolveImportCategory(ctx, tx, v)
+		if err != nil {
+			return nil, err
+		}
+		values.Category = &id
+	}
+
+	if v, ok := row.value("manufacturer_id"); ok {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, &ImportRowError{Column: "manufacturer_id", Message: "ожидается ID производителя"}
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM manufacturers WHERE id = $1)", id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("ошибка проверки производителя: %w", err)
+		}
+		if !exists {
+			return nil, &ImportRowError{Column: "manufacturer_id", Message: fmt.Sprintf("производитель %d не найден", id)}
+		}
+		values.ManufacturerID = &id
+	}
+	return values, nil
+}
+
+// resolveImportCategory - неудаленная категория по ID или slug.
+func resolveImportCategory(ctx context.Context, tx *db.Tx, ref string) (int64, error) {
+	var id int64
+	query := "SELECT id FROM categories WHERE slug = $1 AND deleted_at IS NULL"
+	var arg any = ref
+	if n, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		query = "SELECT id FROM categories WHERE id = $1 AND deleted_at IS NULL"
+		arg = n
+	}
+	err := tx.QueryRowContext(ctx, query, arg).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, &ImportRowError{Column: "category", Message: fmt.Sprintf("категория %q не найдена", ref)}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка проверки категории: %w", err)
+	}
+	return id, nil
+}
+
+// parseImportNumber - число из ячейки; допускается десятичная запятая.
+func parseImportNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("недопустимое число %q", s)
+	}
+	return f, nil
+}
+
+// applyImportVariant - перенос указанных в строке полей варианта.
+func applyImportVariant(v *ProductVariant, values *importValues) {
+	if values.Color != nil {
+		v.Color = values.Color
+	}
+	if values.CoilResistance != nil {
+		v.CoilResistance = values.CoilResistance
+	}
+	if values.NicotineStrength != nil {
+		v.NicotineStrength = values.NicotineStrength
+	}
+	if values.VariantPrice != nil {
+		v.PriceOverride = values.VariantPrice
+	}
+	if values.Stock != nil {
+		v.Stock = *values.Stock
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDryRunReplay(t *testing.T) {
+	row := func(line int, sku, parent string) importRow {
+		values := map[string]string{"sku": sku}
+		if parent != "" {
+			values["parent_sku"] = parent
+		}
+		return importRow{Line: line, Values: values}
+	}
+	// Строки прошлых пакетов, создавшие варианты
+	created := map[string]importRow{
+		"A": row(2, "A", ""),
+		"B": row(5, "B", "A"),
+		"C": row(7, "C", ""),
+	}
+
+	tests := []struct {
+		name  string
+		batch []importRow
+		want  []int
+	}{
+		{"без ссылок на прошлые пакеты", []importRow{row(102, "X", ""), row(103, "Y", "X")}, nil},
+		{"parent_sku из прошлого пакета", []importRow{row(102, "X", "C")}, []int{7}},
+		{"повторный артикул", []importRow{row(102, "A", "")}, []int{2}},
+		{"цепочка родителей в порядке файла", []importRow{row(102, "X", "B")}, []int{2, 5}},
+		{"каждая строка выполняется один раз", []importRow{row(102, "B", ""), row(103, "X", "A"), row(104, "C", "B")}, []int{2, 5, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []int
+			for _, r := range dryRunReplay(created, tt.batch) {
+				lines = append(lines, r.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("строки = %v, ожидалось %v", lines, tt.want)
+			}
+		})
+	}
+}
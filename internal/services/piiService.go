@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+// reencryptBatchSize - количество строк, перешифровываемых в одной транзакции.
+const reencryptBatchSize = 100
+
+// reencryptBatch - перешифрование очередной порции строк одной таблицы;
+// возвращает количество измененных строк.
+type reencryptBatch func(ctx context.Context, tx *db.Tx) (int, error)
+
+// PIIService - перешифрование персональных данных основным ключом.
+type PIIService interface {
+	Reencrypt(ctx context.Context) (int, error)
+}
+
+// PIIServiceImpl - фоновое шифрование старых значений и перенос на новый ключ после ротации.
+type PIIServiceImpl struct {
+	db      *db.DB
+	cipher  *pii.Cipher
+	batches []reencryptBatch
+}
+
+// NewPIIService - функция для создания нового сервиса перешифрования.
+func NewPIIService(db *db.DB, cipher *pii.Cipher) *PIIServiceImpl {
+	s := &PIIServiceImpl{
+		db:     db,
+		cipher: cipher,
+	}
+	s.batches = []reencryptBatch{
+		s.reencryptCustomers,
+		s.reencryptAddresses,
+		s.reencryptPurchases,
+		s.reencryptDocuments,
+		s.reencryptEmailLog("login_attempts"),
+		s.reencryptEmailLog("security_events"),
+	}
+	return s
+}
+
+// RunReencryption - периодическое перешифрование до отмены контекста.
+func (s *PIIServiceImpl) RunReencryption(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reencrypt(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Ошибка перешифрования персональных данных", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Reencrypt - шифрование всех значений, которые еще не зашифрованы основным ключом.
+// Возвращает количество измененных строк.
+func (s *PIIServiceImpl) Reencrypt(ctx context.Context) (int, error) {
+	total := 0
+	for _, batch := range s.batches {
+		for ctx.Err() == nil {
+			n, err := s.runBatch(ctx, batch)
+			if err != nil {
+				return total, err
+			}
+			total += n
+			if n < reencryptBatchSize {
+				break
+			}
+		}
+	}
+	if total > 0 {
+		slog.Info("Персональные данные перешифрованы",
+			slog.Int("rows", total),
+			slog.String("key_id", s.cipher.PrimaryKeyID()))
+	}
+	return total, nil
+}
+
+// runBatch - выполнение пакета в отдельной транзакции.
+func (s *PIIServiceImpl) runBatch(ctx context.Context, batch reencryptBatch) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	n, err := batch(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return n, nil
+}
+
+// notPrimary - условие для непустого значения, не зашифрованного основным ключом.
+// Префикс сравнивается через left(), чтобы "_" в идентификаторе ключа не работал как шаблон LIKE.
+func notPrimary(value string) string {
+	return "(" + value + " <> '' AND left(" + value + ", length($1)) <> $1)"
+}
+
+// reencryptCustomers - email, телефон и адрес клиентов, а также слепой индекс email.
+func (s *PIIServiceImpl) reencryptCustomers(ctx context.Context, tx *db.Tx) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, email, phone, address FROM customers
+        WHERE email_hash IS NULL OR `+notPrimary("email")+` OR `+notPrimary("phone")+` OR `+notPrimary("address")+`
+        ORDER BY id
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED`, s.cipher.PrimaryPrefix(), reencryptBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения клиентов: %w", err)
+	}
+	type customer struct {
+		id             int64
+		email          string
+		phone, address *string
+	}
+	var customers []customer
+	for rows.Next() {
+		var c customer
+		if err := rows.Scan(&c.id, &c.email, &c.phone, &c.address); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка чтения клиента: %w", err)
+		}
+		customers = append(customers, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	for _, c := range customers {
+		email, err := s.cipher.Decrypt(c.email)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка расшифровки email клиента %d: %w", c.id, err)
+		}
+		if err := rewrapFields(s.cipher, &c.email, c.phone, c.address); err != nil {
+			return 0, fmt.Errorf("ошибка перешифрования клиента %d: %w", c.id, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE customers SET email = $2, email_hash = $3, phone = $4, address = $5 WHERE id = $1",
+			c.id, c.email, s.cipher.BlindIndex(email), c.phone, c.address); err != nil {
+			return 0, fmt.Errorf("ошибка сохранения клиента %d: %w", c.id, err)
+		}
+	}
+	return len(customers), nil
+}
+
+// reencryptAddresses - город, улица и индекс в адресной книге.
+func (s *PIIServiceImpl) reencryptAddresses(ctx context.Context, tx *db.Tx) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, city, street, postcode FROM customer_addresses
+        WHERE `+notPrimary("city")+` OR `+notPrimary("street")+` OR `+notPrimary("postcode")+`
+        ORDER BY id
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED`, s.cipher.PrimaryPrefix(), reencryptBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения адресов: %w", err)
+	}
+	type address struct {
+		id                     int64
+		city, street, postcode string
+	}
+	var addresses []address
+	for rows.Next() {
+		var a address
+		if err := rows.Scan(&a.id, &a.city, &a.street, &a.postcode); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка чтения адреса: %w", err)
+		}
+		addresses = append(addresses, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	for _, a := range addresses {
+		if err := rewrapFields(s.cipher, &a.city, &a.street, &a.postcode); err != nil {
+			return 0, fmt.Errorf("ошибка перешифрования адреса %d: %w", a.id, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE customer_addresses SET city = $2, street = $3, postcode = $4 WHERE id = $1",
+			a.id, a.city, a.street, a.postcode); err != nil {
+			return 0, fmt.Errorf("ошибка сохранения адреса %d: %w", a.id, err)
+		}
+	}
+	return len(addresses), nil
+}
+
+// reencryptPurchases - копии адресов доставки в покупках. Пустые поля
+// обезличенных покупок не шифруются.
+func (s *PIIServiceImpl) reencryptPurchases(ctx context.Context, tx *db.Tx) (int, error) {
+	var conditions string
+	for i, field := range []string{"city", "street", "postcode"} {
+		if i > 0 {
+			conditions += " OR "
+		}
+		conditions += notPrimary("(delivery_address->>'" + field + "')")
+	}
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, delivery_address FROM purchases
+        WHERE delivery_address IS NOT NULL AND (`+conditions+`)
+        ORDER BY id
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED`, s.cipher.PrimaryPrefix(), reencryptBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения покупок: %w", err)
+	}
+	type purchase struct {
+		id       int64
+		snapshot AddressSnapshot
+	}
+	var purchases []purchase
+	for rows.Next() {
+		var p purchase
+		var data []byte
+		if err := rows.Scan(&p.id, &data); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка чтения покупки: %w", err)
+		}
+		if err := json.Unmarshal(data, &p.snapshot); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка чтения адреса доставки покупки %d: %w", p.id, err)
+		}
+		purchases = append(purchases, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	for _, p := range purchases {
+		if err := rewrapFields(s.cipher, &p.snapshot.City, &p.snapshot.Street, &p.snapshot.Postcode); err != nil {
+			return 0, fmt.Errorf("ошибка перешифрования покупки %d: %w", p.id, err)
+		}
+		data, err := json.Marshal(p.snapshot)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка сохранения адреса доставки: %w", err)
+		}
+		// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE purchases SET delivery_address = $2 WHERE id = $1", p.id, string(data)); err != nil {
+			return 0, fmt.Errorf("ошибка сохранения покупки %d: %w", p.id, err)
+		}
+	}
+	return len(purchases), nil
+}
+
+// reencryptDocuments - файлы документов для проверки возраста. Файлы крупные,
+// поэтому сначала выбираются только ID, а содержимое читается по одному.
+func (s *PIIServiceImpl) reencryptDocuments(ctx context.Context, tx *db.Tx) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id FROM id_documents
+        WHERE length(content) > 0
+          AND substring(content FOR octet_length(convert_to($1, 'UTF8'))) <> convert_to($1, 'UTF8')
+        ORDER BY id
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED`, s.cipher.PrimaryPrefix(), reencryptBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения документов: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка чтения документа: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	for _, id := range ids {
+		var content []byte
+		if err := tx.QueryRowContext(ctx, "SELECT content FROM id_documents WHERE id = $1", id).Scan(&content); err != nil {
+			return 0, fmt.Errorf("ошибка чтения документа %d: %w", id, err)
+		}
+		stored, _, err := s.cipher.Rewrap(string(content))
+		if err != nil {
+			return 0, fmt.Errorf("ошибка перешифрования документа %d: %w", id, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE id_documents SET content = $2 WHERE id = $1", id, []byte(stored)); err != nil {
+			return 0, fmt.Errorf("ошибка сохранения документа %d: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// reencryptEmailLog - email в журнале входа table и его слепой индекс.
+// Пустые email событий без клиента не шифруются.
+func (s *PIIServiceImpl) reencryptEmailLog(table string) reencryptBatch {
+	return func(ctx context.Context, tx *db.Tx) (int, error) {
+		rows, err := tx.QueryContext(ctx, `
+            SELECT id, email FROM `+table+`
+            WHERE email <> '' AND (email_hash IS NULL OR `+notPrimary("email")+`)
+            ORDER BY id
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED`, s.cipher.PrimaryPrefix(), reencryptBatchSize)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка получения записей %s: %w", table, err)
+		}
+		type entry struct {
+			id    int64
+			email string
+		}
+		var entries []entry
+		for rows.Next() {
+			var e entry
+			if err := rows.Scan(&e.id, &e.email); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("ошибка чтения записи %s: %w", table, err)
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+		}
+
+		for _, e := range entries {
+			email, err := s.cipher.Decrypt(e.email)
+			if err != nil {
+				return 0, fmt.Errorf("ошибка расшифровки email записи %s %d: %w", table, e.id, err)
+			}
+			if err := rewrapFields(s.cipher, &e.email); err != nil {
+				return 0, fmt.Errorf("ошибка перешифрования записи %s %d: %w", table, e.id, err)
+			}
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE "+table+" SET email = $2, email_hash = $3 WHERE id = $1",
+				e.id, e.email, s.cipher.BlindIndex(email)); err != nil {
+				return 0, fmt.Errorf("ошибка сохранения записи %s %d: %w", table, e.id, err)
+			}
+		}
+		return len(entries), nil
+	}
+}
+
+// rewrapFields - приведение значений к основному ключу; пустые строки и nil пропускаются.
+func rewrapFields(c *pii.Cipher, fields ...*string) error {
+	for _, f := range fields {
+		if f == nil || *f == "" {
+			continue
+		}
+		v, _, err := c.Rewrap(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
@@ -0,0 +1,589 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"VapeShop-ClientAPI/internal/auth"
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+var ErrErasureRequestNotFound = errors.New("запрос на удаление данных не найден")
+
+// ErasureRequest - запрос клиента на удаление персональных данных.
+type ErasureRequest struct {
+	ID          int64     `json:"id"`
+	CustomerID  int64     `json:"customer_id"`
+	RequestedAt time.Time `json:"requested_at"`
+	// До этого момента запрос можно отменить
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+// exportSection - файл выгрузки персональных данных.
+type exportSection struct {
+	file string
+	load func(ctx context.Context, tx *db.Tx, customerID int64) (any, error)
+}
+
+type PrivacyService interface {
+	ExportData(ctx context.Context, customerID int64, w io.Writer) error
+	RequestErasure(ctx context.Context, customerID int64) (*ErasureRequest, error)
+	CancelErasure(ctx context.Context, customerID int64) error
+	ProcessErasures(ctx context.Context) (int, error)
+	RunErasures(ctx context.Context, interval time.Duration)
+}
+
+// PrivacyServiceImpl - выгрузка и удаление персональных данных клиента.
+type PrivacyServiceImpl struct {
+	db          *db.DB
+	cipher      *pii.Cipher
+	gracePeriod time.Duration
+	sections    []exportSection
+}
+
+// NewPrivacyService - функция для создания нового сервиса персональных данных.
+func NewPrivacyService(db *db.DB, cipher *pii.Cipher, gracePeriod time.Duration) *PrivacyServiceImpl {
+	s := &PrivacyServiceImpl{
+		db:          db,
+		cipher:      cipher,
+		gracePeriod: gracePeriod,
+	}
+	s.sections = []exportSection{
+		{file: "profile.json", load: s.exportProfile},
+		{file: "addresses.json", load: s.exportAddresses},
+		{file: "purchases.json", load: s.exportPurchases},
+		{file: "reviews.json", load: exportReviews},
+		{file: "id_documents.json", load: exportIDDocuments},
+		{file: "security_events.json", load: s.exportSecurityEvents},
+		{file: "login_attempts.json", load: s.exportLoginAttempts},
+		{file: "audit_log.json", load: exportAuditLog},
+	}
+	return s
+}
+
+// ExportData - ZIP-архив с JSON-файлами всех данных клиента и загруженными документами.
+// Данные читаются в одной транзакции, чтобы файлы были согласованы между собой.
+func (s *PrivacyServiceImpl) ExportData(ctx context.Context, customerID int64, w io.Writer) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM customers WHERE id = $1 AND deleted_at IS NULL)", customerID).Scan(&exists); err != nil {
+		return fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+	if !exists {
+		return ErrCustomerNotFound
+	}
+
+	files := make(map[string]any, len(s.sections))
+	for _, section := range s.sections {
+		data, err := section.load(ctx, tx, customerID)
+		if err != nil {
+			return fmt.Errorf("ошибка выгрузки %s: %w", section.file, err)
+		}
+		files[section.file] = data
+	}
+
+	documents, err := exportIDDocumentFiles(ctx, tx, s.cipher, customerID)
+	if err != nil {
+		return fmt.Errorf("ошибка выгрузки документов: %w", err)
+	}
+
+	now := time.Now()
+	zw := zip.NewWriter(w)
+	for _, section := range s.sections {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: section.file, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(files[section.file]); err != nil {
+			return err
+		}
+	}
+	for name, content := range documents {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Выгружены персональные данные", slog.Int64("customer_id", customerID))
+	return nil
+}
+
+// RequestErasure - запрос на удаление данных. Данные обезличиваются после окончания
+// срока на отмену; повторный запрос возвращает уже созданный.
+func (s *PrivacyServiceImpl) RequestErasure(ctx context.Context, customerID int64) (*ErasureRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT deleted_at FROM customers WHERE id = $1 FOR UPDATE", customerID).Scan(&deletedAt)
+	if errors.Is(err, sql.ErrNoRows) || deletedAt.Valid {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+
+	req := ErasureRequest{CustomerID: customerID}
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, requested_at, scheduled_at FROM erasure_requests
+        WHERE customer_id = $1 AND cancelled_at IS NULL AND completed_at IS NULL`,
+		customerID).Scan(&req.ID, &req.RequestedAt, &req.ScheduledAt)
+	if err == nil {
+		return &req, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ошибка получения запроса на удаление: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO erasure_requests (customer_id, scheduled_at)
+        VALUES ($1, $2)
+        RETURNING id, requested_at, scheduled_at`,
+		customerID, time.Now().Add(s.gracePeriod)).Scan(&req.ID, &req.RequestedAt, &req.ScheduledAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения запроса на удаление: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Запрошено удаление персональных данных",
+		slog.Int64("customer_id", customerID), slog.Time("scheduled_at", req.ScheduledAt))
+	return &req, nil
+}
+
+// CancelErasure - отмена запроса на удаление до окончания срока.
+func (s *PrivacyServiceImpl) CancelErasure(ctx context.Context, customerID int64) error {
+	res, err := s.db.ExecContext(ctx, `
+        UPDATE erasure_requests SET cancelled_at = now()
+        WHERE customer_id = $1 AND cancelled_at IS NULL AND completed_at IS NULL`, customerID)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены запроса на удаление: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrErasureRequestNotFound
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Запрос на удаление персональных данных отменен", slog.Int64("customer_id", customerID))
+	return nil
+}
+
+// RunErasures - периодическое выполнение запросов на удаление до отмены ctx.
+func (s *PrivacyServiceImpl) RunErasures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ProcessErasures(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Ошибка удаления персональных данных", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// ProcessErasures - обезличивание клиентов, срок отмены запросов которых истек.
+// Каждый запрос выполняется в отдельной транзакции; SKIP LOCKED позволяет
+// нескольким экземплярам сервиса работать одновременно. Возвращает число выполненных запросов.
+func (s *PrivacyServiceImpl) ProcessErasures(ctx context.Context) (int, error) {
+	processed := 0
+	for ctx.Err() == nil {
+		done, err := s.processNextErasure(ctx)
+		if err != nil {
+			return processed, err
+		}
+		if !done {
+			break
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (s *PrivacyServiceImpl) processNextErasure(ctx context.Context) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var requestID, customerID int64
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, customer_id FROM erasure_requests
+        WHERE cancelled_at IS NULL AND completed_at IS NULL AND scheduled_at <= now()
+        ORDER BY scheduled_at
+        LIMIT 1
+        FOR UPDATE SKIP LOCKED`).Scan(&requestID, &customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ошибка получения запроса на удаление: %w", err)
+	}
+
+	if err := s.anonymizeCustomer(ctx, tx, customerID); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE erasure_requests SET completed_at = now() WHERE id = $1", requestID); err != nil {
+		return false, fmt.Errorf("ошибка завершения запроса на удаление: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	slog.Info("Персональные данные клиента удалены", slog.Int64("customer_id", customerID))
+	return true, nil
+}
+
+// anonymizeCustomer - обезличивание клиента. Покупки сохраняются для бухгалтерского учета,
+// из копий адресов доставки остается только регион; отзывы удаляются.
+func (s *PrivacyServiceImpl) anonymizeCustomer(ctx context.Context, tx *db.Tx, customerID int64) error {
+	// Случайный пароль, который никто не знает: войти в учетную запись больше нельзя
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("ошибка генерации пароля: %w", err)
+	}
+	password, err := auth.HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return fmt.Errorf("ошибка хэширования пароля: %w", err)
+	}
+
+	var email string
+	if err := tx.QueryRowContext(ctx, "SELECT email FROM customers WHERE id = $1", customerID).Scan(&email); err != nil {
+		return fmt.Errorf("ошибка получения клиента: %w", err)
+	}
+	if email, err = s.cipher.Decrypt(email); err != nil {
+		return err
+	}
+
+	// Email обязателен и уникален, поэтому заменяется на несуществующий адрес
+	placeholder := fmt.Sprintf("deleted-%d@deleted.invalid", customerID)
+	encryptedPlaceholder, err := s.cipher.Encrypt(placeholder)
+	if err != nil {
+		return err
+	}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE customers
+          SET first_name = 'Удален', last_name = '', email = $3, email_hash = $4,
+              password = $2, phone = NULL, address = NULL, date_of_birth = NULL,
+              age_verification_status = 'unverified', age_verified_at = NULL, email_verified_at = NULL,
+              failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL,
+              deleted_at = now()
+          WHERE id = $1`, []any{customerID, password, encryptedPlaceholder, s.cipher.BlindIndex(placeholder)}},
+		{"DELETE FROM customer_addresses WHERE customer_id = $1", []any{customerID}},
+		{`UPDATE purchases SET delivery_address = jsonb_build_object('region', delivery_address->>'region')
+          WHERE customer_id = $1 AND delivery_address IS NOT NULL`, []any{customerID}},
+		// Отзывы удаляются вместе с их вкладом в рейтинг товаров
+		{`WITH r AS (
+              DELETE FROM reviews WHERE customer_id = $1 RETURNING product_id, rating, status
+          )
+          UPDATE products p SET rating_count = p.rating_count - a.n, rating_sum = p.rating_sum - a.total
+          FROM (SELECT product_id, count(*) AS n, sum(rating) AS total FROM r WHERE status = $2 GROUP BY product_id) a
+          WHERE p.id = a.product_id`, []any{customerID, ReviewStatusApproved}},
+		{"DELETE FROM id_documents WHERE customer_id = $1", []any{customerID}},
+		{"DELETE FROM auth_tokens WHERE customer_id = $1", []any{customerID}},
+		// Записи, которые фоновая задача еще не зашифровала, ищутся по открытому email
+		{`DELETE FROM login_attempts
+          WHERE customer_id = $1 OR email_hash = $2 OR (email_hash IS NULL AND lower(email) = lower($3))`,
+			[]any{customerID, s.cipher.BlindIndex(email), email}},
+		{"UPDATE security_events SET email = NULL, email_hash = NULL, ip = NULL WHERE customer_id = $1", []any{customerID}},
+		{"UPDATE audit_log SET ip = NULL WHERE actor_id = $1", []any{customerID}},
+	}
+	for _, st := range statements {
+		if _, err := tx.ExecContext(ctx, st.query, st.args...); err != nil {
+			return fmt.Errorf("ошибка обезличивания клиента: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *PrivacyServiceImpl) exportProfile(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	var p struct {
+		ID                    int64      `json:"id"`
+		FirstName             string     `json:"first_name"`
+		LastName              string     `json:"last_name"`
+		Email                 string     `json:"email"`
+		Phone                 *string    `json:"phone"`
+		Address               *string    `json:"address"`
+		Role                  string     `json:"role"`
+		DateOfBirth           *string    `json:"date_of_birth"`
+		AgeVerificationStatus string     `json:"age_verification_status"`
+		AgeVerifiedAt         *time.Time `json:"age_verified_at"`
+		EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	}
+	var dob sql.NullTime
+	err := tx.QueryRowContext(ctx, `
+        SELECT id, first_name, last_name, email, phone, address, role, date_of_birth,
+               age_verification_status, age_verified_at, email_verified_at
+        FROM customers WHERE id = $1`, customerID).Scan(
+		&p.ID,
+		&p.FirstName,
+		&p.LastName,
+		&p.Email,
+		&p.Phone,
+		&p.Address,
+		&p.Role,
+		&dob,
+		&p.AgeVerificationStatus,
+		&p.AgeVerifiedAt,
+		&p.EmailVerifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if dob.Valid {
+		d := dob.Time.Format(DateLayout)
+		p.DateOfBirth = &d
+	}
+	if err := decryptFields(s.cipher, &p.Email); err != nil {
+		return nil, err
+	}
+	if p.Phone, err = s.cipher.DecryptPtr(p.Phone); err != nil {
+		return nil, err
+	}
+	if p.Address, err = s.cipher.DecryptPtr(p.Address); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *PrivacyServiceImpl) exportAddresses(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+addressColumns+" FROM customer_addresses WHERE customer_id = $1 ORDER BY id", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []Address{}
+	for rows.Next() {
+		var address Address
+		if err := scanAddress(rows, s.cipher, &address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+func (s *PrivacyServiceImpl) exportPurchases(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+purchaseColumns+" FROM purchases WHERE customer_id = $1 ORDER BY id", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purchases := []Purchase{}
+	for rows.Next() {
+		var purchase Purchase
+		if err := scanPurchase(rows, s.cipher, &purchase); err != nil {
+			return nil, err
+		}
+		purchases = append(purchases, purchase)
+	}
+	return purchases, rows.Err()
+}
+
+func exportReviews(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT `+reviewColumns+`
+        FROM reviews r
+        JOIN customers c ON c.id = r.customer_id
+        WHERE r.customer_id = $1
+        ORDER BY r.id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		var review Review
+		if err := scanReview(rows, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+func exportIDDocuments(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, customer_id, document_type, date_of_birth, file_name, content_type,
+               status, rejection_reason, reviewed_by, reviewed_at, created_at
+        FROM id_documents WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []IDDocument{}
+	for rows.Next() {
+		var doc IDDocument
+		var dob time.Time
+		if err := rows.Scan(
+			&doc.ID,
+			&doc.CustomerID,
+			&doc.DocumentType,
+			&dob,
+			&doc.FileName,
+			&doc.ContentType,
+			&doc.Status,
+			&doc.RejectionReason,
+			&doc.ReviewedBy,
+			&doc.ReviewedAt,
+			&doc.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		doc.DateOfBirth = dob.Format(DateLayout)
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
+
+// exportIDDocumentFiles - файлы загруженных документов: имя в архиве -> содержимое.
+func exportIDDocumentFiles(ctx context.Context, tx *db.Tx, c *pii.Cipher, customerID int64) (map[string][]byte, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, file_name, content FROM id_documents WHERE customer_id = $1", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make(map[string][]byte)
+	for rows.Next() {
+		var id int64
+		var name string
+		var content []byte
+		if err := rows.Scan(&id, &name, &content); err != nil {
+			return nil, err
+		}
+		if content, err = c.DecryptBytes(content); err != nil {
+			return nil, fmt.Errorf("ошибка расшифровки документа %d: %w", id, err)
+		}
+		files[fmt.Sprintf("id_documents/%d_%s", id, name)] = content
+	}
+	return files, rows.Err()
+}
+
+func (s *PrivacyServiceImpl) exportSecurityEvents(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, event_type, customer_id, actor_id, email, ip, COALESCE(details, '{}'::jsonb), created_at
+        FROM security_events WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []SecurityEvent{}
+	for rows.Next() {
+		var e SecurityEvent
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.EventType, &e.CustomerID, &e.ActorID, &e.Email, &e.IP, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Email, err = s.cipher.DecryptPtr(e.Email); err != nil {
+			return nil, err
+		}
+		e.Details = details
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *PrivacyServiceImpl) exportLoginAttempts(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	type loginAttempt struct {
+		Email     string    `json:"email"`
+		IP        string    `json:"ip"`
+		Success   bool      `json:"success"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT email, ip, success, created_at
+        FROM login_attempts WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []loginAttempt{}
+	for rows.Next() {
+		var a loginAttempt
+		if err := rows.Scan(&a.Email, &a.IP, &a.Success, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if a.Email, err = s.cipher.Decrypt(a.Email); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// exportAuditLog - изменения, выполненные самим клиентом.
+func exportAuditLog(ctx context.Context, tx *db.Tx, customerID int64) (any, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+auditColumns+" FROM audit_log WHERE actor_id = $1 ORDER BY id", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := scanAuditEntry(rows, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
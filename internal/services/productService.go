@@ -3,19 +3,34 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+
+	"github.com/lib/pq"
 )
 
+// ErrProductNotFound - продукт не найден или удален.
+var ErrProductNotFound = errors.New("продукт не найден")
+
 // Product - структура, представляющая продукт.
 type Product struct {
-	ID              int64   `json:"id"`
-	Name            string  `json:"name"`
-	Description     string  `json:"description"`
-	Price           float64 `json:"price"`
-	ImageUrl        string  `json:"image_url"`
-	CategoryID      int     `json:"category_id"` // Убедитесь, что это поле правильно указано
-	ManufacturerID  int     `json:"manufacturer_id"`
+	ID             int64   `json:"id"`
+	Name           string  `json:"name"`
+	Description    string  `json:"description"`
+	Price          float64 `json:"price"`
+	ImageUrl       string  `json:"image_url"`
+	CategoryID     int     `json:"category_id"` // Убедитесь, что это поле правильно указано
+	ManufacturerID int     `json:"manufacturer_id"`
+	// Сумма остатков вариантов; при создании без вариантов - остаток единственного варианта
 	Stock           int     `json:"stock"`
 	VapeType        string  `json:"vape_type"`
 	Power           int     `json:"power"`
@@ -26,31 +41,170 @@ type Product struct {
 	Color           string  `json:"color"`
 	IsNew           bool    `json:"is_new"`
 	IsFeatured      bool    `json:"is_featured"`
+
+	// Характеристики жидкостей
+	NicotineStrength *float64 `json:"nicotine_strength" validate:"omitempty,gte=0"` // мг/мл
+	Volume           *int     `json:"volume" validate:"omitempty,gt=0"`             // мл
+	Flavor           *string  `json:"flavor" validate:"omitempty,max=255"`
+	NicotineSalt     bool     `json:"nicotine_salt"`
+
+	// Характеристики по схеме категории: код -> значение
+	Attributes map[string]any `json:"attributes"`
+	// Диапазон цен вариантов; nil, если вариантов нет
+	PriceMin *float64 `json:"price_min"`
+	PriceMax *float64 `json:"price_max"`
+	// Средняя оценка одобренных отзывов; nil, если отзывов нет
+	Rating      *float64 `json:"rating"`
+	RatingCount int      `json:"rating_count"`
+	// Варианты товара; в ответе заполняются только при получении товара по ID
+	Variants []ProductVariant `json:"variants,omitempty" validate:"dive"`
+	// Изображения товара по порядку; заполняются только при получении товара по ID
+	Images []ProductImage `json:"images,omitempty"`
+
+	// Момент удаления; удаленные товары видны только администратору с include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Путь к категории товара от корня дерева; заполняется только при получении товара по ID
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+// ProductFilter - фильтр каталога; нулевые значения не ограничивают выборку.
+type ProductFilter struct {
+	// Скрыть товары, которые нельзя продать в регион
+	Region string
+	// Показать и удаленные товары
+	IncludeDeleted bool
+	// Товары категории вместе с подкатегориями
+	CategoryID int64
+	// Товары категорий магазина
+	StoreID int64
+	// Условия по характеристикам; выполняться должны все
+	Attributes []AttributeFilter
+	// Порядок списка: ProductSortRating или по ID
+	Sort string
+}
+
+// ProductSortRating - сначала товары с большей средней оценкой, при равной - с большим
+// количеством отзывов; товары без отзывов в конце.
+const ProductSortRating = "rating"
+
+// AttributeFilter - условие по характеристике: значение из списка и/или числовой диапазон.
+// Товары без характеристики под условие не подходят.
+type AttributeFilter struct {
+	Code   string
+	Values []string
+	Min    *float64
+	Max    *float64
+}
+
+// Facet - распределение значений характеристики среди товаров выборки.
+type Facet struct {
+	Code string `json:"code"`
+	// Название и единица измерения из схемы; без категории в фильтре название совпадает с кодом
+	Name   string       `json:"name"`
+	Type   string       `json:"type"`
+	Unit   *string      `json:"unit"`
+	Values []FacetValue `json:"values"`
+	// Диапазон числовых значений
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// FacetValue - значение характеристики и количество товаров с ним.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// productVariantsScope - неудаленные варианты товара p для агрегатов в productColumns.
+const productVariantsScope = "FROM product_variants v WHERE v.product_id = p.id AND v.deleted_at IS NULL"
+
+const productColumns = `p.id, p.name, p.description, p.price, p.image_url, p.category_id, p.manufacturer_id,
+    (SELECT COALESCE(sum(v.stock), 0) ` + productVariantsScope + `), p.vape_type, p.power, p.battery_capacity, p.tank_capacity, p.coil_resistance, p.material,
+    p.color, p.is_new, p.is_featured, p.nicotine_strength, p.volume, p.flavor, p.nicotine_salt, p.deleted_at, p.attributes,
+    (SELECT min(COALESCE(v.price, p.price)) ` + productVariantsScope + `),
+    (SELECT max(COALESCE(v.price, p.price)) ` + productVariantsScope + `),
+    ` + productRating + `, p.rating_count`
+
+// productRating - средняя оценка товара по сумме и количеству одобренных отзывов.
+const productRating = "round(p.rating_sum::numeric / NULLIF(p.rating_count, 0), 2)"
+
+// scanProduct - чтение строки, выбранной с productColumns.
+func scanProduct(row interface{ Scan(dest ...any) error }, product *Product) error {
+	var attributes []byte
+	if err := row.Scan(
+		&product.ID,
+		&product.Name,
+		&product.Description,
+		&product.Price,
+		&product.ImageUrl,
+		&product.CategoryID,
+		&product.ManufacturerID,
+		&product.Stock,
+		&product.VapeType,
+		&product.Power,
+		&product.BatteryCapacity,
+		&product.TankCapacity,
+		&product.CoilResistance,
+		&product.Material,
+		&product.Color,
+		&product.IsNew,
+		&product.IsFeatured,
+		&product.NicotineStrength,
+		&product.Volume,
+		&product.Flavor,
+		&product.NicotineSalt,
+		&product.DeletedAt,
+		&attributes,
+		&product.PriceMin,
+		&product.PriceMax,
+		&product.Rating,
+		&product.RatingCount,
+	); err != nil {
+		return err
+	}
+	return json.Unmarshal(attributes, &product.Attributes)
 }
 
 type ProductService interface {
-	GetAllProducts(ctx context.Context) ([]Product, error)
+	GetAllProducts(ctx context.Context, filter ProductFilter) ([]Product, error)
+	GetProductFacets(ctx context.Context, filter ProductFilter) ([]Facet, error)
 	GetProductByID(ctx context.Context, id string) (*Product, error)
 	CreateProduct(ctx context.Context, product Product) (*Product, error)
 	UpdateProduct(ctx context.Context, product Product) (*Product, error)
 	DeleteProduct(ctx context.Context, id string) error
+	RestoreProduct(ctx context.Context, id string) (*Product, error)
 }
 
 // ProductServiceImpl - реализация сервиса для работы с продуктами.
 type ProductServiceImpl struct {
-	db *sql.DB // Ссылка на объект базы данных
+	db *db.DB // Ссылка на объект базы данных
 }
 
 // NewProductService - функция для создания нового сервиса продуктов.
-func NewProductService(db *sql.DB) *ProductServiceImpl {
+func NewProductService(db *db.DB) *ProductServiceImpl {
 	return &ProductServiceImpl{
 		db: db,
 	}
 }
 
-// GetAllProducts - получение списка всех продуктов.
-func (s *ProductServiceImpl) GetAllProducts(ctx context.Context) ([]Product, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT * FROM products")
+// GetAllProducts - получение списка продуктов. Если указан регион, товары,
+// запрещенные к продаже в нем, не возвращаются. Удаленные товары возвращаются
+// только с IncludeDeleted. Фильтр по категории включает ее подкатегории.
+// По умолчанию товары упорядочены по ID, с Sort - по рейтингу.
+func (s *ProductServiceImpl) GetAllProducts(ctx context.Context, filter ProductFilter) ([]Product, error) {
+	where, args, err := productWhere(filter)
+	if err != nil {
+		return nil, err
+	}
+	order := "p.id"
+	if filter.Sort == ProductSortRating {
+		order = productRating + " DESC NULLS LAST, p.rating_count DESC, p.id"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+productColumns+`
+        FROM products p
+        WHERE `+where+`
+        ORDER BY `+order, args...)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
 	}
@@ -59,26 +213,7 @@ func (s *ProductServiceImpl) GetAllProducts(ctx context.Context) ([]Product, err
 	var products []Product
 	for rows.Next() {
 		var product Product
-		err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.Price,
-			&product.ImageUrl,
-			&product.CategoryID,
-			&product.ManufacturerID,
-			&product.Stock,
-			&product.VapeType,
-			&product.Power,
-			&product.BatteryCapacity,
-			&product.TankCapacity,
-			&product.CoilResistance,
-			&product.Material,
-			&product.Color,
-			&product.IsNew,
-			&product.IsFeatured,
-		)
-		if err != nil {
+		if err := scanProduct(rows, &product); err != nil {
 			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
 		}
 		products = append(products, product)
@@ -91,109 +226,482 @@ func (s *ProductServiceImpl) GetAllProducts(ctx context.Context) ([]Product, err
 	return products, nil
 }
 
+// productWhere - условие WHERE для фильтра каталога и его параметры.
+func productWhere(filter ProductFilter) (string, []any, error) {
+	args := []any{NormalizeRegion(filter.Region), filter.IncludeDeleted, filter.CategoryID, filter.StoreID}
+	where := `($2 OR p.deleted_at IS NULL)
+          AND ($1 = '' OR NOT EXISTS (
+            SELECT 1 FROM region_rules r
+            WHERE r.region_code = $1 AND NOT EXISTS (
+              SELECT 1 ` + productVariantsScope + ` AND NOT (` + regionRestrictedCondition + `)
+            )
+          ))
+          AND ($3 = 0 OR p.category_id IN (` + categoryDescendants("$3") + `))
+          AND ($4 = 0 OR EXISTS (SELECT 1 FROM categories c WHERE c.id = p.category_id AND c.store_id = $4))`
+
+	param := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	for _, f := range filter.Attributes {
+		if !attributeCodePattern.MatchString(f.Code) {
+			return "", nil, &AttributeError{Code: f.Code, Reason: "недопустимый код"}
+		}
+		code := param(f.Code)
+		if len(f.Values) > 0 {
+			where += "\n          AND p.attributes->>" + code + "::text = ANY(" + param(pq.Array(f.Values)) + ")"
+		}
+		// Нечисловые значения в диапазон не попадают
+		number := "CASE WHEN jsonb_typeof(p.attributes->" + code + "::text) = 'number' THEN (p.attributes->>" + code + "::text)::numeric END"
+		if f.Min != nil {
+			where += "\n          AND " + number + " >= " + param(*f.Min) + "::numeric"
+		}
+		if f.Max != nil {
+			where += "\n          AND " + number + " <= " + param(*f.Max) + "::numeric"
+		}
+	}
+	return where, args, nil
+}
+
+// GetProductFacets - значения характеристик среди товаров, подходящих под фильтр,
+// с количеством товаров. Если указана категория, порядок, названия и единицы
+// измерения берутся из ее схемы; характеристики вне схемы идут в конце по коду.
+func (s *ProductServiceImpl) GetProductFacets(ctx context.Context, filter ProductFilter) ([]Facet, error) {
+	where, args, err := productWhere(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT e.key, jsonb_typeof(e.value), e.value #>> '{}', count(*)
+        FROM products p, jsonb_each(p.attributes) e
+        WHERE `+where+`
+        GROUP BY 1, 2, 3
+        ORDER BY 1, 3`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var facets []*Facet
+	byCode := make(map[string]*Facet)
+	for rows.Next() {
+		var code, kind, value string
+		var count int
+		if err := rows.Scan(&code, &kind, &value, &count); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		f, ok := byCode[code]
+		if !ok {
+			f = &Facet{Code: code, Name: code, Type: kind, Values: []FacetValue{}}
+			byCode[code] = f
+			facets = append(facets, f)
+		}
+		f.Values = append(f.Values, FacetValue{Value: value, Count: count})
+		if kind == AttributeTypeNumber {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			if f.Min == nil || n < *f.Min {
+				f.Min = &n
+			}
+			if f.Max == nil || n > *f.Max {
+				f.Max = &n
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	// Без схемы числа сортируются по значению, остальные - как строки
+	for _, f := range facets {
+		if f.Min != nil {
+			sort.SliceStable(f.Values, func(i, j int) bool {
+				a, _ := strconv.ParseFloat(f.Values[i].Value, 64)
+				b, _ := strconv.ParseFloat(f.Values[j].Value, 64)
+				return a < b
+			})
+		}
+	}
+
+	result := make([]Facet, 0, len(facets))
+	if filter.CategoryID != 0 {
+		schema, err := loadAttributeSchema(ctx, s.db, filter.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range schema {
+			f, ok := byCode[d.Code]
+			if !ok {
+				continue
+			}
+			f.Name, f.Type, f.Unit = d.Name, d.Type, d.Unit
+			result = append(result, *f)
+			delete(byCode, d.Code)
+		}
+	}
+	for _, f := range facets {
+		if _, ok := byCode[f.Code]; ok {
+			result = append(result, *f)
+		}
+	}
+	return result, nil
+}
+
 // GetProductByID - получение продукта по его ID.
 func (s *ProductServiceImpl) GetProductByID(ctx context.Context, id string) (*Product, error) {
 	var product Product
-	err := s.db.QueryRowContext(ctx, "SELECT * FROM products WHERE id = $1", id).Scan(
-		&product.ID,
-		&product.Name,
-		&product.Description,
-		&product.Price,
-		&product.ImageUrl,
-		&product.CategoryID,
-		&product.ManufacturerID,
-		&product.Stock,
-		&product.VapeType,
-		&product.Power,
-		&product.BatteryCapacity,
-		&product.TankCapacity,
-		&product.CoilResistance,
-		&product.Material,
-		&product.Color,
-		&product.IsNew,
-		&product.IsFeatured,
-	)
+	err := scanProduct(s.db.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products p WHERE p.id = $1 AND p.deleted_at IS NULL", id), &product)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("продукт не найден")
+			return nil, ErrProductNotFound
 		}
 		return nil, fmt.Errorf("ошибка получения продукта по ID: %w", err)
 	}
+	if product.Breadcrumbs, err = categoryBreadcrumbs(ctx, s.db, int64(product.CategoryID)); err != nil {
+		return nil, err
+	}
+	if product.Variants, err = productVariants(ctx, s.db, product.ID); err != nil {
+		return nil, err
+	}
+	if product.Images, err = productImages(ctx, s.db, product.ID); err != nil {
+		return nil, err
+	}
 	return &product, nil
 }
 
 // CreateProduct - создание нового продукта.
 func (s *ProductServiceImpl) CreateProduct(ctx context.Context, product Product) (*Product, error) {
-	// Проверка существования категории
-	var exists bool
-	err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id=$1)", product.CategoryID).Scan(&exists)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка проверки категории: %w", err)
-	}
-	if !exists {
-		return nil, fmt.Errorf("категория с ID %d не существует", product.CategoryID)
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
 	}
+	defer tx.Rollback()
+	product.DeletedAt = nil
+	product.Breadcrumbs = nil
 
-	// Подготовка SQL-запроса
-	query := `
-        INSERT INTO products (name, description, price, image_url, category_id, manufacturer_id, stock, vape_type, power, battery_capacity, tank_capacity, coil_resistance, material, color, is_new, is_featured)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-        RETURNING id`
-
-	// Выполнение запроса
-	res := s.db.QueryRowContext(ctx, query,
-		product.Name,
-		product.Description,
-		product.Price,
-		product.ImageUrl,
-		product.CategoryID,
-		product.ManufacturerID,
-		product.Stock,
-		product.VapeType,
-		product.Power,
-		product.BatteryCapacity,
-		product.TankCapacity,
-		product.CoilResistance,
-		product.Material,
-		product.Color,
-		product.IsNew,
-		product.IsFeatured)
+	if err := insertProduct(ctx, tx, &product); err != nil {
+		return nil, err
+	}
 
-	// Получение ID последней вставленной записи
-	if err := res.Scan(&product.ID); err != nil {
-		return nil, fmt.Errorf("ошибка при вставке продукта: %w", err)
+	if err := recordAudit(ctx, tx, AuditEntityProduct, product.ID, AuditActionCreate, nil, product); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
 	}
 
 	return &product, nil
 }
 
+// UpdateProduct - обновление продукта. Возвращается сохраненная строка целиком,
+// так как обновляются не все поля.
 func (s *ProductServiceImpl) UpdateProduct(ctx context.Context, product Product) (*Product, error) {
-	fmt.Printf("Обновление продукта с ID: %d\n", product.ID) // Логируем ID
+	logger.FromContext(ctx).DebugContext(ctx, "Обновление продукта", slog.Int64("product_id", product.ID))
 
-	result, err := s.db.ExecContext(ctx, `
-        UPDATE products 
-        SET manufacturer_id = $1, name = $2, description = $3, price = $4 
-        WHERE id = $5`,
-		product.ManufacturerID, product.Name, product.Description, product.Price, product.ID)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
 
+	var before Product
+	err = scanProduct(tx.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products p WHERE p.id = $1 AND p.deleted_at IS NULL FOR UPDATE", product.ID), &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("продукт с ID %d не найден", product.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения продукта по ID: %w", err)
+	}
+
+	// Без характеристик в запросе сохраняются прежние
+	schema, err := loadAttributeSchema(ctx, tx, int64(before.CategoryID))
 	if err != nil {
 		return nil, err
 	}
+	if product.Attributes == nil {
+		product.Attributes = before.Attributes
+	} else if err := validateAttributes(schema, product.Attributes); err != nil {
+		return nil, err
+	}
+	attributes, err := json.Marshal(product.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки характеристик: %w", err)
+	}
+	product.applyLegacyAttributes(schema, &before)
 
-	rowsAffected, err := result.RowsAffected()
+	var updated Product
+	err = scanProduct(tx.QueryRowContext(ctx, `
+        UPDATE products AS p
+        SET manufacturer_id = $1, name = $2, description = $3, price = $4,
+            nicotine_strength = $5, volume = $6, flavor = $7, nicotine_salt = $8, attributes = $10,
+            vape_type = $11, power = $12, battery_capacity = $13, tank_capacity = $14,
+            coil_resistance = $15, material = $16, color = $17
+        WHERE p.id = $9
+        RETURNING `+productColumns,
+		product.ManufacturerID, product.Name, product.Description, product.Price,
+		product.NicotineStrength, product.Volume, product.Flavor, product.NicotineSalt, product.ID,
+		string(attributes), product.VapeType, product.Power, product.BatteryCapacity, product.TankCapacity,
+		product.CoilResistance, product.Material, product.Color), &updated)
 	if err != nil {
 		return nil, err
 	}
 
-	if rowsAffected == 0 {
-		return nil, fmt.Errorf("продукт с ID %d не найден", product.ID)
+	if err := recordAudit(ctx, tx, AuditEntityProduct, updated.ID, AuditActionUpdate, before, updated); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
 	}
 
-	return &product, nil // Возвращаем обновленный продукт
+	return &updated, nil // Возвращаем обновленный продукт
 }
 
-// DeleteProduct - удаление продукта.
+// DeleteProduct - мягкое удаление продукта: на него ссылаются покупки и история цен,
+// поэтому строка остается в базе до окончательного удаления фоновой задачей.
 func (s *ProductServiceImpl) DeleteProduct(ctx context.Context, id string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id)
-	return err
+	return s.setDeleted(ctx, id, true, nil)
+}
+
+// RestoreProduct - восстановление удаленного продукта.
+func (s *ProductServiceImpl) RestoreProduct(ctx context.Context, id string) (*Product, error) {
+	var product Product
+	if err := s.setDeleted(ctx, id, false, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// setDeleted - установка или снятие отметки об удалении с записью в журнал аудита.
+// Удаление уже удаленного или отсутствующего продукта ничего не делает.
+func (s *ProductServiceImpl) setDeleted(ctx context.Context, id string, deleted bool, result *Product) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before Product
+	err = scanProduct(tx.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products p WHERE p.id = $1 AND (p.deleted_at IS NULL) = $2 FOR UPDATE",
+		id, deleted), &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		if deleted {
+			return nil
+		}
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	after := before
+	action := AuditActionRestore
+	if deleted {
+		now := time.Now()
+		after.DeletedAt = &now
+		action = AuditActionDelete
+	} else {
+		after.DeletedAt = nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET deleted_at = $2 WHERE id = $1", before.ID, after.DeletedAt); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, AuditEntityProduct, before.ID, action, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	if result != nil {
+		*result = after
+	}
+	return nil
+}
+
+// insertProduct - вставка товара с вариантами; без вариантов товар получает один
+// вариант с его характеристиками и остатком.
+func insertProduct(ctx context.Context, tx *db.Tx, p *Product) error {
+	// Проверка существования категории
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id=$1 AND deleted_at IS NULL)", p.CategoryID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки категории: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("категория с ID %d не существует", p.CategoryID)
+	}
+
+	schema, err := loadAttributeSchema(ctx, tx, int64(p.CategoryID))
+	if err != nil {
+		return err
+	}
+	if p.Attributes == nil {
+		p.Attributes = map[string]any{}
+	}
+	// Устаревшие поля заполняют характеристики, если те не указаны явно
+	for code, v := range p.legacyAttributes() {
+		if _, ok := p.Attributes[code]; !ok && slices.ContainsFunc(schema, func(d AttributeDefinition) bool { return d.Code == code }) {
+			p.Attributes[code] = v
+		}
+	}
+	if err := validateAttributes(schema, p.Attributes); err != nil {
+		return err
+	}
+	// Без вариантов товар получает один вариант с его характеристиками и остатком
+	defaultVariant := len(p.Variants) == 0
+	if defaultVariant {
+		p.Variants = []ProductVariant{p.defaultVariant()}
+	}
+	p.applyLegacyAttributes(schema, nil)
+	// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+	attributes, err := json.Marshal(p.Attributes)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки характеристик: %w", err)
+	}
+
+	// Подготовка SQL-запроса
+	query := `
+        INSERT INTO products (name, description, price, image_url, category_id, manufacturer_id, vape_type, power, battery_capacity, tank_capacity, coil_resistance, material, color, is_new, is_featured, nicotine_strength, volume, flavor, nicotine_salt, attributes)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+        RETURNING id`
+
+	// Выполнение запроса
+	res := tx.QueryRowContext(ctx, query,
+		p.Name,
+		p.Description,
+		p.Price,
+		p.ImageUrl,
+		p.CategoryID,
+		p.ManufacturerID,
+		p.VapeType,
+		p.Power,
+		p.BatteryCapacity,
+		p.TankCapacity,
+		p.CoilResistance,
+		p.Material,
+		p.Color,
+		p.IsNew,
+		p.IsFeatured,
+		p.NicotineStrength,
+		p.Volume,
+		p.Flavor,
+		p.NicotineSalt,
+		string(attributes))
+
+	// Получение ID последней вставленной записи
+	if err := res.Scan(&p.ID); err != nil {
+		return fmt.Errorf("ошибка при вставке продукта: %w", err)
+	}
+
+	if defaultVariant {
+		p.Variants[0].SKU = fmt.Sprintf("SKU-%d", p.ID)
+	}
+	for i := range p.Variants {
+		p.Variants[i].ProductID = p.ID
+		if err := insertVariant(ctx, tx, &p.Variants[i], p.Price); err != nil {
+			return err
+		}
+	}
+	p.summarizeVariants()
+	return nil
+}
+
+// legacyAttributes - непустые значения полей, перенесенных в характеристики, по кодам характеристик.
+func (p *Product) legacyAttributes() map[string]any {
+	values := map[string]any{}
+	for code, v := range map[string]string{"vape_type": p.VapeType, "material": p.Material, "color": p.Color} {
+		if v != "" {
+			values[code] = v
+		}
+	}
+	for code, v := range map[string]float64{
+		"power":            float64(p.Power),
+		"battery_capacity": float64(p.BatteryCapacity),
+		"tank_capacity":    p.TankCapacity,
+		"coil_resistance":  p.CoilResistance,
+	} {
+		if v != 0 {
+			values[code] = v
+		}
+	}
+	return values
+}
+
+// applyLegacyAttributes - заполнение устаревших полей значениями характеристик, которые
+// схема категории schema описывает с подходящим типом. Остальные поля сохраняют значение
+// из запроса, а если оно не указано - из fallback (прежнего состояния товара). Поля
+// сохраняются в столбцы товара, по tank_capacity проверяются региональные ограничения,
+// поэтому их нельзя обнулять только из-за того, что в схеме категории нет характеристики.
+func (p *Product) applyLegacyAttributes(schema []AttributeDefinition, fallback *Product) {
+	if fallback == nil {
+		fallback = &Product{}
+	}
+	defined := func(code string, types ...string) bool {
+		i := slices.IndexFunc(schema, func(d AttributeDefinition) bool { return d.Code == code })
+		return i >= 0 && slices.Contains(types, schema[i].Type)
+	}
+	str := func(field *string, code, old string) {
+		if defined(code, "string") {
+			*field, _ = p.Attributes[code].(string)
+		} else if *field == "" {
+			*field = old
+		}
+	}
+	num := func(field *float64, code string, old float64) {
+		if defined(code, "number", "integer") {
+			*field, _ = p.Attributes[code].(float64)
+		} else if *field == 0 {
+			*field = old
+		}
+	}
+	integer := func(field *int, code string, old int) {
+		v := float64(*field)
+		num(&v, code, float64(old))
+		*field = int(v)
+	}
+	str(&p.VapeType, "vape_type", fallback.VapeType)
+	integer(&p.Power, "power", fallback.Power)
+	integer(&p.BatteryCapacity, "battery_capacity", fallback.BatteryCapacity)
+	num(&p.TankCapacity, "tank_capacity", fallback.TankCapacity)
+	num(&p.CoilResistance, "coil_resistance", fallback.CoilResistance)
+	str(&p.Material, "material", fallback.Material)
+	str(&p.Color, "color", fallback.Color)
+}
+
+// defaultVariant - единственный вариант товара, созданного без вариантов; артикул
+// назначается после вставки товара.
+func (p *Product) defaultVariant() ProductVariant {
+	v := ProductVariant{
+		NicotineStrength: p.NicotineStrength,
+		Stock:            p.Stock,
+	}
+	if color := p.Color; color != "" {
+		v.Color = &color
+	}
+	if resistance := p.CoilResistance; resistance != 0 {
+		v.CoilResistance = &resistance
+	}
+	return v
+}
+
+// summarizeVariants - остаток и диапазон цен по вариантам, как в productColumns.
+func (p *Product) summarizeVariants() {
+	p.Stock, p.PriceMin, p.PriceMax = 0, nil, nil
+	for _, v := range p.Variants {
+		if v.DeletedAt != nil {
+			continue
+		}
+		p.Stock += v.Stock
+		price := v.Price
+		if p.PriceMin == nil || price < *p.PriceMin {
+			p.PriceMin = &price
+		}
+		if p.PriceMax == nil || price > *p.PriceMax {
+			p.PriceMax = &price
+		}
+	}
 }
@@ -0,0 +1,72 @@
+package services
+
+import "testing"
+
+func TestApplyLegacyAttributes(t *testing.T) {
+	tankSchema := []AttributeDefinition{
+		{Code: "tank_capacity", Type: "integer"},
+		{Code: "color", Type: "string"},
+	}
+	stored := &Product{TankCapacity: 5, Color: "Черный", Power: 40}
+
+	tests := []struct {
+		name     string
+		product  Product
+		schema   []AttributeDefinition
+		fallback *Product
+		want     Product
+	}{
+		{
+			name:    "создание в категории без схемы сохраняет поля запроса",
+			product: Product{TankCapacity: 4, Power: 80, VapeType: "Мод"},
+			want:    Product{TankCapacity: 4, Power: 80, VapeType: "Мод"},
+		},
+		{
+			name:     "обновление в категории без схемы сохраняет прежние значения",
+			product:  Product{Attributes: map[string]any{}},
+			fallback: stored,
+			want:     Product{TankCapacity: 5, Color: "Черный", Power: 40},
+		},
+		{
+			name:     "обновление в категории без схемы принимает новые значения",
+			product:  Product{TankCapacity: 2},
+			fallback: stored,
+			want:     Product{TankCapacity: 2, Color: "Черный", Power: 40},
+		},
+		{
+			name:     "описанные характеристики заполняют поля",
+			product:  Product{TankCapacity: 9, Attributes: map[string]any{"tank_capacity": float64(3), "color": "Синий"}},
+			schema:   tankSchema,
+			fallback: stored,
+			want:     Product{TankCapacity: 3, Color: "Синий", Power: 40},
+		},
+		{
+			name:     "удаленная описанная характеристика очищает поле",
+			product:  Product{Attributes: map[string]any{}},
+			schema:   tankSchema,
+			fallback: stored,
+			want:     Product{Power: 40},
+		},
+		{
+			name:     "характеристика другого типа не обнуляет поле",
+			product:  Product{Attributes: map[string]any{"tank_capacity": "2 мл"}},
+			schema:   []AttributeDefinition{{Code: "tank_capacity", Type: "string"}},
+			fallback: stored,
+			want:     Product{TankCapacity: 5, Color: "Черный", Power: 40},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.product
+			p.applyLegacyAttributes(tt.schema, tt.fallback)
+			if p.VapeType != tt.want.VapeType || p.Power != tt.want.Power || p.BatteryCapacity != tt.want.BatteryCapacity ||
+				p.TankCapacity != tt.want.TankCapacity || p.CoilResistance != tt.want.CoilResistance ||
+				p.Material != tt.want.Material || p.Color != tt.want.Color {
+				t.Errorf("поля = {%q %d %d %g %g %q %q}, ожидалось {%q %d %d %g %g %q %q}",
+					p.VapeType, p.Power, p.BatteryCapacity, p.TankCapacity, p.CoilResistance, p.Material, p.Color,
+					tt.want.VapeType, tt.want.Power, tt.want.BatteryCapacity, tt.want.TankCapacity,
+					tt.want.CoilResistance, tt.want.Material, tt.want.Color)
+			}
+		})
+	}
+}
@@ -3,9 +3,28 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+	"VapeShop-ClientAPI/internal/metrics"
+	"VapeShop-ClientAPI/internal/pii"
+)
+
+var (
+	// ErrAgeVerificationRequired - товар с возрастным ограничением, а возраст клиента не подтвержден.
+	ErrAgeVerificationRequired = errors.New("для покупки товара необходимо подтвердить возраст")
+	// ErrUnderage - клиент не достиг минимального возраста для покупки товара.
+	ErrUnderage = errors.New("товар не продается лицам, не достигшим установленного возраста")
+	// ErrDeliveryRegionRequired - без адреса доставки с регионом нельзя проверить региональные ограничения.
+	ErrDeliveryRegionRequired = errors.New("не указан адрес доставки: добавьте адрес в адресную книгу или укажите address_id")
+	// ErrPurchaseNotFound - покупка не найдена или принадлежит другому клиенту.
+	ErrPurchaseNotFound = errors.New("покупка не найдена")
 )
 
 type Purchase struct {
@@ -13,29 +32,87 @@ type Purchase struct {
 	CustomerID int64 `json:"customer_id"`
 	StoreID    int64 `json:"store_id"`
 	ProductID  int64 `json:"product_id"`
-	Quantity   int64 `json:"quantity"` // Изменено на NullInt64
+	// Вариант товара; можно указать артикулом. Если не указан, у товара должен быть один вариант
+	VariantID *int64 `json:"variant_id"`
+	// Артикул варианта на момент покупки
+	SKU      string `json:"sku" validate:"max=64"`
+	Quantity int64  `json:"quantity" validate:"gt=0"` // Изменено на NullInt64
+	// Цена единицы варианта на момент покупки; значение из запроса не используется.
+	// У покупок, оформленных до сохранения цены, отсутствует
+	Price *float64 `json:"price"`
+	// Регион доставки из адреса, по которому проверяются региональные ограничения;
+	// значение из запроса не используется
+	Region string `json:"region" validate:"max=16"`
+	// Адрес из адресной книги; если не указан, используется адрес по умолчанию
+	AddressID *int64 `json:"address_id"`
+	// Копия адреса на момент покупки
+	DeliveryAddress *AddressSnapshot `json:"delivery_address"`
+}
+
+const purchaseColumns = "id, customer_id, store_id, product_id, variant_id, COALESCE(sku, ''), quantity, price, COALESCE(delivery_region, ''), address_id, delivery_address"
+
+// scanPurchase - чтение строки, выбранной с purchaseColumns, с расшифровкой адреса доставки.
+func scanPurchase(row interface{ Scan(dest ...any) error }, c *pii.Cipher, purchase *Purchase) error {
+	var address []byte
+	if err := row.Scan(
+		&purchase.ID,
+		&purchase.CustomerID,
+		&purchase.StoreID,
+		&purchase.ProductID,
+		&purchase.VariantID,
+		&purchase.SKU,
+		&purchase.Quantity,
+		&purchase.Price,
+		&purchase.Region,
+		&purchase.AddressID,
+		&address,
+	); err != nil {
+		return err
+	}
+	if address != nil {
+		purchase.DeliveryAddress = &AddressSnapshot{}
+		if err := json.Unmarshal(address, purchase.DeliveryAddress); err != nil {
+			return fmt.Errorf("ошибка чтения адреса доставки: %w", err)
+		}
+		return decryptSnapshot(c, purchase.DeliveryAddress)
+	}
+	return nil
+}
+
+// decryptSnapshot - расшифровка копии адреса. У обезличенных покупок
+// в копии остается только регион.
+func decryptSnapshot(c *pii.Cipher, snapshot *AddressSnapshot) error {
+	return decryptFields(c, &snapshot.City, &snapshot.Street, &snapshot.Postcode)
 }
 
+// PurchaseService - покупки. Параметр customerID ограничивает чтение и изменение
+// покупками одного клиента; 0 - покупки всех клиентов (для администратора).
 type PurchaseService interface {
-	GetAllPurchases(ctx context.Context) ([]Purchase, error)
-	GetPurchaseByID(ctx context.Context, id string) (*Purchase, error)
+	GetAllPurchases(ctx context.Context, customerID int64) ([]Purchase, error)
+	GetPurchaseByID(ctx context.Context, id string, customerID int64) (*Purchase, error)
 	CreatePurchase(ctx context.Context, purchase Purchase) (*Purchase, error)
-	UpdatePurchase(ctx context.Context, purchase Purchase) (*Purchase, error)
-	DeletePurchase(ctx context.Context, id string) error
+	UpdatePurchase(ctx context.Context, purchase Purchase, customerID int64) (*Purchase, error)
+	DeletePurchase(ctx context.Context, id string, customerID int64) error
 }
 
 type PurchaseServiceImpl struct {
-	db *sql.DB
+	db *db.DB
+	// Минимальный возраст для покупки товаров с возрастным ограничением
+	minimumAge int
+	cipher     *pii.Cipher
 }
 
-func NewPurchaseService(db *sql.DB) *PurchaseServiceImpl {
+func NewPurchaseService(db *db.DB, minimumAge int, cipher *pii.Cipher) *PurchaseServiceImpl {
 	return &PurchaseServiceImpl{
-		db: db,
+		db:         db,
+		minimumAge: minimumAge,
+		cipher:     cipher,
 	}
 }
 
-func (s *PurchaseServiceImpl) GetAllPurchases(ctx context.Context) ([]Purchase, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, customer_id, store_id, product_id, quantity FROM purchases")
+func (s *PurchaseServiceImpl) GetAllPurchases(ctx context.Context, customerID int64) ([]Purchase, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+purchaseColumns+" FROM purchases WHERE ($1 = 0 OR customer_id = $1) ORDER BY id", customerID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
 	}
@@ -44,13 +121,7 @@ func (s *PurchaseServiceImpl) GetAllPurchases(ctx context.Context) ([]Purchase,
 	var purchases []Purchase
 	for rows.Next() {
 		var purchase Purchase
-		if err := rows.Scan(
-			&purchase.ID,
-			&purchase.CustomerID,
-			&purchase.StoreID,
-			&purchase.ProductID,
-			&purchase.Quantity,
-		); err != nil {
+		if err := scanPurchase(rows, s.cipher, &purchase); err != nil {
 			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
 		}
 
@@ -64,7 +135,7 @@ func (s *PurchaseServiceImpl) GetAllPurchases(ctx context.Context) ([]Purchase,
 	return purchases, nil
 }
 
-func (s *PurchaseServiceImpl) GetPurchaseByID(ctx context.Context, id string) (*Purchase, error) {
+func (s *PurchaseServiceImpl) GetPurchaseByID(ctx context.Context, id string, customerID int64) (*Purchase, error) {
 	var purchase Purchase
 
 	// Преобразование ID из строки в целое число
@@ -74,17 +145,13 @@ func (s *PurchaseServiceImpl) GetPurchaseByID(ctx context.Context, id string) (*
 	}
 
 	// Выполнение запроса к базе данных с явным указанием столбцов
-	err = s.db.QueryRowContext(ctx, "SELECT id, customer_id, store_id, product_id, quantity FROM purchases WHERE id = $1", purchaseID).Scan(
-		&purchase.ID,
-		&purchase.CustomerID,
-		&purchase.StoreID,
-		&purchase.ProductID,
-		&purchase.Quantity,
-	)
+	err = scanPurchase(s.db.QueryRowContext(ctx,
+		"SELECT "+purchaseColumns+" FROM purchases WHERE id = $1 AND ($2 = 0 OR customer_id = $2)",
+		purchaseID, customerID), s.cipher, &purchase)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("покупка не найдена")
+			return nil, ErrPurchaseNotFound
 		}
 		return nil, err // Возврат ошибки при других проблемах
 	}
@@ -93,49 +160,259 @@ func (s *PurchaseServiceImpl) GetPurchaseByID(ctx context.Context, id string) (*
 }
 
 func (s *PurchaseServiceImpl) CreatePurchase(ctx context.Context, purchase Purchase) (*Purchase, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Регион доставки берется только из адреса клиента: регион из запроса ничем
+	// не подтвержден и позволил бы обойти региональные ограничения
+	addressID, snapshot, err := deliveryAddress(ctx, tx, purchase.CustomerID, purchase.AddressID)
+	if err != nil {
+		return nil, err
+	}
+	purchase.AddressID, purchase.DeliveryAddress, purchase.Region = nil, nil, ""
+	if snapshot != nil {
+		purchase.AddressID = addressID
+		purchase.DeliveryAddress = snapshot
+		purchase.Region = snapshot.Region
+	}
+	if purchase.Region == "" {
+		return nil, ErrDeliveryRegionRequired
+	}
+
+	variant, err := lockPurchaseVariant(ctx, tx, purchase.ProductID, purchase.VariantID, purchase.SKU)
+	if err != nil {
+		return nil, err
+	}
+	purchase.ProductID, purchase.VariantID, purchase.SKU = variant.productID, &variant.id, variant.sku
+	purchase.Price = &variant.price
+	if err := s.checkRestrictions(ctx, tx, variant, purchase.CustomerID, purchase.Region); err != nil {
+		return nil, err
+	}
+
+	if err := variant.take(ctx, tx, purchase.Quantity); err != nil {
+		return nil, err
+	}
+
 	// Используем RETURNING для получения ID вставленной записи
 	query := `
-        INSERT INTO purchases (customer_id, store_id, product_id, quantity) 
-        VALUES ($1, $2, $3, $4) 
+        INSERT INTO purchases (customer_id, store_id, product_id, variant_id, sku, quantity, delivery_region, address_id, delivery_address, price) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
         RETURNING id`
 
-	err := s.db.QueryRowContext(ctx, query,
+	// lib/pq передает []byte как bytea, поэтому JSONB отправляем строкой
+	var address *string
+	if purchase.DeliveryAddress != nil {
+		data, err := json.Marshal(purchase.DeliveryAddress)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сохранения адреса доставки: %w", err)
+		}
+		str := string(data)
+		address = &str
+	}
+
+	err = tx.QueryRowContext(ctx, query,
 		purchase.CustomerID,
 		purchase.StoreID,
 		purchase.ProductID,
-		purchase.Quantity).Scan(&purchase.ID)
+		purchase.VariantID,
+		purchase.SKU,
+		purchase.Quantity,
+		purchase.Region,
+		purchase.AddressID,
+		address,
+		purchase.Price).Scan(&purchase.ID)
 
 	if err != nil {
 		return nil, err // Возврат ошибки при выполнении запроса
 	}
 
+	if err := recordAudit(ctx, tx, AuditEntityPurchase, purchase.ID, AuditActionCreate, nil, auditPurchase(purchase)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	if purchase.DeliveryAddress != nil {
+		if err := decryptSnapshot(s.cipher, purchase.DeliveryAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	metrics.PurchasesCreated.Inc()
+	metrics.Revenue.Add(variant.price * float64(purchase.Quantity))
+	logger.FromContext(ctx).InfoContext(ctx, "Покупка создана",
+		slog.Int64("purchase_id", purchase.ID),
+		slog.Int64("product_id", purchase.ProductID),
+		slog.String("sku", purchase.SKU),
+		slog.Int64("quantity", purchase.Quantity))
+
 	return &purchase, nil // Возврат созданной покупки с установленным ID
 }
 
-func (s *PurchaseServiceImpl) UpdatePurchase(ctx context.Context, purchase Purchase) (*Purchase, error) {
-	res, err := s.db.ExecContext(ctx, `
+// checkRestrictions - возрастное ограничение категории и правила региона доставки
+// для варианта, который покупает клиент.
+func (s *PurchaseServiceImpl) checkRestrictions(ctx context.Context, tx *db.Tx, variant *purchaseVariant, customerID int64, region string) error {
+	if variant.ageRestricted {
+		if err := s.checkAge(ctx, tx, customerID); err != nil {
+			return err
+		}
+	}
+
+	rule, err := getRegionRule(ctx, tx, region)
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		if err := rule.check(variant.nicotineStrength, variant.tankCapacity, variant.flavor); err != nil {
+			logger.FromContext(ctx).WarnContext(ctx, "Покупка отклонена: региональное ограничение",
+				slog.Int64("product_id", variant.productID),
+				slog.String("region", region))
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAge - проверка, что возраст клиента подтвержден и не меньше минимального.
+func (s *PurchaseServiceImpl) checkAge(ctx context.Context, tx *db.Tx, customerID int64) error {
+	var status string
+	var dob sql.NullTime
+	err := tx.QueryRowContext(ctx,
+		"SELECT age_verification_status, date_of_birth FROM customers WHERE id = $1",
+		customerID).Scan(&status, &dob)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCustomerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка проверки возраста: %w", err)
+	}
+
+	log := logger.FromContext(ctx)
+	if status != AgeVerificationVerified || !dob.Valid {
+		log.WarnContext(ctx, "Покупка отклонена: возраст не подтвержден", slog.Int64("customer_id", customerID))
+		return ErrAgeVerificationRequired
+	}
+	if !isAdult(dob.Time, s.minimumAge, time.Now()) {
+		log.WarnContext(ctx, "Покупка отклонена: клиент не достиг минимального возраста", slog.Int64("customer_id", customerID))
+		return ErrUnderage
+	}
+	return nil
+}
+
+func (s *PurchaseServiceImpl) UpdatePurchase(ctx context.Context, purchase Purchase, customerID int64) (*Purchase, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before Purchase
+	err = scanPurchase(tx.QueryRowContext(ctx,
+		"SELECT "+purchaseColumns+" FROM purchases WHERE id = $1 AND ($2 = 0 OR customer_id = $2) FOR UPDATE",
+		purchase.ID, customerID), s.cipher, &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPurchaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Клиент не может передать свою покупку другому клиенту
+	if customerID != 0 {
+		purchase.CustomerID = customerID
+	}
+
+	// Прежнее количество возвращается на остаток, новое списывается с варианта,
+	// указанного в запросе; без него остается прежний вариант того же товара
+	if err := returnStock(ctx, tx, before); err != nil {
+		return nil, err
+	}
+	if purchase.VariantID == nil && purchase.SKU == "" && purchase.ProductID == before.ProductID {
+		purchase.VariantID = before.VariantID
+	}
+	variant, err := lockPurchaseVariant(ctx, tx, purchase.ProductID, purchase.VariantID, purchase.SKU)
+	if err != nil {
+		return nil, err
+	}
+	purchase.ProductID, purchase.VariantID, purchase.SKU = variant.productID, &variant.id, variant.sku
+	// Цена сохраняется, пока не меняется вариант
+	purchase.Price = before.Price
+	if before.VariantID == nil || *before.VariantID != variant.id || before.Price == nil {
+		purchase.Price = &variant.price
+	}
+	// Товар или клиент могли измениться, поэтому ограничения проверяются заново,
+	// как при оформлении покупки
+	if err := s.checkRestrictions(ctx, tx, variant, purchase.CustomerID, before.Region); err != nil {
+		return nil, err
+	}
+	if err := variant.take(ctx, tx, purchase.Quantity); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
         UPDATE purchases 
-        SET customer_id = $1, store_id = $2, product_id = $3, quantity = $4 
+        SET customer_id = $1, store_id = $2, product_id = $3, quantity = $4, variant_id = $6, sku = NULLIF($7, ''), price = $8
         WHERE id = $5`,
-		purchase.CustomerID, purchase.StoreID, purchase.ProductID, purchase.Quantity, purchase.ID)
+		purchase.CustomerID, purchase.StoreID, purchase.ProductID, purchase.Quantity, purchase.ID,
+		purchase.VariantID, purchase.SKU, purchase.Price)
 
 	if err != nil {
 		return nil, err
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
+	after := before
+	after.CustomerID = purchase.CustomerID
+	after.StoreID = purchase.StoreID
+	after.ProductID = purchase.ProductID
+	after.VariantID = purchase.VariantID
+	after.SKU = purchase.SKU
+	after.Quantity = purchase.Quantity
+	after.Price = purchase.Price
+	if err := recordAudit(ctx, tx, AuditEntityPurchase, purchase.ID, AuditActionUpdate, auditPurchase(before), auditPurchase(after)); err != nil {
 		return nil, err
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return &after, nil // Возвращаем обновлённую покупку
+}
+
+func (s *PurchaseServiceImpl) DeletePurchase(ctx context.Context, id string, customerID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		return nil, errors.New("запись не найдена") // Обработка случая, когда запись не найдена
+	var purchase Purchase
+	err = scanPurchase(tx.QueryRowContext(ctx,
+		"DELETE FROM purchases WHERE id = $1 AND ($2 = 0 OR customer_id = $2) RETURNING "+purchaseColumns,
+		id, customerID), s.cipher, &purchase)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPurchaseNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := returnStock(ctx, tx, purchase); err != nil {
+		return err
 	}
 
-	return &purchase, nil // Возвращаем обновлённую покупку
+	if err := recordAudit(ctx, tx, AuditEntityPurchase, purchase.ID, AuditActionDelete, auditPurchase(purchase), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *PurchaseServiceImpl) DeletePurchase(ctx context.Context, id string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM purchases WHERE id = $1", id)
-	return err
+// auditPurchase - покупка для журнала аудита. Копия адреса доставки содержит
+// персональные данные и после покупки не меняется, поэтому в журнал не пишется.
+func auditPurchase(purchase Purchase) Purchase {
+	purchase.DeliveryAddress = nil
+	return purchase
 }
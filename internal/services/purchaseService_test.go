@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"VapeShop-ClientAPI/internal/config"
+	"VapeShop-ClientAPI/internal/db"
+)
+
+// openTestDB - подключение к тестовой базе TEST_DATABASE_NAME с примененными миграциями;
+// остальные параметры берутся из DATABASE_*. Без TEST_DATABASE_NAME тест пропускается.
+func openTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	name := os.Getenv("TEST_DATABASE_NAME")
+	if name == "" {
+		t.Skip("TEST_DATABASE_NAME не задан")
+	}
+	port, _ := strconv.Atoi(os.Getenv("DATABASE_PORT"))
+	conn, err := db.NewDB(config.Database{
+		Host:     os.Getenv("DATABASE_HOST"),
+		Port:     port,
+		User:     os.Getenv("DATABASE_USER"),
+		Password: os.Getenv("DATABASE_PASSWORD"),
+		Name:     name,
+		SSLMode:  os.Getenv("DATABASE_SSLMODE"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestLockPurchaseVariantAgeRestrictedAncestor(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// insert - вставка строки с возвратом ID
+	insert := func(t *testing.T, query string, args ...any) int64 {
+		t.Helper()
+		var id int64
+		if err := tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	root := insert(t, "INSERT INTO categories (name, age_restricted, slug) VALUES ('Жидкости', TRUE, 'test-age-root')")
+	child := insert(t, "INSERT INTO categories (name, parent_id, slug) VALUES ('Солевые', $1, 'test-age-child')", root)
+	leaf := insert(t, "INSERT INTO categories (name, parent_id, slug) VALUES ('Ягодные', $1, 'test-age-leaf')", child)
+	free := insert(t, "INSERT INTO categories (name, slug) VALUES ('Аксессуары', 'test-age-free')")
+
+	tests := []struct {
+		name       string
+		categoryID *int64
+		want       bool
+	}{
+		{"категория с ограничением", &root, true},
+		{"подкатегория", &child, true},
+		{"вложенная подкатегория", &leaf, true},
+		{"категория без ограничения", &free, false},
+		{"товар без категории", nil, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			productID := insert(t, "INSERT INTO products (name, price, category_id) VALUES ('Товар', 100, $1)", tt.categoryID)
+			insert(t, "INSERT INTO product_variants (product_id, sku, stock) VALUES ($1, $2, 1)",
+				productID, "TEST-AGE-"+strconv.Itoa(i))
+
+			variant, err := lockPurchaseVariant(ctx, tx, productID, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if variant.ageRestricted != tt.want {
+				t.Errorf("ageRestricted = %v, ожидалось %v", variant.ageRestricted, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+// purgeBatchSize - количество строк, удаляемых в одной транзакции.
+const purgeBatchSize = 100
+
+// purgeBatch - окончательное удаление очередной порции строк одной таблицы;
+// возвращает количество удаленных строк и ключи файлов, которые нужно удалить
+// из хранилища после фиксации транзакции.
+type purgeBatch func(ctx context.Context, tx *db.Tx, cutoff time.Time) (int, []string, error)
+
+// PurgeService - окончательное удаление категорий и товаров.
+type PurgeService interface {
+	Purge(ctx context.Context) (int, error)
+}
+
+// PurgeServiceImpl - удаление из базы записей, удаленных раньше срока хранения,
+// на которые больше ничего не ссылается. Записи с историей покупок и цен остаются навсегда.
+type PurgeServiceImpl struct {
+	db        *db.DB
+	store     storage.BlobStore
+	retention time.Duration
+}
+
+// NewPurgeService - функция для создания нового сервиса окончательного удаления.
+func NewPurgeService(db *db.DB, store storage.BlobStore, retention time.Duration) *PurgeServiceImpl {
+	return &PurgeServiceImpl{
+		db:        db,
+		store:     store,
+		retention: retention,
+	}
+}
+
+// RunPurge - периодическое окончательное удаление до отмены контекста.
+func (s *PurgeServiceImpl) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Purge(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Ошибка окончательного удаления каталога", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Purge - окончательное удаление. Сначала удаляются товары, чтобы освободившиеся
+// категории удалились в том же проходе. Возвращает количество удаленных строк.
+func (s *PurgeServiceImpl) Purge(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-s.retention)
+	total := 0
+	for _, purge := range []purgeBatch{
+		purgeProducts,
+		purgeCategories,
+	} {
+		for ctx.Err() == nil {
+			n, err := s.runBatch(ctx, cutoff, purge)
+			if err != nil {
+				return total, err
+			}
+			total += n
+			if n < purgeBatchSize {
+				break
+			}
+		}
+	}
+	if total > 0 {
+		slog.Info("Удаленные записи каталога удалены окончательно", slog.Int("rows", total))
+	}
+	return total, nil
+}
+
+// runBatch - выполнение пакета в отдельной транзакции.
+func (s *PurgeServiceImpl) runBatch(ctx context.Context, cutoff time.Time, purge purgeBatch) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	n, files, err := purge(ctx, tx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	deleteImageFiles(context.WithoutCancel(ctx), s.store, files)
+	return n, nil
+}
+
+// purgeProducts - товары без покупок и истории цен вместе с их вариантами и изображениями.
+// Ограничения внешних ключей проверяются в конце запроса, поэтому варианты
+// удаляются в том же запросе; в нем же товары записываются в журнал аудита с вариантами.
+func purgeProducts(ctx context.Context, tx *db.Tx, cutoff time.Time) (int, []string, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id FROM products
+        WHERE deleted_at < $1
+          AND NOT EXISTS (SELECT 1 FROM purchases WHERE product_id = products.id)
+          AND NOT EXISTS (SELECT 1 FROM purchase_items WHERE product_id = products.id)
+          AND NOT EXISTS (SELECT 1 FROM price_change WHERE product_id = products.id)
+        ORDER BY id
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED`, cutoff, purgeBatchSize)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления товаров: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления товаров: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil, nil
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		"DELETE FROM product_images i WHERE i.product_id = ANY($1) RETURNING "+imageColumns, pq.Array(ids))
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления изображений: %w", err)
+	}
+	var files []string
+	for rows.Next() {
+		var img ProductImage
+		if err := scanImage(rows, &img); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		files = append(files, img.keys()...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления изображений: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+        WITH variants AS (
+            DELETE FROM product_variants WHERE product_id = ANY($1)
+        ), reviews AS (
+            DELETE FROM reviews WHERE product_id = ANY($1)
+        )
+        DELETE FROM products AS p
+        WHERE p.id = ANY($1)
+        RETURNING `+productColumns, pq.Array(ids))
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления товаров: %w", err)
+	}
+	var products []Product
+	for rows.Next() {
+		var product Product
+		if err := scanProduct(rows, &product); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		products = append(products, product)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления товаров: %w", err)
+	}
+
+	for _, product := range products {
+		if err := recordAudit(ctx, tx, AuditEntityProduct, product.ID, AuditActionPurge, product, nil); err != nil {
+			return 0, nil, err
+		}
+	}
+	return len(products), files, nil
+}
+
+// purgeCategories - категории без товаров и подкатегорий, в том числе удаленных,
+// вместе с описаниями их характеристик.
+func purgeCategories(ctx context.Context, tx *db.Tx, cutoff time.Time) (int, []string, error) {
+	rows, err := tx.QueryContext(ctx, `
+        WITH purged AS (
+            SELECT id FROM categories c
+            WHERE c.deleted_at < $1
+              AND NOT EXISTS (SELECT 1 FROM products WHERE category_id = c.id)
+              AND NOT EXISTS (SELECT 1 FROM categories ch WHERE ch.parent_id = c.id)
+            ORDER BY id
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        ), attributes AS (
+            DELETE FROM category_attributes WHERE category_id IN (SELECT id FROM purged)
+        )
+        DELETE FROM categories
+        WHERE id IN (SELECT id FROM purged)
+        RETURNING `+categoryColumns, cutoff, purgeBatchSize)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления категорий: %w", err)
+	}
+	var categories []Category
+	for rows.Next() {
+		var category Category
+		if err := scanCategory(rows, &category); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("ошибка удаления категорий: %w", err)
+	}
+
+	for _, category := range categories {
+		if err := recordAudit(ctx, tx, AuditEntityCategory, category.ID, AuditActionPurge, category, nil); err != nil {
+			return 0, nil, err
+		}
+	}
+	return len(categories), nil, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+
+	"github.com/lib/pq"
+)
+
+var ErrRegionRuleNotFound = errors.New("правила для региона не найдены")
+
+// RegionRule - ограничения продажи товаров в регионе доставки.
+// Нулевые указатели означают отсутствие ограничения.
+type RegionRule struct {
+	RegionCode          string    `json:"region_code"`
+	Name                string    `json:"name" validate:"required,max=255"`
+	MaxNicotineStrength *float64  `json:"max_nicotine_strength" validate:"omitempty,gte=0"`
+	MaxTankCapacity     *int      `json:"max_tank_capacity" validate:"omitempty,gte=0"`
+	BannedFlavors       []string  `json:"banned_flavors" validate:"dive,required,max=255"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// RegionRestrictedError - товар нельзя продать в регион доставки.
+type RegionRestrictedError struct {
+	Region string
+	Reason string
+}
+
+func (e *RegionRestrictedError) Error() string {
+	return fmt.Sprintf("товар не может быть доставлен в регион %s: %s", e.Region, e.Reason)
+}
+
+// regionRestrictedCondition - SQL-условие, при котором вариант v товара p нельзя продать
+// в регион r. Крепость варианта берется как при оформлении покупки; неизвестное
+// значение, как и в RegionRule.check, ограничением не считается.
+const regionRestrictedCondition = `
+    COALESCE(COALESCE(v.nicotine_strength, p.nicotine_strength) > r.max_nicotine_strength, FALSE)
+    OR COALESCE(p.tank_capacity > r.max_tank_capacity, FALSE)
+    OR COALESCE(lower(p.flavor) = ANY(r.banned_flavors), FALSE)`
+
+// check - проверка характеристик товара по правилам региона.
+func (r *RegionRule) check(nicotineStrength sql.NullFloat64, tankCapacity sql.NullInt64, flavor sql.NullString) error {
+	switch {
+	case r.MaxNicotineStrength != nil && nicotineStrength.Valid && nicotineStrength.Float64 > *r.MaxNicotineStrength:
+		return &RegionRestrictedError{
+			Region: r.RegionCode,
+			Reason: fmt.Sprintf("крепость никотина превышает %g мг/мл", *r.MaxNicotineStrength),
+		}
+	case r.MaxTankCapacity != nil && tankCapacity.Valid && tankCapacity.Int64 > int64(*r.MaxTankCapacity):
+		return &RegionRestrictedError{
+			Region: r.RegionCode,
+			Reason: fmt.Sprintf("объем бака превышает %d мл", *r.MaxTankCapacity),
+		}
+	case flavor.Valid && slices.Contains(r.BannedFlavors, strings.ToLower(flavor.String)):
+		return &RegionRestrictedError{
+			Region: r.RegionCode,
+			Reason: fmt.Sprintf("вкус %q запрещен", flavor.String),
+		}
+	}
+	return nil
+}
+
+// NormalizeRegion - коды регионов хранятся в верхнем регистре.
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+type RegionRuleService interface {
+	GetRegionRules(ctx context.Context) ([]RegionRule, error)
+	GetRegionRule(ctx context.Context, region string) (*RegionRule, error)
+	SaveRegionRule(ctx context.Context, rule RegionRule) (*RegionRule, error)
+	DeleteRegionRule(ctx context.Context, region string) error
+}
+
+// RegionRuleServiceImpl - управление региональными ограничениями продаж.
+type RegionRuleServiceImpl struct {
+	db *db.DB
+}
+
+// NewRegionRuleService - функция для создания нового сервиса региональных ограничений.
+func NewRegionRuleService(db *db.DB) *RegionRuleServiceImpl {
+	return &RegionRuleServiceImpl{
+		db: db,
+	}
+}
+
+// GetRegionRules - получение правил всех регионов.
+func (s *RegionRuleServiceImpl) GetRegionRules(ctx context.Context) ([]RegionRule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT region_code, name, max_nicotine_strength, max_tank_capacity, banned_flavors, updated_at
+        FROM region_rules
+        ORDER BY region_code`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []RegionRule{}
+	for rows.Next() {
+		var rule RegionRule
+		if err := rows.Scan(
+			&rule.RegionCode,
+			&rule.Name,
+			&rule.MaxNicotineStrength,
+			&rule.MaxTankCapacity,
+			pq.Array(&rule.BannedFlavors),
+			&rule.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	return rules, nil
+}
+
+// GetRegionRule - получение правил региона.
+func (s *RegionRuleServiceImpl) GetRegionRule(ctx context.Context, region string) (*RegionRule, error) {
+	rule, err := getRegionRule(ctx, s.db, NormalizeRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrRegionRuleNotFound
+	}
+	return rule, nil
+}
+
+// SaveRegionRule - создание или замена правил региона.
+func (s *RegionRuleServiceImpl) SaveRegionRule(ctx context.Context, rule RegionRule) (*RegionRule, error) {
+	rule.RegionCode = NormalizeRegion(rule.RegionCode)
+
+	flavors := make([]string, 0, len(rule.BannedFlavors))
+	for _, f := range rule.BannedFlavors {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !slices.Contains(flavors, f) {
+			flavors = append(flavors, f)
+		}
+	}
+	rule.BannedFlavors = flavors
+
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO region_rules (region_code, name, max_nicotine_strength, max_tank_capacity, banned_flavors, updated_at)
+        VALUES ($1, $2, $3, $4, $5, now())
+        ON CONFLICT (region_code) DO UPDATE
+        SET name = EXCLUDED.name,
+            max_nicotine_strength = EXCLUDED.max_nicotine_strength,
+            max_tank_capacity = EXCLUDED.max_tank_capacity,
+            banned_flavors = EXCLUDED.banned_flavors,
+            updated_at = EXCLUDED.updated_at
+        RETURNING updated_at`,
+		rule.RegionCode, rule.Name, rule.MaxNicotineStrength, rule.MaxTankCapacity, pq.Array(rule.BannedFlavors),
+	).Scan(&rule.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения правил региона: %w", err)
+	}
+
+	return &rule, nil
+}
+
+// DeleteRegionRule - удаление правил региона; после этого продажи в регион не ограничены.
+func (s *RegionRuleServiceImpl) DeleteRegionRule(ctx context.Context, region string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM region_rules WHERE region_code = $1", NormalizeRegion(region))
+	if err != nil {
+		return fmt.Errorf("ошибка удаления правил региона: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRegionRuleNotFound
+	}
+	return nil
+}
+
+// queryRower - общий интерфейс *db.DB и *db.Tx для чтения одной строки.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// getRegionRule - правила региона или nil, если для региона ограничений нет.
+func getRegionRule(ctx context.Context, q queryRower, region string) (*RegionRule, error) {
+	var rule RegionRule
+	err := q.QueryRowContext(ctx, `
+        SELECT region_code, name, max_nicotine_strength, max_tank_capacity, banned_flavors, updated_at
+        FROM region_rules WHERE region_code = $1`, region).Scan(
+		&rule.RegionCode,
+		&rule.Name,
+		&rule.MaxNicotineStrength,
+		&rule.MaxTankCapacity,
+		pq.Array(&rule.BannedFlavors),
+		&rule.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения правил региона: %w", err)
+	}
+	return &rule, nil
+}
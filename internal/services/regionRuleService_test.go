@@ -0,0 +1,60 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegionRuleCheck(t *testing.T) {
+	nicotine := 20.0
+	tank := 2
+	rule := &RegionRule{
+		RegionCode:          "EU",
+		MaxNicotineStrength: &nicotine,
+		MaxTankCapacity:     &tank,
+		BannedFlavors:       []string{"ментол", "mango"},
+	}
+	strength := func(v float64) sql.NullFloat64 { return sql.NullFloat64{Float64: v, Valid: true} }
+	capacity := func(v int64) sql.NullInt64 { return sql.NullInt64{Int64: v, Valid: true} }
+	flavor := func(v string) sql.NullString { return sql.NullString{String: v, Valid: true} }
+
+	tests := []struct {
+		name       string
+		rule       *RegionRule
+		nicotine   sql.NullFloat64
+		tank       sql.NullInt64
+		flavor     sql.NullString
+		wantReason string
+	}{
+		{"в пределах ограничений", rule, strength(20), capacity(2), flavor("Табак"), ""},
+		{"крепость выше предела", rule, strength(20.5), capacity(2), flavor("Табак"), "крепость никотина превышает 20 мг/мл"},
+		{"объем бака выше предела", rule, strength(0), capacity(3), sql.NullString{}, "объем бака превышает 2 мл"},
+		{"запрещенный вкус", rule, sql.NullFloat64{}, sql.NullInt64{}, flavor("Ментол"), `вкус "Ментол" запрещен`},
+		{"запрещенный вкус в другом регистре", rule, sql.NullFloat64{}, sql.NullInt64{}, flavor("MANGO"), `вкус "MANGO" запрещен`},
+		{"неизвестные характеристики", rule, sql.NullFloat64{}, sql.NullInt64{}, sql.NullString{}, ""},
+		{"регион без ограничений", &RegionRule{RegionCode: "RU"}, strength(50), capacity(10), flavor("ментол"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.check(tt.nicotine, tt.tank, tt.flavor)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("check = %v, ожидалось nil", err)
+				}
+				return
+			}
+			var restricted *RegionRestrictedError
+			if !errors.As(err, &restricted) {
+				t.Fatalf("check = %v, ожидалась RegionRestrictedError", err)
+			}
+			if restricted.Region != tt.rule.RegionCode || restricted.Reason != tt.wantReason {
+				t.Errorf("ошибка = {%s %q}, ожидалось {%s %q}", restricted.Region, restricted.Reason, tt.rule.RegionCode, tt.wantReason)
+			}
+			if !strings.Contains(err.Error(), tt.rule.RegionCode) {
+				t.Errorf("текст ошибки %q без региона", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
+)
+
+// Статусы модерации отзывов
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
+var (
+	ErrReviewNotFound = errors.New("отзыв не найден")
+	// ErrReviewExists - клиент уже оставил отзыв о товаре.
+	ErrReviewExists = errors.New("вы уже оставили отзыв об этом товаре")
+)
+
+// Review - отзыв клиента о товаре.
+type Review struct {
+	ID         int64 `json:"id"`
+	ProductID  int64 `json:"product_id"`
+	CustomerID int64 `json:"customer_id"`
+	// Имя автора; у клиентов, удаливших учетную запись, отзывов нет
+	Author string `json:"author"`
+	Rating int    `json:"rating"`
+	Text   string `json:"text"`
+	// Автор покупал товар на момент написания отзыва
+	VerifiedPurchase bool `json:"verified_purchase"`
+	// pending, approved или rejected; в каталоге видны только одобренные
+	Status          string     `json:"status"`
+	RejectionReason *string    `json:"rejection_reason,omitempty"`
+	ModeratedBy     *int64     `json:"moderated_by,omitempty"`
+	ModeratedAt     *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// CreateReviewRequest - новый отзыв.
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Text   string `json:"text" validate:"max=5000"`
+}
+
+// RejectReviewRequest - отклонение отзыва с причиной для автора.
+type RejectReviewRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+// ReviewFilter - фильтр отзывов; нулевые значения не ограничивают выборку.
+type ReviewFilter struct {
+	ProductID int64
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+const reviewColumns = `r.id, r.product_id, r.customer_id, c.first_name, r.rating, r.text, r.verified_purchase,
+    r.status, r.rejection_reason, r.moderated_by, r.moderated_at, r.created_at`
+
+// scanReview - чтение строки, выбранной с reviewColumns из reviews r JOIN customers c.
+func scanReview(row interface{ Scan(dest ...any) error }, review *Review) error {
+	return row.Scan(
+		&review.ID,
+		&review.ProductID,
+		&review.CustomerID,
+		&review.Author,
+		&review.Rating,
+		&review.Text,
+		&review.VerifiedPurchase,
+		&review.Status,
+		&review.RejectionReason,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		&review.CreatedAt,
+	)
+}
+
+type ReviewService interface {
+	GetProductReviews(ctx context.Context, productID int64, limit, offset int) ([]Review, error)
+	CreateReview(ctx context.Context, productID, customerID int64, req CreateReviewRequest) (*Review, error)
+	GetReviews(ctx context.Context, filter ReviewFilter) ([]Review, error)
+	ApproveReview(ctx context.Context, id, adminID int64) (*Review, error)
+	RejectReview(ctx context.Context, id, adminID int64, req RejectReviewRequest) (*Review, error)
+	DeleteReview(ctx context.Context, id int64) error
+}
+
+// ReviewServiceImpl - отзывы о товарах. Новые отзывы ждут модерации; рейтинг товара
+// (rating_sum и rating_count) меняется в той же транзакции, что и статус отзыва,
+// и учитывает только одобренные отзывы.
+type ReviewServiceImpl struct {
+	db *db.DB
+}
+
+// NewReviewService - функция для создания нового сервиса отзывов.
+func NewReviewService(db *db.DB) *ReviewServiceImpl {
+	return &ReviewServiceImpl{
+		db: db,
+	}
+}
+
+// GetProductReviews - одобренные отзывы о товаре, новые первыми.
+func (s *ReviewServiceImpl) GetProductReviews(ctx context.Context, productID int64, limit, offset int) ([]Review, error) {
+	if err := checkReviewProduct(ctx, s.db, productID); err != nil {
+		return nil, err
+	}
+	reviews, err := s.GetReviews(ctx, ReviewFilter{ProductID: productID, Status: ReviewStatusApproved, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	// Кто из администраторов проверял отзыв, покупателям не показывается
+	for i := range reviews {
+		reviews[i].ModeratedBy = nil
+	}
+	return reviews, nil
+}
+
+// CreateReview - отзыв клиента о товаре; отправляется на модерацию. Отметка о покупке
+// ставится, если товар есть в покупках клиента: в строках purchase_items или
+// в самой покупке, если строк у нее нет.
+func (s *ReviewServiceImpl) CreateReview(ctx context.Context, productID, customerID int64, req CreateReviewRequest) (*Review, error) {
+	if err := checkReviewProduct(ctx, s.db, productID); err != nil {
+		return nil, err
+	}
+
+	var review Review
+	err := scanReview(s.db.QueryRowContext(ctx, `
+        WITH r AS (
+            INSERT INTO reviews (product_id, customer_id, rating, text, verified_purchase)
+            VALUES ($1, $2, $3, $4, EXISTS (
+                SELECT 1 FROM purchases pu
+                WHERE pu.customer_id = $2
+                  AND (EXISTS (SELECT 1 FROM purchase_items i WHERE i.purchase_id = pu.id AND i.product_id = $1)
+                    OR (pu.product_id = $1 AND NOT EXISTS (SELECT 1 FROM purchase_items i WHERE i.purchase_id = pu.id)))
+            ))
+            ON CONFLICT (product_id, customer_id) DO NOTHING
+            RETURNING *
+        )
+        SELECT `+reviewColumns+`
+        FROM r JOIN customers c ON c.id = r.customer_id`,
+		productID, customerID, req.Rating, req.Text), &review)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReviewExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания отзыва: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Отзыв отправлен на модерацию",
+		slog.Int64("review_id", review.ID),
+		slog.Int64("product_id", productID))
+	return &review, nil
+}
+
+// GetReviews - отзывы для модерации, новые первыми.
+func (s *ReviewServiceImpl) GetReviews(ctx context.Context, filter ReviewFilter) ([]Review, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+reviewColumns+`
+        FROM reviews r
+        JOIN customers c ON c.id = r.customer_id
+        WHERE ($1 = 0 OR r.product_id = $1) AND ($2 = '' OR r.status = $2)
+        ORDER BY r.created_at DESC, r.id DESC
+        LIMIT $3 OFFSET $4`, filter.ProductID, filter.Status, limit, max(filter.Offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		var review Review
+		if err := scanReview(rows, &review); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+
+	return reviews, nil
+}
+
+// ApproveReview - одобрение отзыва: он появляется в каталоге и учитывается в рейтинге товара.
+// Одобрить можно и отклоненный ранее отзыв.
+func (s *ReviewServiceImpl) ApproveReview(ctx context.Context, id, adminID int64) (*Review, error) {
+	return s.moderate(ctx, id, adminID, ReviewStatusApproved, nil)
+}
+
+// RejectReview - отклонение отзыва; одобренный ранее отзыв убирается из каталога и рейтинга.
+func (s *ReviewServiceImpl) RejectReview(ctx context.Context, id, adminID int64, req RejectReviewRequest) (*Review, error) {
+	return s.moderate(ctx, id, adminID, ReviewStatusRejected, &req.Reason)
+}
+
+func (s *ReviewServiceImpl) moderate(ctx context.Context, id, adminID int64, status string, reason *string) (*Review, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := lockReview(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var after Review
+	if err := scanReview(tx.QueryRowContext(ctx, `
+        WITH r AS (
+            UPDATE reviews
+            SET status = $2, rejection_reason = $3, moderated_by = $4, moderated_at = now()
+            WHERE id = $1
+            RETURNING *
+        )
+        SELECT `+reviewColumns+`
+        FROM r JOIN customers c ON c.id = r.customer_id`, id, status, reason, adminID), &after); err != nil {
+		return nil, fmt.Errorf("ошибка обновления отзыва: %w", err)
+	}
+	if err := updateProductRating(ctx, tx, before, &after); err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, AuditEntityReview, id, AuditActionUpdate, auditReview(before), auditReview(&after)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Отзыв проверен",
+		slog.Int64("review_id", id),
+		slog.String("status", status),
+		slog.Int64("admin_id", adminID))
+	return &after, nil
+}
+
+// DeleteReview - удаление отзыва администратором.
+func (s *ReviewServiceImpl) DeleteReview(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	review, err := lockReview(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM reviews WHERE id = $1", id); err != nil {
+		return fmt.Errorf("ошибка удаления отзыва: %w", err)
+	}
+	if err := updateProductRating(ctx, tx, review, nil); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, AuditEntityReview, id, AuditActionDelete, auditReview(review), nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// checkReviewProduct - проверка, что товар есть в каталоге: отзывы об удаленных товарах
+// не показываются и не принимаются.
+func checkReviewProduct(ctx context.Context, q *db.DB, productID int64) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID).Scan(&exists); err != nil {
+		return fmt.Errorf("ошибка получения продукта по ID: %w", err)
+	}
+	if !exists {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// lockReview - блокировка отзыва перед изменением статуса или удалением.
+func lockReview(ctx context.Context, tx *db.Tx, id int64) (*Review, error) {
+	var review Review
+	err := scanReview(tx.QueryRowContext(ctx, `
+        SELECT `+reviewColumns+`
+        FROM reviews r
+        JOIN customers c ON c.id = r.customer_id
+        WHERE r.id = $1
+        FOR UPDATE OF r`, id), &review)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения отзыва: %w", err)
+	}
+	return &review, nil
+}
+
+// updateProductRating - изменение рейтинга товара при переходе отзыва из состояния
+// before в after; after == nil - отзыв удален. Рейтинг меняется, только если отзыв
+// становится одобренным или перестает им быть.
+func updateProductRating(ctx context.Context, tx *db.Tx, before, after *Review) error {
+	wasApproved := before.Status == ReviewStatusApproved
+	isApproved := after != nil && after.Status == ReviewStatusApproved
+	if wasApproved == isApproved {
+		return nil
+	}
+	sign := 1
+	if wasApproved {
+		sign = -1
+	}
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE products
+        SET rating_count = rating_count + $2, rating_sum = rating_sum + $3
+        WHERE id = $1`, before.ProductID, sign, sign*before.Rating); err != nil {
+		return fmt.Errorf("ошибка обновления рейтинга товара: %w", err)
+	}
+	return nil
+}
+
+// auditReview - поля отзыва для журнала аудита.
+func auditReview(r *Review) map[string]any {
+	return map[string]any{
+		"product_id":       r.ProductID,
+		"customer_id":      r.CustomerID,
+		"rating":           r.Rating,
+		"status":           r.Status,
+		"rejection_reason": r.RejectionReason,
+	}
+}
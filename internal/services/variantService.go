@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/metrics"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrVariantNotFound - вариант не найден, удален или относится к другому товару.
+	ErrVariantNotFound = errors.New("вариант товара не найден")
+	// ErrVariantRequired - у товара несколько вариантов, и покупка не указывает нужный.
+	ErrVariantRequired = errors.New("у товара несколько вариантов, укажите sku")
+	// ErrSKUTaken - артикул уже занят другим вариантом.
+	ErrSKUTaken = errors.New("артикул уже используется другим вариантом")
+	// ErrOutOfStock - на складе недостаточно товара для покупки.
+	ErrOutOfStock = errors.New("недостаточно товара на складе")
+)
+
+// ProductVariant - вариант товара со своим артикулом и остатком.
+type ProductVariant struct {
+	ID        int64   `json:"id"`
+	ProductID int64   `json:"product_id"`
+	SKU       string  `json:"sku" validate:"required,max=64"`
+	Color     *string `json:"color" validate:"omitempty,max=255"`
+	// Сопротивление испарителя, Ом
+	CoilResistance *float64 `json:"coil_resistance" validate:"omitempty,gt=0"`
+	// Крепость никотина, мг/мл; nil - как у товара
+	NicotineStrength *float64 `json:"nicotine_strength" validate:"omitempty,gte=0"`
+	// Цена варианта; nil - цена товара
+	PriceOverride *float64 `json:"price_override" validate:"omitempty,gte=0"`
+	// Итоговая цена варианта
+	Price float64 `json:"price"`
+	Stock int     `json:"stock" validate:"gte=0"`
+	// Изображение варианта; nil - изображение товара
+	ImageUrl *string `json:"image_url" validate:"omitempty,max=255"`
+	Position int     `json:"position"`
+	// Момент удаления; удаленные варианты остаются в покупках
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+const variantColumns = `v.id, v.product_id, v.sku, v.color, v.coil_resistance, v.nicotine_strength,
+    v.price, COALESCE(v.price, p.price), v.stock, v.image_url, v.position, v.deleted_at`
+
+// scanVariant - чтение строки, выбранной с variantColumns из product_variants v JOIN products p.
+func scanVariant(row interface{ Scan(dest ...any) error }, v *ProductVariant) error {
+	return row.Scan(
+		&v.ID,
+		&v.ProductID,
+		&v.SKU,
+		&v.Color,
+		&v.CoilResistance,
+		&v.NicotineStrength,
+		&v.PriceOverride,
+		&v.Price,
+		&v.Stock,
+		&v.ImageUrl,
+		&v.Position,
+		&v.DeletedAt,
+	)
+}
+
+type VariantService interface {
+	GetProductVariants(ctx context.Context, productID int64) ([]ProductVariant, error)
+	CreateVariant(ctx context.Context, variant ProductVariant) (*ProductVariant, error)
+	UpdateVariant(ctx context.Context, variant ProductVariant) (*ProductVariant, error)
+	DeleteVariant(ctx context.Context, productID, variantID int64) error
+}
+
+// VariantServiceImpl - управление вариантами товаров.
+type VariantServiceImpl struct {
+	db *db.DB
+}
+
+// NewVariantService - функция для создания нового сервиса вариантов товаров.
+func NewVariantService(db *db.DB) *VariantServiceImpl {
+	return &VariantServiceImpl{
+		db: db,
+	}
+}
+
+// GetProductVariants - неудаленные варианты неудаленного товара.
+func (s *VariantServiceImpl) GetProductVariants(ctx context.Context, productID int64) ([]ProductVariant, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки продукта: %w", err)
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+	return productVariants(ctx, s.db, productID)
+}
+
+// CreateVariant - добавление варианта к товару.
+func (s *VariantServiceImpl) CreateVariant(ctx context.Context, variant ProductVariant) (*ProductVariant, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var price float64
+	err = tx.QueryRowContext(ctx,
+		"SELECT price FROM products WHERE id = $1 AND deleted_at IS NULL", variant.ProductID).Scan(&price)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки продукта: %w", err)
+	}
+
+	if err := insertVariant(ctx, tx, &variant, price); err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, AuditEntityVariant, variant.ID, AuditActionCreate, nil, variant); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return &variant, nil
+}
+
+// UpdateVariant - изменение варианта. Товар варианта не меняется.
+func (s *VariantServiceImpl) UpdateVariant(ctx context.Context, variant ProductVariant) (*ProductVariant, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := lockVariant(ctx, tx, variant.ProductID, variant.ID)
+	if err != nil {
+		return nil, err
+	}
+	if variant.SKU != before.SKU {
+		if err := checkSKU(ctx, tx, variant.ID, variant.SKU); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE product_variants
+        SET sku = $2, color = $3, coil_resistance = $4, nicotine_strength = $5,
+            price = $6, stock = $7, image_url = $8, position = $9
+        WHERE id = $1`,
+		variant.ID, variant.SKU, variant.Color, variant.CoilResistance, variant.NicotineStrength,
+		variant.PriceOverride, variant.Stock, variant.ImageUrl, variant.Position); err != nil {
+		if isSKUConflict(err) {
+			return nil, ErrSKUTaken
+		}
+		return nil, fmt.Errorf("ошибка обновления варианта: %w", err)
+	}
+	after, err := lockVariant(ctx, tx, variant.ProductID, variant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, AuditEntityVariant, after.ID, AuditActionUpdate, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return after, nil
+}
+
+// DeleteVariant - мягкое удаление варианта: на него ссылаются покупки.
+// Удаление уже удаленного или отсутствующего варианта ничего не делает.
+func (s *VariantServiceImpl) DeleteVariant(ctx context.Context, productID, variantID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := lockVariant(ctx, tx, productID, variantID)
+	if errors.Is(err, ErrVariantNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	after := *before
+	now := time.Now()
+	after.DeletedAt = &now
+	if _, err := tx.ExecContext(ctx, "UPDATE product_variants SET deleted_at = $2 WHERE id = $1", variantID, now); err != nil {
+		return fmt.Errorf("ошибка удаления варианта: %w", err)
+	}
+
+	if err := recordAudit(ctx, tx, AuditEntityVariant, variantID, AuditActionDelete, before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// lockVariant - неудаленный вариант неудаленного товара с блокировкой строки варианта.
+func lockVariant(ctx context.Context, tx *db.Tx, productID, variantID int64) (*ProductVariant, error) {
+	var v ProductVariant
+	err := scanVariant(tx.QueryRowContext(ctx, `
+        SELECT `+variantColumns+`
+        FROM product_variants v JOIN products p ON p.id = v.product_id
+        WHERE v.id = $1 AND v.product_id = $2 AND v.deleted_at IS NULL AND p.deleted_at IS NULL
+        FOR UPDATE OF v`, variantID, productID), &v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVariantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения варианта: %w", err)
+	}
+	return &v, nil
+}
+
+// productVariants - неудаленные варианты товара по порядку.
+func productVariants(ctx context.Context, q queryer, productID int64) ([]ProductVariant, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT `+variantColumns+`
+        FROM product_variants v JOIN products p ON p.id = v.product_id
+        WHERE v.product_id = $1 AND v.deleted_at IS NULL
+        ORDER BY v.position, v.id`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения вариантов: %w", err)
+	}
+	defer rows.Close()
+
+	variants := []ProductVariant{}
+	for rows.Next() {
+		var v ProductVariant
+		if err := scanVariant(rows, &v); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %w", err)
+		}
+		variants = append(variants, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return variants, nil
+}
+
+// insertVariant - вставка варианта товара variant.ProductID; productPrice - цена
+// товара для итоговой цены варианта без собственной цены.
+func insertVariant(ctx context.Context, tx *db.Tx, variant *ProductVariant, productPrice float64) error {
+	if err := checkSKU(ctx, tx, 0, variant.SKU); err != nil {
+		return err
+	}
+	variant.DeletedAt = nil
+	err := tx.QueryRowContext(ctx, `
+        INSERT INTO product_variants (product_id, sku, color, coil_resistance, nicotine_strength, price, stock, image_url, position)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id`,
+		variant.ProductID, variant.SKU, variant.Color, variant.CoilResistance, variant.NicotineStrength,
+		variant.PriceOverride, variant.Stock, variant.ImageUrl, variant.Position).Scan(&variant.ID)
+	if isSKUConflict(err) {
+		return ErrSKUTaken
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при вставке варианта: %w", err)
+	}
+	variant.Price = productPrice
+	if variant.PriceOverride != nil {
+		variant.Price = *variant.PriceOverride
+	}
+	return nil
+}
+
+// isSKUConflict - нарушение уникальности артикула: вариант с тем же артикулом мог
+// быть сохранен параллельным запросом после checkSKU.
+func isSKUConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "product_variants_sku_idx"
+}
+
+// checkSKU - проверка, что артикул не занят другим вариантом, в том числе удаленным.
+func checkSKU(ctx context.Context, tx *db.Tx, id int64, sku string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM product_variants WHERE sku = $1 AND id <> $2)", sku, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки артикула: %w", err)
+	}
+	if exists {
+		return ErrSKUTaken
+	}
+	return nil
+}
+
+// purchaseVariant - вариант товара, заблокированный для покупки, с характеристиками,
+// по которым проверяются возрастные и региональные ограничения.
+type purchaseVariant struct {
+	id               int64
+	sku              string
+	productID        int64
+	price            float64
+	stock            int64
+	ageRestricted    bool
+	nicotineStrength sql.NullFloat64
+	tankCapacity     sql.NullInt64
+	flavor           sql.NullString
+}
+
+// lockPurchaseVariant - поиск варианта по товару, ID или артикулу с блокировкой строки, чтобы
+// параллельные покупки не ушли в минус по остатку. Если вариант не указан,
+// у товара должен быть ровно один вариант. Возрастное ограничение наследуется
+// от всех категорий-предков.
+func lockPurchaseVariant(ctx context.Context, tx *db.Tx, productID int64, variantID *int64, sku string) (*purchaseVariant, error) {
+	if productID == 0 && variantID == nil && sku == "" {
+		return nil, ErrVariantNotFound
+	}
+	rows, err := tx.QueryContext(ctx, `
+        SELECT v.id, v.sku, v.product_id, COALESCE(v.price, p.price), v.stock, `+categoryAgeRestricted("p.category_id")+`,
+            COALESCE(v.nicotine_strength, p.nicotine_strength), p.tank_capacity, p.flavor
+        FROM product_variants v
+        JOIN products p ON p.id = v.product_id
+        WHERE v.deleted_at IS NULL AND p.deleted_at IS NULL
+          AND ($1 = 0 OR v.product_id = $1)
+          AND ($2::bigint IS NULL OR v.id = $2)
+          AND ($3 = '' OR v.sku = $3)
+        ORDER BY v.id
+        LIMIT 2
+        FOR UPDATE OF v`, productID, variantID, sku)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения варианта товара: %w", err)
+	}
+	defer rows.Close()
+
+	var variant purchaseVariant
+	found := 0
+	for rows.Next() {
+		found++
+		if err := rows.Scan(&variant.id, &variant.sku, &variant.productID, &variant.price, &variant.stock,
+			&variant.ageRestricted, &variant.nicotineStrength, &variant.tankCapacity, &variant.flavor); err != nil {
+			return nil, fmt.Errorf("ошибка получения варианта товара: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка получения варианта товара: %w", err)
+	}
+	switch {
+	case found == 0:
+		return nil, ErrVariantNotFound
+	case found > 1:
+		return nil, ErrVariantRequired
+	}
+	return &variant, nil
+}
+
+// take - списание количества с остатка заблокированного варианта.
+func (v *purchaseVariant) take(ctx context.Context, tx *db.Tx, quantity int64) error {
+	if v.stock < quantity {
+		metrics.StockOuts.Inc()
+		return ErrOutOfStock
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE product_variants SET stock = stock - $1 WHERE id = $2", quantity, v.id); err != nil {
+		return fmt.Errorf("ошибка списания остатка: %w", err)
+	}
+	v.stock -= quantity
+	return nil
+}
+
+// returnStock - возврат количества покупки на остаток ее варианта при изменении
+// или удалении покупки.
+func returnStock(ctx context.Context, tx *db.Tx, purchase Purchase) error {
+	if purchase.VariantID == nil {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE product_variants SET stock = stock + $1 WHERE id = $2",
+		purchase.Quantity, *purchase.VariantID); err != nil {
+		return fmt.Errorf("ошибка возврата остатка: %w", err)
+	}
+	return nil
+}
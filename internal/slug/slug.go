@@ -0,0 +1,58 @@
+package slug
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaxLength - максимальная длина слага.
+const MaxLength = 255
+
+var pattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Транслитерация кириллицы
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// Make - слаг из произвольной строки: "Pod-системы 2.0" -> "pod-sistemy-2-0".
+// Может вернуть пустую строку, если в исходной нет букв и цифр.
+func Make(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		var part string
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			part = string(r)
+		case translit[r] != "":
+			part = translit[r]
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			// Прочие буквы, в том числе ъ и ь, пропускаются без разделителя
+			continue
+		default:
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(part)
+	}
+	out := b.String()
+	if len(out) > MaxLength {
+		out = strings.TrimRight(out[:MaxLength], "-")
+	}
+	return out
+}
+
+// Valid - строка состоит из латинских букв в нижнем регистре и цифр, разделенных одиночными дефисами.
+func Valid(s string) bool {
+	return len(s) <= MaxLength && pattern.MatchString(s)
+}
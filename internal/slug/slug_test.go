@@ -0,0 +1,71 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Pod-системы 2.0", "pod-sistemy-2-0"},
+		{"Жидкости", "zhidkosti"},
+		{"Щелочные ёмкости", "shchelochnye-emkosti"},
+		{"Подъезд, объём", "podezd-obem"},
+		{"ЧАЙ И ХЛЕБ", "chay-i-khleb"},
+		{"  --Одноразовые  устройства--  ", "odnorazovye-ustroystva"},
+		{"Mods & Tanks", "mods-tanks"},
+		{"Café", "caf"},
+		{"日本", ""},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Make(tt.in); got != tt.want {
+			t.Errorf("Make(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+		if got := Make(tt.in); got != "" && !Valid(got) {
+			t.Errorf("Make(%q) = %q не проходит Valid", tt.in, got)
+		}
+	}
+}
+
+func TestMakeMaxLength(t *testing.T) {
+	got := Make(strings.Repeat("a", MaxLength-1) + " b")
+	if len(got) > MaxLength || !Valid(got) {
+		t.Errorf("Make вернул %d символов, слаг допустим: %v", len(got), Valid(got))
+	}
+	if strings.HasSuffix(got, "-") {
+		t.Errorf("слаг %q оканчивается дефисом", got)
+	}
+	// Транслитерация увеличивает длину: "щ" -> "shch"
+	if got := Make(strings.Repeat("щ", MaxLength)); len(got) != MaxLength {
+		t.Errorf("длина = %d, ожидалось %d", len(got), MaxLength)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"pod-sistemy", true},
+		{"e-liquid-2", true},
+		{"a", true},
+		{"", false},
+		{"Pod", false},
+		{"pod--sistemy", false},
+		{"-pod", false},
+		{"pod-", false},
+		{"pod_sistemy", false},
+		{"под", false},
+		{strings.Repeat("a", MaxLength), true},
+		{strings.Repeat("a", MaxLength+1), false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.in); got != tt.want {
+			t.Errorf("Valid(%q) = %v, ожидалось %v", tt.in, got, tt.want)
+		}
+	}
+}
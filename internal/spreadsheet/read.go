@@ -0,0 +1,247 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize - максимальный размер распакованной части XLSX; защищает от zip-бомб.
+const maxPartSize = 256 << 20
+
+// xlsxMaxRows - максимальное количество строк листа XLSX. Номер строки берется из файла,
+// и без ограничения несколько байт вида <row r="2000000000"> заняли бы десятки гигабайт.
+const xlsxMaxRows = 1 << 20
+
+// ErrUnsupportedFormat - файл не является таблицей CSV или XLSX.
+var ErrUnsupportedFormat = errors.New("поддерживаются только файлы CSV и XLSX")
+
+// Read - строки таблицы CSV или первого листа XLSX; формат определяется по содержимому.
+// Индекс строки совпадает с ее номером в таблице минус один: пустые строки XLSX
+// возвращаются пустыми, чтобы номера строк в сообщениях совпадали с таблицей.
+func Read(data []byte) ([][]string, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readXLSX(data)
+	}
+	return readCSV(data)
+}
+
+// readCSV - чтение CSV в UTF-8. Разделитель (запятая, точка с запятой или табуляция)
+// определяется по первой строке: Excel с русской локалью сохраняет CSV через точку с запятой.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, ErrUnsupportedFormat
+	}
+
+	header := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		header = data[:i]
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = ','
+	best := bytes.Count(header, []byte{','})
+	for _, sep := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(sep))); n > best {
+			r.Comma, best = sep, n
+		}
+	}
+	r.FieldsPerRecord = -1
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения CSV: %w", err)
+	}
+	return rows, nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText - текст строки с форматированием: простой (<t>) или из частей (<r><t>).
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string   `xml:"r,attr"`
+			T  string   `xml:"t,attr"`
+			V  string   `xml:"v"`
+			Is xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX - значения ячеек первого листа книги XLSX. Числа возвращаются так,
+// как они записаны в файле (например, "12.5"), логические значения - "1" и "0".
+func readXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения XLSX: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodePart(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("ошибка чтения XLSX: нет листа %s", sheetPath)
+	}
+	var sheet xlsxSheet
+	if err := decodePart(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		n := row.R
+		if n <= 0 {
+			n = len(rows) + 1
+		}
+		if n > xlsxMaxRows {
+			return nil, fmt.Errorf("ошибка чтения XLSX: номер строки %d больше %d", n, xlsxMaxRows)
+		}
+		if n <= len(rows) {
+			return nil, fmt.Errorf("ошибка чтения XLSX: строка %d указана не по порядку", n)
+		}
+		for len(rows) < n {
+			rows = append(rows, nil)
+		}
+		var values []string
+		for i, c := range row.Cells {
+			col := i
+			if c.R != "" {
+				if col, err = columnIndex(c.R); err != nil {
+					return nil, err
+				}
+			}
+			value := c.V
+			switch c.T {
+			case "s":
+				idx, err := strconv.Atoi(c.V)
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return nil, fmt.Errorf("ошибка чтения XLSX: недопустимая ссылка на строку в ячейке %s", c.R)
+				}
+				value = shared.Items[idx].String()
+			case "inlineStr":
+				value = c.Is.String()
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			values[col] = value
+		}
+		rows[n-1] = values
+	}
+	return rows, nil
+}
+
+// firstSheet - путь к первому листу книги по workbook.xml и его связям.
+func firstSheet(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+	wf, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", ErrUnsupportedFormat
+	}
+	var wb xlsxWorkbook
+	if err := decodePart(wf, &wb); err != nil {
+		return "", err
+	}
+	rf, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok || len(wb.Sheets) == 0 {
+		return fallback, nil
+	}
+	var rels xlsxRelationships
+	if err := decodePart(rf, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != wb.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+// decodePart - разбор XML-части архива с ограничением размера.
+func decodePart(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("ошибка чтения XLSX: %w", err)
+	}
+	defer rc.Close()
+	lr := &io.LimitedReader{R: rc, N: maxPartSize}
+	if err := xml.NewDecoder(lr).Decode(v); err != nil {
+		if lr.N <= 0 {
+			return fmt.Errorf("ошибка чтения XLSX: часть %s слишком большая", f.Name)
+		}
+		return fmt.Errorf("ошибка чтения XLSX: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// columnIndex - номер столбца (с нуля) по адресу ячейки, например 2 для "C7".
+func columnIndex(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A') + 1
+	}
+	if i == 0 || col > 16384 {
+		return 0, fmt.Errorf("ошибка чтения XLSX: недопустимый адрес ячейки %q", ref)
+	}
+	return col - 1, nil
+}
@@ -0,0 +1,141 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testXLSX - книга XLSX с первым листом sheetData.
+func testXLSX(t *testing.T, sheetData string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"xl/workbook.xml":          `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"/>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSXRowNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		sheet   string
+		want    [][]string
+		wantErr string
+	}{
+		{
+			name:  "пропущенные строки возвращаются пустыми",
+			sheet: `<row r="1"><c r="A1" t="inlineStr"><is><t>sku</t></is></c></row><row r="3"><c r="B3"><v>5</v></c></row>`,
+			want:  [][]string{{"sku"}, nil, {"", "5"}},
+		},
+		{
+			name:  "строки без номера идут подряд",
+			sheet: `<row><c t="inlineStr"><is><t>a</t></is></c></row><row><c t="inlineStr"><is><t>b</t></is></c></row>`,
+			want:  [][]string{{"a"}, {"b"}},
+		},
+		{
+			name:    "номер строки больше предела XLSX",
+			sheet:   `<row r="2000000000"><c r="A2000000000"><v>1</v></c></row>`,
+			wantErr: "номер строки 2000000000",
+		},
+		{
+			name:  "последняя допустимая строка",
+			sheet: `<row r="1048576"><c r="A1048576"><v>1</v></c></row>`,
+		},
+		{
+			name:    "строки не по порядку",
+			sheet:   `<row r="5"><c r="A5"><v>1</v></c></row><row r="2"><c r="A2"><v>2</v></c></row>`,
+			wantErr: "не по порядку",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := Read(testXLSX(t, tt.sheet))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка = %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("строки = %q, ожидалось %q", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    [][]string
+		wantErr error
+	}{
+		{
+			name: "запятая",
+			data: "sku,name,price\nA-1,Жидкость,450.5\n",
+			want: [][]string{{"sku", "name", "price"}, {"A-1", "Жидкость", "450.5"}},
+		},
+		{
+			name: "точка с запятой из Excel с BOM",
+			data: "\xef\xbb\xbfsku;name;price\r\nA-1;Жидкость, 30 мл;450,5\r\n",
+			want: [][]string{{"sku", "name", "price"}, {"A-1", "Жидкость, 30 мл", "450,5"}},
+		},
+		{
+			name: "табуляция",
+			data: "sku\tname\nA-1\t\"Pod, 2 мл\"\n",
+			want: [][]string{{"sku", "name"}, {"A-1", "Pod, 2 мл"}},
+		},
+		{
+			name: "строки разной длины",
+			data: "sku;name;price\nA-1;Жидкость\n",
+			want: [][]string{{"sku", "name", "price"}, {"A-1", "Жидкость"}},
+		},
+		{
+			name: "одна строка без перевода",
+			data: "sku;stock",
+			want: [][]string{{"sku", "stock"}},
+		},
+		{
+			name:    "двоичный файл",
+			data:    "\x89PNG\r\n\x1a\n\x00\x00",
+			wantErr: ErrUnsupportedFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := Read([]byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ошибка = %v, ожидалась %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("строки = %q, ожидалось %q", rows, tt.want)
+			}
+		})
+	}
+}
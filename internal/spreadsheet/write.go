@@ -0,0 +1,238 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Форматы выгрузки
+const (
+	FormatCSV       = "csv"
+	FormatXLSX      = "xlsx"
+	FormatJSONLines = "jsonl"
+)
+
+// ContentTypes - MIME-типы форматов выгрузки.
+var ContentTypes = map[string]string{
+	FormatCSV:       "text/csv; charset=utf-8",
+	FormatXLSX:      "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatJSONLines: "application/x-ndjson",
+}
+
+// Writer - потоковая запись таблицы. Значения строк: nil, string, int64, float64, bool,
+// time.Time или json.RawMessage (в JSON Lines вставляется как есть, в остальных форматах - текстом).
+type Writer interface {
+	// WriteHeader - названия столбцов; вызывается один раз перед строками
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+	// Close - завершение файла и запись буферов; нижележащий io.Writer не закрывается
+	Close() error
+}
+
+// NewWriter - запись таблицы в формате format.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return &xlsxWriter{zw: zip.NewWriter(w)}, nil
+	case FormatJSONLines:
+		return &jsonLinesWriter{w: bufio.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат выгрузки: %s", format)
+	}
+}
+
+// text - значение ячейки текстом.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = text(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonLinesWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (j *jsonLinesWriter) WriteHeader(columns []string) error {
+	j.columns = columns
+	return nil
+}
+
+// WriteRow - объект с ключами-столбцами в порядке заголовка.
+func (j *jsonLinesWriter) WriteRow(values []any) error {
+	j.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(j.columns[i])
+		j.w.Write(key)
+		j.w.WriteByte(':')
+		var value []byte
+		if raw, ok := v.(json.RawMessage); ok {
+			value = raw
+		} else {
+			var err error
+			if value, err = json.Marshal(v); err != nil {
+				return err
+			}
+		}
+		j.w.Write(value)
+	}
+	j.w.WriteByte('}')
+	_, err := j.w.WriteString("\n")
+	return err
+}
+
+func (j *jsonLinesWriter) Close() error {
+	return j.w.Flush()
+}
+
+// xlsxWriter - книга из одного листа. Строки записываются в лист по мере поступления
+// без общей таблицы строк (inlineStr), поэтому память не зависит от размера выгрузки.
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+func (x *xlsxWriter) WriteHeader(columns []string) error {
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	} {
+		w, err := x.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+
+	// Лист записывается последним: следующий Create завершил бы его
+	w, err := x.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(w)
+	x.sheet.WriteString(xlsxSheetStart)
+
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		values[i] = c
+	}
+	return x.WriteRow(values)
+}
+
+func (x *xlsxWriter) WriteRow(values []any) error {
+	x.row++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		ref := columnName(i) + strconv.Itoa(x.row)
+		switch v := v.(type) {
+		case int64, float64:
+			fmt.Fprintf(x.sheet, `<c r="%s"><v>%s</v></c>`, ref, text(v))
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			fmt.Fprintf(x.sheet, `<c r="%s" t="b"><v>%s</v></c>`, ref, b)
+		default:
+			fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(x.sheet, []byte(text(v))); err != nil {
+				return err
+			}
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if x.sheet == nil {
+		if err := x.WriteHeader(nil); err != nil {
+			return err
+		}
+	}
+	x.sheet.WriteString(xlsxSheetEnd)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// columnName - буквенное имя столбца по номеру с нуля: A, B, ..., Z, AA, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,122 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 8, 10, 30, 0, 0, time.UTC)
+	columns := []string{"sku", "name", "price", "stock", "active", "comment", "attributes", "created_at"}
+	rows := [][]any{
+		{"A-1", "Жидкость <Манго & лед>", 450.5, int64(12), true, nil, json.RawMessage(`{"flavor":"манго"}`), created},
+		{"A-2", "  пробелы по краям  ", float64(0), int64(-1), false, "строка\nвторая", nil, created},
+	}
+	want := [][]string{
+		columns,
+		{"A-1", "Жидкость <Манго & лед>", "450.5", "12", "", "", `{"flavor":"манго"}`, "2024-03-08T10:30:00Z"},
+		{"A-2", "  пробелы по краям  ", "0", "-1", "", "строка\nвторая", "", "2024-03-08T10:30:00Z"},
+	}
+
+	tests := []struct {
+		format              string
+		wantTrue, wantFalse string
+	}{
+		{FormatCSV, "true", "false"},
+		// Логические значения XLSX читаются как "1" и "0"
+		{FormatXLSX, "1", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(tt.format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteHeader(columns); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows {
+				if err := w.WriteRow(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Read(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := make([][]string, len(want))
+			for i := range want {
+				expected[i] = append([]string(nil), want[i]...)
+			}
+			expected[1][4], expected[2][4] = tt.wantTrue, tt.wantFalse
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("строки = %q, ожидалось %q", got, expected)
+			}
+		})
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatJSONLines, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteHeader([]string{"sku", "price", "attributes", "comment"})
+	w.WriteRow([]any{"A-1", 450.5, json.RawMessage(`{"flavor":"манго"}`), nil})
+	w.WriteRow([]any{"A-2", int64(10), json.RawMessage(`null`), "\"кавычки\""})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"sku":"A-1","price":450.5,"attributes":{"flavor":"манго"},"comment":null}` + "\n" +
+		`{"sku":"A-2","price":10,"attributes":null,"comment":"\"кавычки\""}` + "\n"
+	if buf.String() != want {
+		t.Errorf("JSON Lines = %s, ожидалось %s", buf.String(), want)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("строка не является JSON: %s", line)
+		}
+	}
+}
+
+func TestWriteEmptyXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatXLSX, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(buf.Bytes()); err != nil {
+		t.Errorf("пустая книга не читается: %v", err)
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xls", &bytes.Buffer{}); err == nil {
+		t.Error("ожидалась ошибка для неизвестного формата")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, ожидалось %s", i, got, want)
+		}
+		if got, err := columnIndex(want + "1"); err != nil || got != i {
+			t.Errorf("columnIndex(%s1) = %d, %v, ожидалось %d", want, got, err, i)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FileStore - хранение объектов в локальном каталоге. Тип содержимого
+// определяется по расширению ключа.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore - создание хранилища в каталоге dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{
+		dir: dir,
+	}
+}
+
+// Put - запись объекта. Файл сначала пишется во временный и переименовывается,
+// чтобы параллельное чтение не увидело его частично записанным.
+func (s *FileStore) Put(_ context.Context, key string, content []byte, _ string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("ошибка записи файла: %w", err)
+	}
+	return nil
+}
+
+// Get - открытие объекта на чтение.
+func (s *FileStore) Get(_ context.Context, key string) (*Object, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
+	}
+	return &Object{
+		Body:        f,
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		Size:        info.Size(),
+	}, nil
+}
+
+// Delete - удаление объекта.
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("ошибка удаления файла: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
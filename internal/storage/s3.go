@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config - параметры S3-совместимого хранилища.
+type S3Config struct {
+	// Адрес сервиса, например https://s3.eu-central-1.amazonaws.com или http://localhost:9000 для MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Адресация bucket в пути (endpoint/bucket/key), а не в имени хоста; нужна MinIO
+	PathStyle bool
+}
+
+// s3RequestTimeout - время на отправку запроса к S3 и получение заголовков ответа.
+// Чтение тела ответа не ограничивается: объект может передаваться клиенту дольше.
+const s3RequestTimeout = 30 * time.Second
+
+// S3Store - хранение объектов в S3-совместимом хранилище. Запросы подписываются
+// AWS Signature Version 4.
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	timeout  time.Duration
+}
+
+// NewS3Store - создание клиента хранилища.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("недопустимый адрес S3: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("не указан bucket S3")
+	}
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{},
+		timeout:  s3RequestTimeout,
+	}, nil
+}
+
+// Put - загрузка объекта.
+func (s *S3Store) Put(ctx context.Context, key string, content []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, content, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Get - получение объекта.
+func (s *S3Store) Get(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &Object{
+			Body:        resp.Body,
+			ContentType: resp.Header.Get("Content-Type"),
+			Size:        resp.ContentLength,
+		}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+}
+
+// Delete - удаление объекта. S3 отвечает 204 и для отсутствующих объектов.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// do - выполнение подписанного запроса к объекту. Если заголовки ответа не получены
+// за s.timeout, запрос отменяется; после этого тело ответа читается без ограничения
+// по времени, пока его не закроют или не отменят ctx.
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	u := *s.endpoint
+	objectPath := "/" + uriEncode(key, false)
+	if s.cfg.PathStyle {
+		objectPath = "/" + uriEncode(s.cfg.Bucket, true) + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	basePath := strings.TrimSuffix(u.Path, "/")
+	u.Path = ""
+	u.RawPath = ""
+	target := u.String() + basePath + objectPath
+
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("ошибка запроса к S3: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	timer := time.AfterFunc(s.timeout, cancel)
+	resp, err := s.client.Do(req)
+	if !timer.Stop() && err != nil {
+		err = fmt.Errorf("нет ответа за %s: %w", s.timeout, err)
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("ошибка запроса к S3: %w", err)
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose - тело ответа, которое при закрытии освобождает контекст запроса.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// sign - подпись запроса AWS Signature Version 4 с заголовком Authorization.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-date":           amzDate,
+		"x-amz-content-sha256": payloadHash,
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// uriEncode - кодирование по правилам SigV4: не кодируются только A-Z, a-z, 0-9, "-", "_", ".", "~"
+// и, если encodeSlash = false, "/".
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// responseError - ошибка с кодом и началом тела ответа S3 (XML с описанием).
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("ошибка S3: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+	testBucket    = "images"
+)
+
+// fakeS3 - заглушка S3 в памяти, которая проверяет подпись SigV4 каждого запроса.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// Ошибки проверки подписи
+	signatureErrors []error
+	// Задержка перед отправкой заголовков ответа
+	delay time.Duration
+}
+
+type fakeObject struct {
+	content     []byte
+	contentType string
+}
+
+var authorizationPattern = regexp.MustCompile(
+	`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	t.Helper()
+	f := &fakeS3{objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifySignature(r, body); err != nil {
+		f.mu.Lock()
+		f.signatureErrors = append(f.signatureErrors, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+		f.mu.Unlock()
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+	time.Sleep(f.delay)
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = fakeObject{content: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.content)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verifySignature - проверка заголовка Authorization по запросу, полученному сервером.
+func verifySignature(r *http.Request, body []byte) error {
+	m := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return errors.New("неверный формат Authorization: " + r.Header.Get("Authorization"))
+	}
+	accessKey, date, region, signedHeaders, signature := m[1], m[2], m[3], m[4], m[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return errors.New("неверная область подписи: " + m[0])
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return errors.New("дата подписи не совпадает с X-Amz-Date")
+	}
+	payloadHash := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return errors.New("хэш тела не совпадает с X-Amz-Content-Sha256")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + date + "/" + region + "/s3/aws4_request\n" +
+		hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request", stringToSign} {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(part))
+		key = h.Sum(nil)
+	}
+	if want := hex.EncodeToString(key); signature != want {
+		return errors.New("подпись не совпадает")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+signedHeaders+";", ";"+required+";") {
+			return errors.New("заголовок не подписан: " + required)
+		}
+	}
+	return nil
+}
+
+func newTestS3Store(t *testing.T, endpoint, secretKey string) *S3Store {
+	t.Helper()
+	store, err := NewS3Store(S3Config{
+		Endpoint:  endpoint,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	f, srv := newFakeS3(t)
+	store := newTestS3Store(t, srv.URL, testSecretKey)
+	ctx := context.Background()
+	key := "products/1/original image.png"
+
+	if err := store.Put(ctx, key, []byte("png data"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "png data" {
+		t.Errorf("содержимое = %q, ожидалось %q", content, "png data")
+	}
+	if obj.ContentType != "image/png" {
+		t.Errorf("ContentType = %q, ожидалось image/png", obj.ContentType)
+	}
+	if obj.Size != int64(len(content)) {
+		t.Errorf("Size = %d, ожидалось %d", obj.Size, len(content))
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get после удаления: %v, ожидалось ErrNotFound", err)
+	}
+	// Удаление отсутствующего объекта не считается ошибкой
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range f.signatureErrors {
+		t.Error(err)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	f, srv := newFakeS3(t)
+	store := newTestS3Store(t, srv.URL, "wrong-secret")
+
+	err := store.Put(context.Background(), "a.png", []byte("x"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("ошибка = %v, ожидалась SignatureDoesNotMatch", err)
+	}
+	if len(f.signatureErrors) != 1 {
+		t.Errorf("ошибок подписи: %d, ожидалась 1", len(f.signatureErrors))
+	}
+}
+
+func TestS3StoreResponseTimeout(t *testing.T) {
+	f, srv := newFakeS3(t)
+	f.delay = 200 * time.Millisecond
+	store := newTestS3Store(t, srv.URL, testSecretKey)
+	store.timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if err := store.Put(context.Background(), "a.png", []byte("x"), "image/png"); err == nil {
+		t.Fatal("ожидалась ошибка при отсутствии ответа")
+	}
+	if elapsed := time.Since(start); elapsed >= f.delay {
+		t.Errorf("запрос отменен через %v, ожидалось около %v", elapsed, store.timeout)
+	}
+}
+
+func TestS3StoreGetStreamsPastTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("first "))
+		w.(http.Flusher).Flush()
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("second"))
+	}))
+	t.Cleanup(srv.Close)
+	store := newTestS3Store(t, srv.URL, testSecretKey)
+	store.timeout = 50 * time.Millisecond
+
+	obj, err := store.Get(context.Background(), "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Body.Close()
+	content, err := io.ReadAll(obj.Body)
+	if err != nil {
+		t.Fatalf("чтение тела прервано: %v", err)
+	}
+	if string(content) != "first second" {
+		t.Errorf("содержимое = %q, ожидалось %q", content, "first second")
+	}
+}
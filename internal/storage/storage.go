@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound - объект с таким ключом отсутствует в хранилище.
+var ErrNotFound = errors.New("объект не найден")
+
+// Object - содержимое объекта хранилища. Body закрывает вызывающий.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	// Размер в байтах; -1, если неизвестен
+	Size int64
+}
+
+// BlobStore - хранилище файлов по ключам вида "a/b/c.jpg". Реализации: локальный
+// каталог и S3-совместимое хранилище (AWS S3, MinIO и т. п.).
+type BlobStore interface {
+	Put(ctx context.Context, key string, content []byte, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete - удаление объекта; удаление отсутствующего объекта не считается ошибкой.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey - ключ не должен выходить за пределы хранилища и содержать пустые сегменты.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("недопустимый ключ объекта: %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsRune(part, '\\') {
+			return fmt.Errorf("недопустимый ключ объекта: %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Значения подставляются при сборке:
+//
+//	go build -ldflags "-X VapeShop-ClientAPI/internal/version.Commit=$(git rev-parse HEAD) \
+//	    -X VapeShop-ClientAPI/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info - информация о сборке сервиса.
+type Info struct {
+	Commit string `json:"commit"`
+	// Время сборки известно, только если передано через -ldflags
+	BuildTime string `json:"build_time,omitempty"`
+	// Время коммита из данных VCS, которые Go встраивает в бинарник
+	CommitTime string `json:"commit_time,omitempty"`
+	GoVersion  string `json:"go_version"`
+}
+
+// Get - получение информации о сборке. Если коммит не был передан через -ldflags,
+// он берется из данных VCS, которые Go встраивает в бинарник.
+func Get() Info {
+	info := Info{
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				info.CommitTime = s.Value
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
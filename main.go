@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"VapeShop-ClientAPI/internal/config"
 	"VapeShop-ClientAPI/internal/db"
+	"VapeShop-ClientAPI/internal/logger"
 	"VapeShop-ClientAPI/internal/router" // Импортируем новый пакет router
+	"VapeShop-ClientAPI/internal/tracing"
 )
 
 func main() {
@@ -12,13 +21,47 @@ func main() {
 		panic(err)
 	}
 
+	// DEBUG=true - текстовый формат логов, иначе JSON
+	slog.SetDefault(logger.New(os.Stdout, cfg.Debug))
+	slog.Info("Конфигурация загружена",
+		slog.String("server_address", cfg.ServerAddress),
+		slog.String("database_host", cfg.Database.Host),
+		slog.String("database_name", cfg.Database.Name),
+		slog.Bool("debug", cfg.Debug))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		panic(err)
+	}
+	// Отправляем оставшиеся спаны после остановки сервера
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Ошибка остановки трассировки", slog.Any("error", err))
+		}
+	}()
+
 	db, err := db.NewDB(cfg.Database)
 	if err != nil {
 		panic(err)
 	}
 
-	server := router.NewServer(cfg, db) // Передаем конфигурацию и базу данных
-	if err := server.Run(cfg.ServerAddress); err != nil {
+	if cfg.Database.AutoMigrate {
+		if err := db.Migrate(context.Background()); err != nil {
+			panic(err)
+		}
+	}
+
+	// Контекст отменяется по SIGINT/SIGTERM, что запускает плавную остановку сервера
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server, err := router.NewServer(cfg, db) // Передаем конфигурацию и базу данных
+	if err != nil {
+		panic(err)
+	}
+	if err := server.Run(ctx, cfg.ServerAddress); err != nil {
 		panic(err)
 	}
 }